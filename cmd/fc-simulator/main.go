@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+
+	focusritesimulator "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-simulator"
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/logger"
+)
+
+var log *logger.CustomLogger = logger.WithPackage("main")
+
+// Runs a fake Focusrite Control server for demos without real hardware
+func main() {
+	deviceFile := flag.String("device", "xml/device-arrival-18i20.xml", "device-arrival xml fixture")
+	valueFile := flag.String("values", "", "optional set xml fixture with initial values")
+	deny := flag.Bool("deny", false, "deny approval for new clients")
+	flag.Parse()
+
+	cfg := focusritesimulator.DefaultConfig()
+	cfg.Authorised = !*deny
+
+	sim := focusritesimulator.NewServer(cfg)
+	err := sim.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sim.Close()
+
+	id, err := sim.AddDeviceFromFile(*deviceFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *valueFile != "" {
+		err = sim.LoadValuesFromFile(id, *valueFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Warnf("Simulating device %d on port %d", id, sim.Port())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
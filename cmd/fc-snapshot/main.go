@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/config"
+	focusriteclient "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-client"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/logger"
+)
+
+var log *logger.CustomLogger = logger.WithPackage("main")
+
+const (
+	CONNECT_TIMEOUT time.Duration = 30 * time.Second
+	SETTLE_TIME     time.Duration = 1 * time.Second // values arrive in several sets after subscribing
+)
+
+// Saves and restores the state of a device (mixes, routing, outputs ...) without Focusrite Control GUI.
+// Server and client key are taken from the monitor controller configuration, so no new approval is needed.
+func main() {
+	exportFile := flag.String("export", "", "save the device state to file (.json or .yaml)")
+	importFile := flag.String("import", "", "restore the device state from file")
+	serial := flag.String("serial", "", "serial number of the device, default: configured device")
+	host := flag.String("host", "", "Focusrite Control host, default: configured or discovered")
+	dryRun := flag.Bool("dry-run", false, "only show the changes of an import")
+	flag.Parse()
+
+	if (*exportFile == "") == (*importFile == "") {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Warnf("no configuration loaded, using defaults: %s", err.Error())
+		cfg = config.Default()
+	}
+	fcCfg := cfg.FocusriteDevice
+	if *host != "" {
+		fcCfg.FocusriteHost = *host
+		fcCfg.FocusritePort = 0
+	}
+	if *serial != "" {
+		fcCfg.FocusriteSerialNumber = *serial
+	}
+
+	// the client runs until it's closed, only waiting for the device is limited
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := focusriteclient.NewFocusriteClient(focusriteclient.UpdateDevice, fcCfg.ServerConfig())
+	client.SetClientIdentity(fcCfg.ClientKey, "")
+	err = client.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, CONNECT_TIMEOUT)
+	deviceId, err := waitForDevice(waitCtx, client, fcCfg.FocusriteSerialNumber)
+	waitCancel()
+	if err != nil {
+		client.Close()
+		log.Fatal(err)
+	}
+
+	// the client keeps updating the device, it's only read through WithDevice
+	if *exportFile != "" {
+		err = exportSnapshot(client, deviceId, *exportFile)
+	} else {
+		err = importSnapshot(client, deviceId, *importFile, *dryRun)
+	}
+	client.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// waitForDevice waits for the arrival and the first values of the device
+func waitForDevice(ctx context.Context, client *focusriteclient.FocusriteClient, serial string) (int, error) {
+	deviceId := 0
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			if deviceId == 0 {
+				return 0, ctx.Err()
+			}
+			go drain(client)
+			return deviceId, nil
+
+		case <-settled:
+			go drain(client)
+			return deviceId, nil
+
+		case msg, ok := <-client.FromFocusrite:
+			if !ok {
+				return 0, context.Canceled
+			}
+
+			switch m := msg.(type) {
+			case focusriteclient.ApprovalMessasge:
+				if !m {
+					log.Warn("approve the app in Focusrite Control")
+				}
+
+			case focusriteclient.DeviceArrivalMessage:
+				log.Infof("Device %s (%s) arrived", m.Model, m.SerialNumber)
+				if deviceId == 0 && (serial == "" || m.SerialNumber == serial) {
+					deviceId = m.ID
+				}
+
+			case focusriteclient.DeviceUpdateMessage:
+				if m.ID == deviceId && settled == nil {
+					settled = time.After(SETTLE_TIME)
+				}
+			}
+		}
+	}
+}
+
+func drain(client *focusriteclient.FocusriteClient) {
+	for range client.FromFocusrite {
+	}
+}
+
+func exportSnapshot(client *focusriteclient.FocusriteClient, deviceId int, file string) error {
+	var snapshot *focusritexml.DeviceSnapshot
+	if !client.WithDevice(deviceId, func(device *focusritexml.Device) { snapshot = device.ExportSnapshot() }) {
+		return fmt.Errorf("device %d is gone", deviceId)
+	}
+
+	var data []byte
+	var err error
+	if isJson(file) {
+		data, err = snapshot.JSON()
+	} else {
+		data, err = snapshot.YAML()
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(file, data, 0644)
+	if err != nil {
+		return err
+	}
+	log.Infof("Saved %d values of %s (%s) to %s", len(snapshot.Values), snapshot.Model, snapshot.SerialNumber, file)
+	return nil
+}
+
+func importSnapshot(client *focusriteclient.FocusriteClient, deviceId int, file string, dryRun bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	snapshot, err := focusritexml.ParseSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	var (
+		model string
+		set   *focusritexml.Set
+		errs  []error
+		paths = make(map[int]string)
+	)
+	found := client.WithDevice(deviceId, func(device *focusritexml.Device) {
+		model = device.Model
+		set, errs = device.RestoreSet(snapshot)
+		for _, item := range set.Items {
+			p, _ := device.PathOf(item.ID)
+			paths[item.ID] = p.Path
+		}
+	})
+	if !found {
+		return fmt.Errorf("device %d is gone", deviceId)
+	}
+
+	if snapshot.Model != model {
+		log.Warnf("Snapshot of %s restored to %s", snapshot.Model, model)
+	}
+	for _, err := range errs {
+		log.Warnf("not restored: %s", err.Error())
+	}
+	for _, item := range set.Items {
+		log.Infof("%s = %s", paths[item.ID], item.Value)
+	}
+	log.Infof("%d of %d values differ", len(set.Items), len(snapshot.Values))
+
+	if dryRun || len(set.Items) == 0 {
+		return nil
+	}
+	err = client.SendSet(*set)
+	if err != nil {
+		return fmt.Errorf("snapshot not restored: %w", err)
+	}
+	log.Infof("Restored %d values", len(set.Items))
+	return nil
+}
+
+func isJson(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".json")
+}
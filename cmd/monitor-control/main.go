@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"sync"
 
 	fcaudioconnector "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-connector"
 	mcuconnector "github.com/sebastianrau/focusrite-mackie-control/pkg/mcu-connector"
@@ -47,8 +49,6 @@ func main() {
 	log.Infof("Monitor Controller %v", Version)
 
 	cfg, err := config.Load()
-	go cfg.RunAutoSave()
-
 	if err != nil {
 		log.Errorln("Loading configuration failed. Loading default values")
 		cfg = config.Default()
@@ -57,6 +57,7 @@ func main() {
 			log.Errorln("Configuration could not be stored")
 		}
 	}
+	go cfg.RunAutoSave()
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
@@ -82,6 +83,9 @@ func main() {
 		setActivationPolicy()
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	mcu := mcuconnector.NewMcuConnector(&cfg.Midi)
 	if mcu == nil {
 		log.Warnf("could not open Midi System")
@@ -93,11 +97,27 @@ func main() {
 		os.Exit(-1)
 	}
 
+	var shutdownOnce sync.Once
+	shutdown := func() {
+		shutdownOnce.Do(func() {
+			if mcu != nil {
+				if err := mcu.Close(); err != nil {
+					log.Error(err.Error())
+				}
+			}
+			if err := fc.Close(); err != nil {
+				log.Error(err.Error())
+			}
+		})
+	}
+	mainGui.OnShutdown = shutdown
+
 	mc := monitorcontroller.NewController(fc, &cfg.MonitorController)
 	if mc == nil {
 		log.Errorf("Could not load monitor Controller")
 		os.Exit(-3)
 	}
+	go cfg.RunFollowController(mc.Changes())
 
 	if mcu != nil {
 		mc.RegisterRemoteController(mcu)
@@ -107,15 +127,28 @@ func main() {
 		mc.RegisterRemoteController(mainGui)
 	}
 
+	// the connectors are started once the controller is wired up, their first events need the control channels
+	if mcu != nil {
+		if err := mcu.Start(ctx); err != nil {
+			log.Errorf("Could not start Midi System: %s", err.Error())
+		}
+	}
+	if err := fc.Start(ctx); err != nil {
+		log.Errorf("Could not start Audio Connector: %s", err.Error())
+		os.Exit(-1)
+	}
+
 	go func() {
 		for range interrupt {
 			err := cfg.Save()
 			if err != nil {
 				log.Error(err.Error())
 			}
+			shutdown()
 			os.Exit(0)
 		}
 	}()
 
 	mainGui.ShowAndRun()
+	shutdown()
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	fcaudioconnector "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-connector"
@@ -26,7 +27,8 @@ type Config struct {
 	Midi              mcuconnector.McuConnectorConfig
 	FocusriteDevice   fcaudioconnector.FcConfiguration
 	MonitorController monitorcontroller.ControllerSate
-	crc               uint64 `yaml:"-"`
+	crc               uint64     `yaml:"-"`
+	mutex             sync.Mutex `yaml:"-"` // the monitor controller state follows the controller while it's saved
 }
 
 func getPath() (string, error) {
@@ -52,6 +54,7 @@ func Default() *Config {
 		FocusriteDevice:   *fcaudioconnector.DefaultConfiguration(),
 		MonitorController: *monitorcontroller.NewDefaultState(),
 	}
+	c.FocusriteDevice.SetLocker(&c.mutex)
 	return c
 }
 
@@ -72,10 +75,21 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	// the audio connector writes the resolved elements and the client id while the config is saved
+	config.FocusriteDevice.SetLocker(&config.mutex)
 
 	return &config, nil
 }
 
+// RunFollowController replaces the monitor controller state with every snapshot of the controller
+func (c *Config) RunFollowController(changes <-chan *monitorcontroller.ControllerSate) {
+	for snapshot := range changes {
+		c.mutex.Lock()
+		c.MonitorController = *snapshot
+		c.mutex.Unlock()
+	}
+}
+
 func (c *Config) RunAutoSave() {
 	t := time.NewTicker(autoSaveTime)
 	for range t.C {
@@ -92,6 +106,8 @@ func (c *Config) RunAutoSave() {
 }
 
 func (c *Config) Save() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
 	path, err := getPath()
 	if err != nil {
@@ -109,7 +125,7 @@ func (c *Config) Save() error {
 	}
 	defer file.Close()
 
-	buf, err := yaml.Marshal(*c)
+	buf, err := yaml.Marshal(c)
 	if err != nil {
 		return err
 	}
@@ -128,6 +144,9 @@ func (c *Config) Save() error {
 }
 
 func (c *Config) UpdateChanged() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	buf, err := yaml.Marshal(c)
 	if err != nil {
 		log.Error(err)
@@ -140,6 +159,9 @@ func (c *Config) UpdateChanged() bool {
 }
 
 func (c *Config) DeepCopy() (*Config, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	var dst Config
 
 	data, err := yaml.Marshal(c)
@@ -1,33 +1,31 @@
 package focusriteclient
 
 import (
-	"encoding/xml"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
 )
 
-// port 30096 - 30098
-// Length=000042 <client-discovery app="SAFFIRE-CONTROL" version="4" device="iOS"/>
-
-type DiscoveryRequest struct {
-	XMLName xml.Name `xml:"client-discovery"`
-	App     string   `xml:"app,attr,omitempty"`
-	Version string   `xml:"version,attr,omitempty"`
-	Device  string   `xml:"device,attr,omitempty"`
+// DiscoveredServer is a Focusrite Control server that answered the discovery
+type DiscoveredServer struct {
+	Hostname string
+	Address  string
+	Port     int
 }
 
-// <server-announcement app='SAFFIRE-CONTROL' version='4' hostname='MacBook-Pro-von-Sebastian.local' port='55145'/>
-type ServerAnnouncement struct {
-	XMLName  xml.Name `xml:"server-announcement"`
-	App      string   `xml:"app,attr,omitempty"`
-	Version  string   `xml:"version,attr,omitempty"`
-	Hostname string   `xml:"hostname,attr,omitempty"`
-	Port     int      `xml:"port,attr,omitempty"`
+func (s DiscoveredServer) String() string {
+	return fmt.Sprintf("%s (%s)", s.Hostname, net.JoinHostPort(s.Address, strconv.Itoa(s.Port)))
 }
 
+const (
+	DISCOVERY_TIME time.Duration = 1 * time.Second
+	BROADCAST_IP   string        = "255.255.255.255"
+	LOCALHOST_IP   string        = "127.0.0.1"
+)
+
 var (
 	ports []int = []int{
 		30096,
@@ -35,66 +33,122 @@ var (
 		30098,
 	}
 
-	dc DiscoveryRequest = DiscoveryRequest{
+	dc focusritexml.ClientDiscovery = focusritexml.ClientDiscovery{
 		App:     "SAFFIRE-CONTROL",
 		Version: "4",
 		Device:  "iOS",
 	}
 )
 
-func DiscoverServer() (int, error) {
-	for _, p := range ports {
-		port, err := discoverClient(p)
-		if err == nil {
-			return port, nil
-		}
-	}
-	return 0, fmt.Errorf("no server found")
-}
-
-func discoverClient(port int) (int, error) {
-	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", "127.0.0.1", port))
-	if err != nil {
-		return 0, err
+// DiscoverServers sends a discovery request to host, or if host is empty to the local machine
+// and as broadcast to the subnet, and collects all server announcements until timeout.
+func DiscoverServers(host string, timeout time.Duration) ([]DiscoveredServer, error) {
+	targets := []string{host}
+	if host == "" {
+		targets = []string{LOCALHOST_IP, BROADCAST_IP}
 	}
 
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer conn.Close()
 
 	msg, err := focusritexml.ParseToXML(dc)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	_, err = conn.Write([]byte(msg))
+
+	sent := 0
+	for _, t := range targets {
+		for _, p := range ports {
+			udpAddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(t, strconv.Itoa(p)))
+			if err != nil {
+				return nil, err
+			}
+			_, err = conn.WriteToUDP([]byte(msg), udpAddr)
+			if err != nil {
+				log.Debugf("discovery to %s failed: %s", udpAddr, err.Error())
+				continue
+			}
+			sent++
+		}
+	}
+	if sent == 0 {
+		return nil, fmt.Errorf("discovery request could not be sent")
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(timeout))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
+	servers := make([]DiscoveredServer, 0)
 	buffer := make([]byte, 1024)
-	deadline := time.Now().Add(5 * time.Second)
-	err = conn.SetReadDeadline(deadline)
-	if err != nil {
-		return 0, err
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			break // deadline reached
+		}
+
+		announcement, err := parseAnnouncement(buffer[:n])
+		if err != nil {
+			log.Warnf("invalid server announcement from %s: %s", addr, err.Error())
+			continue
+		}
+
+		server := DiscoveredServer{
+			Hostname: announcement.Hostname,
+			Address:  addr.IP.String(),
+			Port:     announcement.Port,
+		}
+		if !containsServer(servers, server) {
+			log.Debugf("discovered server %s", server)
+			servers = append(servers, server)
+		}
 	}
 
-	_, err = conn.Read(buffer)
-	if err != nil {
-		return 0, err
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no server found")
 	}
+	return servers, nil
+}
 
-	xmlData, err := focusritexml.SplitLenXML(string(buffer))
-	if err != nil {
-		return 0, err
+// SelectServer returns the server with the given hostname or the first one if hostname is empty
+func SelectServer(servers []DiscoveredServer, hostname string) (DiscoveredServer, error) {
+	if len(servers) == 0 {
+		return DiscoveredServer{}, fmt.Errorf("no server found")
+	}
+	if hostname == "" {
+		return servers[0], nil
 	}
+	for _, s := range servers {
+		if s.Hostname == hostname {
+			return s, nil
+		}
+	}
+	return DiscoveredServer{}, fmt.Errorf("no server with hostname %s found", hostname)
+}
 
-	var announcement ServerAnnouncement
-	err = xml.Unmarshal([]byte(xmlData), &announcement)
+func parseAnnouncement(data []byte) (focusritexml.ServerAnnouncement, error) {
+	msg, err := focusritexml.ParseFromXML(string(data))
 	if err != nil {
-		return 0, err
+		return focusritexml.ServerAnnouncement{}, err
+	}
+
+	announcement, ok := msg.(focusritexml.ServerAnnouncement)
+	if !ok {
+		return announcement, fmt.Errorf("unexpected %T", msg)
 	}
+	return announcement, nil
+}
 
-	return announcement.Port, nil
+// the same server answers on loopback and on the broadcast address
+func containsServer(list []DiscoveredServer, server DiscoveredServer) bool {
+	for _, s := range list {
+		if s.Hostname == server.Hostname && s.Port == server.Port {
+			return true
+		}
+	}
+	return false
 }
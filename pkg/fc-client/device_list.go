@@ -11,6 +11,14 @@ func (dl DeviceList) GetDevice(id int) (*focusritexml.Device, bool) {
 	return d, ok
 }
 
+func (dl DeviceList) IDs() []int {
+	ids := make([]int, 0, len(dl))
+	for id := range dl {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (dl DeviceList) GetDeviceBySerialnumber(serial string) (*focusritexml.Device, bool) {
 	for _, v := range dl {
 		if v.SerialNumber == serial {
@@ -1,9 +1,13 @@
 package focusriteclient
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -22,7 +26,6 @@ const (
 )
 
 const (
-	SERVER_IP        string        = "localhost"
 	KEEP_ALIVE_TIME  time.Duration = 3 * time.Second
 	RECONNECT_TIME_S time.Duration = 5 * time.Second
 
@@ -31,6 +34,13 @@ const (
 
 type FocusriteClientMode int
 
+// ServerConfig selects the Focusrite Control server to connect to
+type ServerConfig struct {
+	Host     string // empty: discover on local machine and subnet
+	Port     int    // 0: discover port on Host
+	Hostname string // pick discovered server by announced hostname
+}
+
 const (
 	UpdateDevice FocusriteClientMode = iota
 	UpdateRaw
@@ -41,32 +51,46 @@ const (
 type FocusriteClient struct {
 	connectionMutex sync.Mutex
 	state           State
+	server          ServerConfig
+	host            string
 	port            int
 	connection      net.Conn
 	isConnected     bool
 
-	DeviceList    DeviceList
+	discoveryMutex    sync.Mutex
+	discoveredServers []DiscoveredServer
+
+	deviceMutex   sync.Mutex // guards DeviceList and the device models, subscribers are notified outside of it
+	DeviceList    DeviceList // read it with WithDevice while the client runs
 	ClientDetails focusritexml.ClientDetails
 
 	ToFocusrite   chan focusritexml.Set
 	FromFocusrite chan interface{}
 
-	sendMutex sync.Mutex
-	sendQueue map[int]focusritexml.Set
+	sendMutex  sync.Mutex // guards sendQueue only, it's never held while sending
+	sendQueue  map[int]focusritexml.Set
+	flushMutex sync.Mutex // keeps the flushed sets in order
 
 	Mode FocusriteClientMode
+
+	ctx           context.Context
+	cancel        context.CancelFunc
+	workerWg      sync.WaitGroup // keep alive, command handling and send queue
+	connectionWg  sync.WaitGroup // connection handling
+	lifecycleLock sync.Mutex
 }
 
 // NewFocusriteClient erstellt einen neuen FocusriteClient.
-func NewFocusriteClient(mode FocusriteClientMode) *FocusriteClient {
+func NewFocusriteClient(mode FocusriteClientMode, server ServerConfig) *FocusriteClient {
 	f := &FocusriteClient{
 		connectionMutex: sync.Mutex{},
 
-		state: Discover,
+		state:  Discover,
+		server: server,
 
 		ClientDetails: focusritexml.ClientDetails{
 			Hostname:  "Monitor Controller",
-			ClientKey: "123456789",
+			ClientKey: NewClientKey(),
 		},
 		DeviceList: make(DeviceList),
 
@@ -78,26 +102,110 @@ func NewFocusriteClient(mode FocusriteClientMode) *FocusriteClient {
 		sendMutex: sync.Mutex{},
 		sendQueue: make(map[int]focusritexml.Set),
 	}
-	go f.runConnection()
-	go f.runKeepalive()
-	go f.runCommandHandling()
-	go f.runSendQueue()
-
 	return f
 }
 
+// NewClientKey generates a random client key. Focusrite Control remembers the approval per key,
+// so it should be generated once per installation and stored.
+func NewClientKey() string {
+	var b [8]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		log.Errorf("client key generation failed: %s", err.Error())
+	}
+	return strconv.FormatUint(binary.BigEndian.Uint64(b[:]), 10)
+}
+
+// SetClientIdentity sets the stored client key and the client id assigned by the server on a previous run.
+// Must be called before Start.
+func (fc *FocusriteClient) SetClientIdentity(clientKey string, clientId string) {
+	if clientKey != "" {
+		fc.ClientDetails.ClientKey = clientKey
+	}
+	fc.ClientDetails.Id = clientId
+}
+
 // Start stellt eine Verbindung zum Focusrite-Server her und empfängt Daten.
+// The client runs until ctx is cancelled or Close is called.
+func (fc *FocusriteClient) Start(ctx context.Context) error {
+	fc.lifecycleLock.Lock()
+	defer fc.lifecycleLock.Unlock()
+
+	if fc.ctx != nil {
+		return fmt.Errorf("focusrite client already started")
+	}
+	fc.ctx, fc.cancel = context.WithCancel(ctx)
+	context.AfterFunc(fc.ctx, fc.unblockConnection)
+
+	fc.connectionWg.Add(1)
+	go fc.runConnection()
+
+	fc.workerWg.Add(3)
+	go fc.runKeepalive()
+	go fc.runCommandHandling()
+	go fc.runSendQueue()
+
+	return nil
+}
+
+// Close unsubscribes from all devices, flushes the send queue, closes the connection
+// and waits for all goroutines to exit. FromFocusrite is closed afterwards.
+// A closed client can't be started again.
+func (fc *FocusriteClient) Close() error {
+	fc.lifecycleLock.Lock()
+	defer fc.lifecycleLock.Unlock()
+
+	if fc.ctx == nil {
+		return fmt.Errorf("focusrite client not started")
+	}
+	if fc.cancel == nil {
+		return nil // already closed
+	}
+
+	fc.cancel()
+	fc.cancel = nil
+
+	fc.connectionWg.Wait()
+	fc.workerWg.Wait()
+	close(fc.FromFocusrite)
+
+	log.Debugf("Focusrite client closed")
+	return nil
+}
+
+// unblockConnection lets a pending read of the connection return
+func (fc *FocusriteClient) unblockConnection() {
+	fc.connectionMutex.Lock()
+	defer fc.connectionMutex.Unlock()
+	if fc.connection != nil {
+		err := fc.connection.SetReadDeadline(time.Now())
+		if err != nil {
+			log.Debugf("set read deadline: %s", err.Error())
+		}
+	}
+}
+
+// publish forwards a message to FromFocusrite, messages are dropped while shutting down
+func (fc *FocusriteClient) publish(msg interface{}) {
+	select {
+	case fc.FromFocusrite <- msg:
+	case <-fc.ctx.Done():
+	}
+}
+
 func (fc *FocusriteClient) runConnection() {
-	for {
+	defer fc.connectionWg.Done()
+
+	for fc.ctx.Err() == nil {
 		switch fc.state {
 		case Discover:
-			p, err := DiscoverServer()
+			err := fc.discover()
 			if err != nil {
 				log.Warn(err.Error())
 				fc.state = Waiting
+				continue
 			}
-			log.Infof("Port discovered: %d", fc.port)
-			fc.port = p
+			log.Infof("Server discovered: %s:%d", fc.host, fc.port)
 			fc.state = Connected
 
 		case Connected:
@@ -110,82 +218,158 @@ func (fc *FocusriteClient) runConnection() {
 			fc.state = Waiting
 
 		case Waiting:
-			time.Sleep(RECONNECT_TIME_S)
+			select {
+			case <-fc.ctx.Done():
+			case <-time.After(RECONNECT_TIME_S):
+			}
 			fc.state = Discover
 		}
 	}
 }
 
+// discover sets host and port of the server to connect to
+func (fc *FocusriteClient) discover() error {
+	if fc.server.Host != "" && fc.server.Port != 0 {
+		fc.host = fc.server.Host
+		fc.port = fc.server.Port
+		return nil
+	}
+
+	servers, err := DiscoverServers(fc.server.Host, DISCOVERY_TIME)
+	fc.setDiscoveredServers(servers)
+	if err != nil {
+		return err
+	}
+
+	server, err := SelectServer(servers, fc.server.Hostname)
+	if err != nil {
+		return err
+	}
+	fc.host = server.Address
+	fc.port = server.Port
+	return nil
+}
+
+func (fc *FocusriteClient) setDiscoveredServers(servers []DiscoveredServer) {
+	fc.discoveryMutex.Lock()
+	fc.discoveredServers = servers
+	fc.discoveryMutex.Unlock()
+	fc.publish(DiscoveryMessage(servers))
+}
+
+// DiscoveredServers returns all servers found by the last discovery
+func (fc *FocusriteClient) DiscoveredServers() []DiscoveredServer {
+	fc.discoveryMutex.Lock()
+	defer fc.discoveryMutex.Unlock()
+	return append([]DiscoveredServer{}, fc.discoveredServers...)
+}
+
 func (fc *FocusriteClient) runKeepalive() {
+	defer fc.workerWg.Done()
+
 	t := time.NewTicker(KEEP_ALIVE_TIME)
 	defer t.Stop()
 
-	for range t.C {
-		if fc.isConnected {
-			err := fc.sendXML(focusritexml.KeepAlive{})
-			if err != nil {
-				log.Error(err.Error())
+	for {
+		select {
+		case <-fc.ctx.Done():
+			return
+		case <-t.C:
+			if fc.Connected() {
+				err := fc.sendXML(focusritexml.KeepAlive{})
+				if err != nil {
+					log.Error(err.Error())
+				}
 			}
 		}
 	}
 }
 
 func (fc *FocusriteClient) runCommandHandling() {
+	defer fc.workerWg.Done()
 
-	for set := range fc.ToFocusrite {
-		if set.DevID != 0 && len(set.Items) > 0 {
-			fc.sendMutex.Lock()
-			q, ok := fc.sendQueue[set.DevID]
-			if !ok { //new set to send
-				fc.sendQueue[set.DevID] = set
-			} else {
-				for _, newItem := range set.Items {
-					updated := false
-					for qItemId, qItem := range q.Items {
-						//set contains Item --> Update Item
-						if qItem.ID == newItem.ID {
-							log.Debugf("Updating Value: %d from %s to %s", qItem.ID, qItem.Value, newItem.Value)
-							q.Items[qItemId].Value = newItem.Value
-							updated = true
-						}
-					}
-					if !updated {
-						q.Items = append(q.Items, newItem)
-					}
-				}
+	for {
+		select {
+		case <-fc.ctx.Done():
+			return
+		case set := <-fc.ToFocusrite:
+			fc.queueSet(set)
+		}
+	}
+}
+
+func (fc *FocusriteClient) queueSet(set focusritexml.Set) {
+	if set.DevID == 0 || len(set.Items) == 0 {
+		return
+	}
+
+	fc.sendMutex.Lock()
+	defer fc.sendMutex.Unlock()
+
+	q, ok := fc.sendQueue[set.DevID]
+	if !ok { //new set to send
+		fc.sendQueue[set.DevID] = set
+		return
+	}
+
+	for _, newItem := range set.Items {
+		updated := false
+		for qItemId, qItem := range q.Items {
+			//set contains Item --> Update Item
+			if qItem.ID == newItem.ID {
+				log.Debugf("Updating Value: %d from %s to %s", qItem.ID, qItem.Value, newItem.Value)
+				q.Items[qItemId].Value = newItem.Value
+				updated = true
 			}
-			fc.sendMutex.Unlock()
+		}
+		if !updated {
+			q.Items = append(q.Items, newItem)
 		}
 	}
+	fc.sendQueue[set.DevID] = q
 }
 
 func (fc *FocusriteClient) runSendQueue() {
+	defer fc.workerWg.Done()
 
 	t := time.NewTicker(FC_SEND_INTERVAL)
-	for range t.C {
-		fc.sendMutex.Lock()
-		for qID, q := range fc.sendQueue {
-			if len(q.Items) >= 0 || q.DevID != 0 {
-
-				log.Debugf("Sending to Focusrite %d items\n", len(q.Items))
-				err := fc.sendSet(q)
-				if err != nil {
-					log.Error(err)
-				}
-				//reset Buffer
-				delete(fc.sendQueue, qID)
+	defer t.Stop()
 
-			}
+	for {
+		select {
+		case <-fc.ctx.Done():
+			return
+		case <-t.C:
+			fc.flushSendQueue()
 		}
-		fc.sendMutex.Unlock()
+	}
+}
+
+// flushSendQueue sends the queued sets. The subscribers of the device are notified while sending,
+// so the queue is taken first and new sets can be queued meanwhile.
+func (fc *FocusriteClient) flushSendQueue() {
+	fc.flushMutex.Lock()
+	defer fc.flushMutex.Unlock()
 
+	fc.sendMutex.Lock()
+	queue := fc.sendQueue
+	fc.sendQueue = make(map[int]focusritexml.Set)
+	fc.sendMutex.Unlock()
+
+	for _, q := range queue {
+		log.Debugf("Sending to Focusrite %d items\n", len(q.Items))
+		err := fc.sendSet(q)
+		if err != nil {
+			log.Error(err)
+		}
 	}
 }
 
 // connectAndListen stellt die Verbindung her und verarbeitet eingehende Daten.
 func (fc *FocusriteClient) connectAndListen() error {
 
-	conn, err := net.Dial("tcp4", fmt.Sprintf("%s:%d", SERVER_IP, fc.port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(fc.ctx, "tcp4", net.JoinHostPort(fc.host, strconv.Itoa(fc.port)))
 	if err != nil {
 		return err
 	}
@@ -193,23 +377,63 @@ func (fc *FocusriteClient) connectAndListen() error {
 
 	fc.setConnected(true)
 	fc.setConnection(conn)
+	if fc.ctx.Err() != nil {
+		return fc.ctx.Err()
+	}
+
 	err = fc.SendClientDetails()
 	if err != nil {
 		return err
 	}
 
+	decoder := focusritexml.NewFrameDecoder()
+	buf := make([]byte, 65536)
 	for {
-		buf := make([]byte, 65536)
 		n, err := conn.Read(buf)
+		if n > 0 {
+			decoder.Write(buf[:n])
+			for {
+				packet, ok := decoder.Next()
+				if !ok {
+					break
+				}
+				fc.handleXmlPacket(packet)
+			}
+		}
+		if fc.ctx.Err() != nil {
+			fc.disconnect()
+			return fc.ctx.Err()
+		}
 		if err == io.EOF {
-			continue
+			return fmt.Errorf("connection closed by server")
 		}
 		if err != nil {
 			return err
 		}
-		packet := string(buf[:n])
-		if packet != "" {
-			fc.handleXmlPacket(packet)
+	}
+}
+
+// disconnect sends all queued sets and unsubscribes from all devices before closing the connection
+func (fc *FocusriteClient) disconnect() {
+	fc.workerWg.Wait()
+
+	for pending := true; pending; {
+		select {
+		case set := <-fc.ToFocusrite:
+			fc.queueSet(set)
+		default:
+			pending = false
+		}
+	}
+	fc.flushSendQueue()
+
+	fc.deviceMutex.Lock()
+	ids := fc.DeviceList.IDs()
+	fc.deviceMutex.Unlock()
+	for _, id := range ids {
+		err := fc.SendSubscribe(id, false)
+		if err != nil {
+			log.Debugf("unsubscribe device %d: %s", id, err.Error())
 		}
 	}
 }
@@ -218,47 +442,72 @@ func (fc *FocusriteClient) handleXmlPacket(packet string) {
 	d, err := focusritexml.ParseFromXML(packet)
 	if err != nil {
 		log.Errorln(err.Error())
+		return
 	}
 
 	switch dd := d.(type) {
 	case focusritexml.Set:
+		fc.deviceMutex.Lock()
 		device, ok := fc.DeviceList.GetDevice(dd.DevID)
 		if !ok {
+			fc.deviceMutex.Unlock()
 			log.Warningf("Unknown device to Update with ID: %d (%d Items)\n", dd.DevID, len(dd.Items))
 			return
 		}
+		// the device model is always updated, subscribers of the device rely on it
+		_, changes := device.ApplySet(dd)
+		update := *device
+		fc.deviceMutex.Unlock()
+		device.Notify(changes)
+
 		if fc.Mode == UpdateDevice || fc.Mode == UpdateBoth {
-			fc.DeviceList.UpdateSet(dd)
-			fc.FromFocusrite <- DeviceUpdateMessage(*device)
+			fc.publish(DeviceUpdateMessage(update))
 		}
 		if fc.Mode == UpdateRaw || fc.Mode == UpdateBoth {
-			fc.FromFocusrite <- RawUpdateMessage(dd)
+			fc.publish(RawUpdateMessage(dd))
 		}
 		return
 
 	case focusritexml.DeviceArrival:
+		fc.deviceMutex.Lock()
 		device := fc.DeviceList.AddDevice(&dd.Device)
 		device.UpdateMap()
-		err := fc.SendSubscribe(device.ID, true)
+		arrival := *device
+		fc.deviceMutex.Unlock()
+
+		err := fc.SendSubscribe(arrival.ID, true)
 		if err != nil {
 			log.Error(err.Error())
 		}
-		fc.FromFocusrite <- DeviceArrivalMessage(*device)
+		fc.publish(DeviceArrivalMessage(arrival))
 		log.Infof("New Device: %s, with ID: %d \n", dd.Device.Model, dd.Device.ID)
 		return
 
 	case focusritexml.DeviceRemoval:
-		fc.FromFocusrite <- DeviceRemovalMessage(dd.Id)
+		fc.publish(DeviceRemovalMessage(dd.Id))
+		fc.deviceMutex.Lock()
 		fc.DeviceList.Remove(dd.Id)
+		fc.deviceMutex.Unlock()
 		return
 
 	case focusritexml.ClientDetails:
-		fc.ClientDetails.Id = dd.Id
 		log.Debugf("New Cleint Details: %s, with ID: %s \n", dd.ClientKey, dd.Id)
+		if dd.Id != "" && dd.Id != fc.ClientDetails.Id {
+			fc.ClientDetails.Id = dd.Id
+			fc.publish(ClientIdMessage(dd.Id))
+		}
 		return
 
 	case focusritexml.Approval:
-		fc.FromFocusrite <- ApprovalMessasge(dd.Authorised)
+		fc.publish(ApprovalMessasge(dd.Authorised))
+		return
+
+	case focusritexml.ErrorMessage:
+		log.Errorf("Focusrite Control error %s: %s", dd.Code, dd.Message)
+		return
+
+	case focusritexml.UnknownMessage:
+		log.Debugf("ignoring unknown message %s: %s", dd.Name, dd.Raw)
 		return
 
 	//Ignoring
@@ -284,9 +533,10 @@ func (fc *FocusriteClient) SendSubscribe(id int, subscribe bool) error {
 // setConnected aktualisiert den Verbindungsstatus.
 func (fc *FocusriteClient) setConnected(status bool) {
 	fc.connectionMutex.Lock()
-	defer fc.connectionMutex.Unlock()
 	fc.isConnected = status
-	fc.FromFocusrite <- ConnectionStatusMessage(status)
+	fc.connectionMutex.Unlock()
+
+	fc.publish(ConnectionStatusMessage(status))
 }
 
 // setConnected aktualisiert den Verbindungsstatus.
@@ -323,6 +573,32 @@ func (fc *FocusriteClient) sendXML(data interface{}) error {
 	return nil
 }
 
+// WithDevice calls f with the model of a device while the client doesn't update it, it returns false for unknown devices.
+// f must not keep the device or call the client.
+func (fc *FocusriteClient) WithDevice(id int, f func(*focusritexml.Device)) bool {
+	fc.deviceMutex.Lock()
+	defer fc.deviceMutex.Unlock()
+	d, ok := fc.DeviceList.GetDevice(id)
+	if ok {
+		f(d)
+	}
+	return ok
+}
+
+// SendSet sends a set right away instead of queueing it, e.g. for tools which need to know if it was sent
+func (fc *FocusriteClient) SendSet(set focusritexml.Set) error {
+	fc.flushMutex.Lock()
+	defer fc.flushMutex.Unlock()
+
+	fc.deviceMutex.Lock()
+	_, ok := fc.DeviceList.GetDevice(set.DevID)
+	fc.deviceMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown device %d", set.DevID)
+	}
+	return fc.sendSet(set)
+}
+
 func (fc *FocusriteClient) sendSet(set focusritexml.Set) error {
 
 	var cleanSet []focusritexml.Item
@@ -334,10 +610,15 @@ func (fc *FocusriteClient) sendSet(set focusritexml.Set) error {
 	}
 	set.Items = cleanSet
 
+	fc.deviceMutex.Lock()
 	dev, ok := fc.DeviceList.GetDevice(set.DevID)
-	if ok && len(set.Items) > 0 {
-		dev.UpdateSet(set)
-		return fc.sendXML(set)
+	if !ok || len(set.Items) == 0 {
+		fc.deviceMutex.Unlock()
+		return nil
 	}
-	return nil
+	_, changes := dev.ApplySet(set)
+	fc.deviceMutex.Unlock()
+
+	dev.Notify(changes)
+	return fc.sendXML(set)
 }
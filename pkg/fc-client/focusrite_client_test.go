@@ -0,0 +1,186 @@
+package focusriteclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	focusritesimulator "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-simulator"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+const testNickname int = 1256 // nickname of input Analogue 1 of the 18i20 fixture
+
+func startClient(t *testing.T) (*FocusriteClient, *focusritesimulator.Server, *focusritexml.Device) {
+	t.Helper()
+
+	simCfg := focusritesimulator.DefaultConfig()
+	simCfg.DiscoveryPorts = nil
+	sim := focusritesimulator.NewServer(simCfg)
+	if err := sim.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sim.Close() })
+	if _, err := sim.AddDeviceFromFile("../../xml/device-arrival-18i20.xml"); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := NewFocusriteClient(UpdateRaw, ServerConfig{Host: "127.0.0.1", Port: sim.Port()})
+	if err := fc.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case msg := <-fc.FromFocusrite:
+			if arrival, ok := msg.(DeviceArrivalMessage); ok {
+				device, _ := fc.DeviceList.GetDevice(arrival.ID)
+				return fc, sim, device
+			}
+		case <-timeout:
+			t.Fatal("no device arrival")
+		}
+	}
+}
+
+// a subscriber blocking on a set sent by the client must not block queueing new sets
+func TestBlockingSubscriberDoesNotBlockQueue(t *testing.T) {
+	fc, _, device := startClient(t)
+
+	go func() {
+		for range fc.FromFocusrite {
+		}
+	}()
+
+	release := make(chan struct{})
+	called := make(chan struct{}, 1)
+	device.Subscribe(testNickname, func(change focusritexml.ElementChange) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+
+	set := focusritexml.NewSet(device.ID)
+	set.AddItemString(testNickname, "Kick")
+	fc.ToFocusrite <- *set
+
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber not called")
+	}
+
+	// more sets than ToFocusrite buffers, they are queued while the subscriber blocks
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*cap(fc.ToFocusrite); i++ {
+			set := focusritexml.NewSet(device.ID)
+			set.AddItemInt(testNickname+1, i)
+			fc.ToFocusrite <- *set
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ToFocusrite blocked by the subscriber")
+	}
+
+	close(release)
+	fc.Close()
+}
+
+func TestSendSetReportsUnsentSets(t *testing.T) {
+	fc, _, device := startClient(t)
+	go func() {
+		for range fc.FromFocusrite {
+		}
+	}()
+
+	set := focusritexml.NewSet(device.ID)
+	set.AddItemString(testNickname, "Kick")
+	if err := fc.SendSet(*set); err != nil {
+		t.Fatalf("send while connected: %v", err)
+	}
+
+	unknown := focusritexml.NewSet(device.ID + 100)
+	unknown.AddItemString(testNickname, "Kick")
+	if err := fc.SendSet(*unknown); err == nil {
+		t.Fatal("set of an unknown device sent")
+	}
+
+	fc.Close()
+	if err := fc.SendSet(*set); err == nil {
+		t.Fatal("set sent after close")
+	}
+}
+
+// the device model is updated with the values of the server on the connection goroutine and the values sent
+// on the send queue goroutine, the subscribers are notified on both, run with -race
+func TestSubscribersNotifiedFromBothGoroutines(t *testing.T) {
+	fc, sim, device := startClient(t)
+	go func() {
+		for range fc.FromFocusrite {
+		}
+	}()
+	gain := device.Outputs.Analogues[0].Gain.ID
+
+	// without subscribers nothing else orders the updates of both goroutines for the race detector
+	const updates = 100
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			set := focusritexml.NewSet(device.ID)
+			set.AddItemInt(gain, -i)
+			sim.SendSet(*set)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			set := focusritexml.NewSet(device.ID)
+			set.AddItemString(testNickname, fmt.Sprintf("Kick %d", i))
+			set.AddItemInt(gain, -50-i)
+			fc.ToFocusrite <- *set
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	notified := make(chan string, 10)
+	device.SubscribeIds([]int{gain, testNickname}, func(change focusritexml.ElementChange) {
+		select {
+		case notified <- fmt.Sprint(change.New):
+		default:
+		}
+	})
+	set := focusritexml.NewSet(device.ID)
+	set.AddItemInt(gain, -10)
+	sim.SendSet(*set)
+	set = focusritexml.NewSet(device.ID)
+	set.AddItemString(testNickname, "Snare")
+	fc.ToFocusrite <- *set
+
+	got := make(map[string]bool)
+	for len(got) < 2 {
+		select {
+		case v := <-notified:
+			got[v] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("notified %v", got)
+		}
+	}
+	if !got["-10"] || !got["Snare"] {
+		t.Fatalf("notified %v", got)
+	}
+	fc.Close()
+}
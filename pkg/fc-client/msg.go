@@ -5,7 +5,9 @@ import (
 )
 
 type ApprovalMessasge bool
+type ClientIdMessage string // id assigned by Focusrite Control, store and reuse it on next start
 type ConnectionStatusMessage bool
+type DiscoveryMessage []DiscoveredServer
 
 type DeviceArrivalMessage focusritexml.Device
 type DeviceRemovalMessage int
@@ -18,7 +20,9 @@ go func() {
 		for msg := range fc.FromFocusrite {
 			switch msg.(type) {
 			case focusriteclient.ApprovalMessasge:
+			case focusriteclient.ClientIdMessage:
 			case focusriteclient.ConnectionStatusMessage:
+			case focusriteclient.DiscoveryMessage:
 			case focusriteclient.DeviceArrivalMessage:
 			case focusriteclient.DeviceRemovalMessage:
 			case focusriteclient.DeviceUpdateMessage:
@@ -1,28 +1,186 @@
 package fcaudioconnector
 
-import "github.com/sebastianrau/focusrite-mackie-control/pkg/monitorcontroller"
+import (
+	"fmt"
+	"sync"
+
+	focusriteclient "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-client"
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/monitorcontroller"
+)
 
 type FocusriteId int
 
+const (
+	PATH_HW_MUTE       string = "monitoring/hardware-controls/hardware-controls/mute"
+	PATH_HW_DIM        string = "monitoring/hardware-controls/hardware-controls/dim"
+	PATH_HW_GAIN       string = "monitoring/hardware-controls/hardware-controls/gain"
+	PATH_HW_ALT_ENABLE string = "monitoring/hardware-controls/hardware-controls/alt-enable"
+	PATH_HW_ALT        string = "monitoring/hardware-controls/hardware-controls/alt"
+)
+
+// channel names of the common layouts, other layouts are numbered
+var channelLayouts map[int][]string = map[int][]string{
+	1: {"M"},
+	2: {"L", "R"},
+	6: {"L", "R", "C", "LFE", "Ls", "Rs"},
+	8: {"L", "R", "C", "LFE", "Ls", "Rs", "Lb", "Rb"},
+}
+
+// ChannelNames returns the channel names of a speaker set with n channels
+func ChannelNames(n int) []string {
+	if names, ok := channelLayouts[n]; ok {
+		return names
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d", i+1)
+	}
+	return names
+}
+
+// SpeakerChannelFcConfig holds the elements of one analogue output
+type SpeakerChannelFcConfig struct {
+	Name       string
+	Mute       FocusriteElement
+	OutputGain FocusriteElement
+	Meter      FocusriteElement
+}
+
 type SpeakerFcConfig struct {
-	Name       FocusriteId
-	Mute       FocusriteId
-	OutputGain FocusriteId
-	MeterL     FocusriteId
-	MeterR     FocusriteId
+	Name     FocusriteElement
+	Channels []SpeakerChannelFcConfig // mono, stereo (L, R) or multichannel (L, R, C, LFE, Ls, Rs ...)
+}
+
+// ChannelNames returns the configured channel names, missing names are taken from the layout
+func (s *SpeakerFcConfig) ChannelNames() []string {
+	layout := ChannelNames(len(s.Channels))
+	names := make([]string, len(s.Channels))
+	for i, ch := range s.Channels {
+		names[i] = ch.Name
+		if names[i] == "" {
+			names[i] = layout[i]
+		}
+	}
+	return names
+}
+
+// MeterIds returns the meters of the first two channels as left and right level, both are the same for mono speakers
+func (s *SpeakerFcConfig) MeterIds() (FocusriteId, FocusriteId) {
+	switch len(s.Channels) {
+	case 0:
+		return 0, 0
+	case 1:
+		return s.Channels[0].Meter.ID, s.Channels[0].Meter.ID
+	default:
+		return s.Channels[0].Meter.ID, s.Channels[1].Meter.ID
+	}
+}
+
+// configs of older versions have one mute and gain and two meters per speaker,
+// the mute and gain of the right channel are completed by Resolve.
+func (s *SpeakerFcConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cfg struct {
+		Name     FocusriteElement
+		Channels []SpeakerChannelFcConfig
+
+		Mute       FocusriteElement
+		OutputGain FocusriteElement
+		MeterL     FocusriteElement
+		MeterR     FocusriteElement
+	}
+	err := unmarshal(&cfg)
+	if err != nil {
+		return err
+	}
+
+	s.Name = cfg.Name
+	s.Channels = cfg.Channels
+	if len(s.Channels) == 0 && (cfg.Mute.IsSet() || cfg.OutputGain.IsSet() || cfg.MeterL.IsSet()) {
+		s.Channels = []SpeakerChannelFcConfig{
+			{Mute: cfg.Mute, OutputGain: cfg.OutputGain, Meter: cfg.MeterL},
+		}
+		if cfg.MeterR.IsSet() && cfg.MeterR != cfg.MeterL {
+			s.Channels = append(s.Channels, SpeakerChannelFcConfig{Meter: cfg.MeterR})
+		}
+	}
+	return nil
+}
+
+func analogueSpeaker(outputs ...string) *SpeakerFcConfig {
+	spk := &SpeakerFcConfig{
+		Name:     FocusriteElement{Path: fmt.Sprintf(`outputs/analogue[name=%q]/nickname`, outputs[0])},
+		Channels: make([]SpeakerChannelFcConfig, 0, len(outputs)),
+	}
+	names := ChannelNames(len(outputs))
+	for i, out := range outputs {
+		spk.Channels = append(spk.Channels, SpeakerChannelFcConfig{
+			Name:       names[i],
+			Mute:       FocusriteElement{Path: fmt.Sprintf(`outputs/analogue[name=%q]/mute`, out)},
+			OutputGain: FocusriteElement{Path: fmt.Sprintf(`outputs/analogue[name=%q]/gain`, out)},
+			Meter:      FocusriteElement{Path: fmt.Sprintf(`outputs/analogue[name=%q]/meter`, out)},
+		})
+	}
+	return spk
 }
 
 type MasterFcConfig struct {
-	MuteSwitch FocusriteId
-	DimSwitch  FocusriteId
+	MuteSwitch FocusriteElement
+	DimSwitch  FocusriteElement
+	VolumeKnob FocusriteElement
+	AltEnable  FocusriteElement
+	AltSwitch  FocusriteElement
 }
 
 type FcConfiguration struct {
 	Speaker map[monitorcontroller.SpeakerID]*SpeakerFcConfig
 	Master  *MasterFcConfig
 
+	// speakers selected by the hardware ALT button: MainSpeaker with ALT off, AltSpeaker with ALT on.
+	// ALT is not used if one of them is empty.
+	MainSpeaker []monitorcontroller.SpeakerID
+	AltSpeaker  []monitorcontroller.SpeakerID
+
+	Ramp *RampConfig
+
 	FocusriteSerialNumber string
 	FocusriteDeviceId     int `yaml:"-"`
+
+	FocusriteHost     string // empty: discover Focusrite Control on this machine and in the subnet
+	FocusritePort     int    // 0: discover port
+	FocusriteHostname string // select server by hostname if several are found
+
+	ClientKey string // generated once per installation, Focusrite Control approves the app per key
+	ClientId  string // assigned by Focusrite Control
+
+	locker sync.Locker // taken while the connector writes, see SetLocker
+}
+
+// SetLocker sets the lock the connector takes while it writes to the configuration,
+// e.g. the lock of the config file saving the configuration concurrently
+func (c *FcConfiguration) SetLocker(l sync.Locker) {
+	c.locker = l
+}
+
+// update runs f with the lock set by SetLocker
+func (c *FcConfiguration) update(f func()) {
+	if c.locker != nil {
+		c.locker.Lock()
+		defer c.locker.Unlock()
+	}
+	f()
+}
+
+// UseAlt returns true if the hardware ALT button switches speakers
+func (c *FcConfiguration) UseAlt() bool {
+	return c.Master != nil && c.Master.AltSwitch.ID != 0 && len(c.MainSpeaker) > 0 && len(c.AltSpeaker) > 0
+}
+
+func (c *FcConfiguration) ServerConfig() focusriteclient.ServerConfig {
+	return focusriteclient.ServerConfig{
+		Host:     c.FocusriteHost,
+		Port:     c.FocusritePort,
+		Hostname: c.FocusriteHostname,
+	}
 }
 
 func DefaultConfiguration() *FcConfiguration {
@@ -30,46 +188,23 @@ func DefaultConfiguration() *FcConfiguration {
 
 		Speaker: map[monitorcontroller.SpeakerID]*SpeakerFcConfig{
 
-			monitorcontroller.SpeakerA: {
-				Name:       1456,
-				Mute:       1453,
-				OutputGain: 1458,
-				MeterL:     1450,
-				MeterR:     1460,
-			},
-			monitorcontroller.SpeakerB: {
-				Name:       1476,
-				Mute:       1473,
-				OutputGain: 1478,
-				MeterL:     1470,
-				MeterR:     1480,
-			},
-			monitorcontroller.SpeakerC: {
-				Name:       1496,
-				Mute:       1493,
-				OutputGain: 1498,
-				MeterL:     1490,
-				MeterR:     1500,
-			},
-			monitorcontroller.SpeakerD: {
-				Name:       1516,
-				Mute:       1513,
-				OutputGain: 1518,
-				MeterL:     1510,
-				MeterR:     1520,
-			},
-			monitorcontroller.Sub: {
-				Name:       1536,
-				Mute:       1533,
-				OutputGain: 1538,
-				MeterL:     1530,
-				MeterR:     1540,
-			},
+			monitorcontroller.SpeakerA: analogueSpeaker("Monitor Output 1", "Monitor Output 2"),
+			monitorcontroller.SpeakerB: analogueSpeaker("Line Output 3", "Line Output 4"),
+			monitorcontroller.SpeakerC: analogueSpeaker("Line Output 5", "Line Output 6"),
+			monitorcontroller.SpeakerD: analogueSpeaker("Line Output 7", "Line Output 8"),
+			monitorcontroller.Sub:      analogueSpeaker("Line Output 9", "Line Output 10"),
 		},
 		Master: &MasterFcConfig{
-			MuteSwitch: 1679,
-			DimSwitch:  1678,
+			MuteSwitch: FocusriteElement{Path: PATH_HW_MUTE},
+			DimSwitch:  FocusriteElement{Path: PATH_HW_DIM},
+			VolumeKnob: FocusriteElement{Path: PATH_HW_GAIN},
+			AltEnable:  FocusriteElement{Path: PATH_HW_ALT_ENABLE},
+			AltSwitch:  FocusriteElement{Path: PATH_HW_ALT},
 		},
+		MainSpeaker:           []monitorcontroller.SpeakerID{monitorcontroller.SpeakerA},
+		AltSpeaker:            []monitorcontroller.SpeakerID{monitorcontroller.SpeakerB},
+		Ramp:                  DefaultRampConfig(),
 		FocusriteSerialNumber: "P9EAC6K250F325",
+		ClientKey:             focusriteclient.NewClientKey(),
 	}
 }
@@ -1,8 +1,10 @@
 package fcaudioconnector
 
 import (
+	"context"
 	"reflect"
 	"strconv"
+	"sync"
 
 	focusriteclient "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-client"
 	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
@@ -16,167 +18,411 @@ type AudioDeviceConnector struct {
 	device *focusriteclient.FocusriteClient
 	config *FcConfiguration
 
-	state        *monitorcontroller.ControllerSate
-	toController chan interface{}
+	// the state is changed by the messages of the client, the subscribed element changes and the controller
+	mu       sync.Mutex
+	state    *monitorcontroller.ControllerSate
+	approval monitorcontroller.ApprovalState
+	answered bool // the server answered the approval request of this connection
+
+	controllerChannel chan interface{}
+	toController      *controllerQueue
+
+	subscription *focusritexml.Subscription // dim, mute, gain and names of the configured device
+	echoes       *echoFilter
+	ramp         *ramp
+
+	done     chan struct{} // closed when the connector stops, sets to Focusrite Control are dropped from then on
+	stopOnce sync.Once
+	wg       sync.WaitGroup
 }
 
 func NewAudioDeviceConnector(cfg *FcConfiguration) *AudioDeviceConnector {
 	ad := &AudioDeviceConnector{
 		config: cfg,
-		state:  monitorcontroller.NewDefaultState(),
+		state: &monitorcontroller.ControllerSate{
+			Master:  monitorcontroller.NewDefaultState().Master,
+			Speaker: make(map[monitorcontroller.SpeakerID]*monitorcontroller.SpeakerState), // added by the speaker updates of the controller
+		},
+		toController: newControllerQueue(),
+		echoes:       newEchoFilter(ECHO_TIMEOUT),
+		ramp:         newRamp(),
+		done:         make(chan struct{}),
 	}
 
-	ad.device = focusriteclient.NewFocusriteClient(focusriteclient.UpdateRaw)
+	cfg.update(func() {
+		// configs from older versions have no key yet, it's saved with the next config save
+		if cfg.ClientKey == "" {
+			cfg.ClientKey = focusriteclient.NewClientKey()
+		}
+		// same for the hardware monitor controls
+		if cfg.Master != nil && !cfg.Master.VolumeKnob.IsSet() {
+			cfg.Master.VolumeKnob = FocusriteElement{Path: PATH_HW_GAIN}
+			cfg.Master.AltEnable = FocusriteElement{Path: PATH_HW_ALT_ENABLE}
+			cfg.Master.AltSwitch = FocusriteElement{Path: PATH_HW_ALT}
+		}
+		// same for the ramps
+		if cfg.Ramp == nil {
+			cfg.Ramp = DefaultRampConfig()
+		}
+	})
 
-	go ad.run()
+	ad.device = focusriteclient.NewFocusriteClient(focusriteclient.UpdateRaw, cfg.ServerConfig())
+	ad.device.SetClientIdentity(cfg.ClientKey, cfg.ClientId)
 
 	return ad
 }
 
+// Start connects to Focusrite Control and handles its messages until ctx is cancelled or Close is called
+func (ad *AudioDeviceConnector) Start(ctx context.Context) error {
+	err := ad.device.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	ad.wg.Add(2)
+	go ad.run()
+	go func() {
+		defer ad.wg.Done()
+		ad.toController.Run(ad.controllerChannel, ad.done)
+	}()
+	return nil
+}
+
+// Close disconnects from Focusrite Control and waits for the message handling to exit
+func (ad *AudioDeviceConnector) Close() error {
+	// the client doesn't read the sets anymore, a command of the controller must not wait for it
+	ad.stop()
+	err := ad.device.Close()
+	ad.wg.Wait()
+	return err
+}
+
+func (ad *AudioDeviceConnector) stop() {
+	ad.stopOnce.Do(func() { close(ad.done) })
+}
+
 func (ad *AudioDeviceConnector) run() {
+	defer ad.wg.Done()
+	defer ad.stop()
+
 	for msg := range ad.device.FromFocusrite {
-		switch m := msg.(type) {
-		case focusriteclient.DeviceArrivalMessage:
-			ad.handleFcDeviceArrivalMsg(focusritexml.Device(m))
-
-		case focusriteclient.DeviceRemovalMessage:
-			ad.handleFcDeviceRemovalMsg(int(m))
-
-		case focusriteclient.RawUpdateMessage:
-			ad.handleFcUpdateMsg(focusritexml.Set(m))
-
-		case focusriteclient.DeviceUpdateMessage:
-			log.Debugf("Got Device Upadte Message")
-			ad.toController <- monitorcontroller.AdSetDeviceStatus{
-				DeviceId:        m.ID,
-				Model:           m.Model,
-				SerialNumber:    m.SerialNumber,
-				SampleRate:      m.Clocking.SampleRate.Value,
-				ConnectionState: ad.device.Connected(),
-			}
+		ad.mu.Lock()
+		ad.handleFcMessage(msg)
+		ad.mu.Unlock()
+	}
+}
 
-		case focusriteclient.ApprovalMessasge:
-			if m {
-				log.Info("got positive approval from Focusrite Controller Server")
-			} else {
-				log.Warn("app has no approval from Focusrite Control.")
-			}
-			// TODO reflect on gui
-
-		case focusriteclient.ConnectionStatusMessage:
-			if !m { //connection to Fc Control Server lost
-				log.Debugf("Connection to Focusrite Device Control Server lost")
-				ad.config.FocusriteDeviceId = 0
-				ad.toController <- monitorcontroller.AdSetDeviceStatus{
-					DeviceId:        0,
-					ConnectionState: ad.device.Connected(),
-				}
-			}
+func (ad *AudioDeviceConnector) handleFcMessage(msg interface{}) {
+	switch m := msg.(type) {
+	case focusriteclient.DeviceArrivalMessage:
+		ad.handleFcDeviceArrivalMsg(focusritexml.Device(m))
 
-		default:
-			log.Warnf("unhadled %s message", reflect.TypeOf(msg).String())
+	case focusriteclient.DeviceRemovalMessage:
+		ad.handleFcDeviceRemovalMsg(int(m))
+
+	case focusriteclient.RawUpdateMessage:
+		ad.handleFcUpdateMsg(focusritexml.Set(m))
+
+	case focusriteclient.DeviceUpdateMessage:
+		log.Debugf("Got Device Upadte Message")
+		ad.toController.Push(monitorcontroller.AdSetDeviceStatus{
+			DeviceId:        m.ID,
+			Model:           m.Model,
+			SerialNumber:    m.SerialNumber,
+			SampleRate:      m.Clocking.SampleRate.Value,
+			ConnectionState: ad.device.Connected(),
+		})
+
+	case focusriteclient.ApprovalMessasge:
+		answered := ad.answered
+		ad.answered = true
+		if m {
+			log.Info("got positive approval from Focusrite Controller Server")
+			ad.setApproval(monitorcontroller.ApprovalApproved)
+		} else if ad.approval == monitorcontroller.ApprovalApproved {
+			log.Warn("approval revoked by Focusrite Control.")
+			ad.setApproval(monitorcontroller.ApprovalRevoked)
+		} else if !answered {
+			// the first answer of a connection without approval, the server waits for the user
+			log.Info("waiting for approval in Focusrite Control.")
+		} else {
+			log.Warn("app has no approval from Focusrite Control.")
+			ad.setApproval(monitorcontroller.ApprovalDenied)
+		}
+
+	case focusriteclient.ClientIdMessage:
+		log.Debugf("Focusrite Control assigned client id %s", string(m))
+		ad.config.update(func() { ad.config.ClientId = string(m) })
+
+	case focusriteclient.DiscoveryMessage:
+		log.Debugf("Discovered %d Focusrite Control server", len(m))
+
+	case focusriteclient.ConnectionStatusMessage:
+		if m {
+			// approval is requested again with every connection
+			ad.answered = false
+			ad.setApproval(monitorcontroller.ApprovalPending)
+		} else { //connection to Fc Control Server lost
+			log.Debugf("Connection to Focusrite Device Control Server lost")
+			ad.config.update(func() { ad.config.FocusriteDeviceId = 0 })
+			ad.unsubscribeDevice()
+			ad.toController.Push(monitorcontroller.AdSetDeviceStatus{
+				DeviceId:        0,
+				ConnectionState: ad.device.Connected(),
+			})
 		}
 
+	default:
+		log.Warnf("unhadled %s message", reflect.TypeOf(msg).String())
 	}
+}
 
+func (ad *AudioDeviceConnector) setApproval(approval monitorcontroller.ApprovalState) {
+	if ad.approval == approval {
+		return
+	}
+	ad.approval = approval
+	ad.toController.Push(monitorcontroller.AdSetApproval(approval))
 }
 
 func (ad *AudioDeviceConnector) SetControlChannel(controllerChannel chan interface{}) {
-	ad.toController = controllerChannel
+	ad.controllerChannel = controllerChannel
 }
 
 func (ad *AudioDeviceConnector) handleFcDeviceArrivalMsg(device focusritexml.Device) {
 	log.Debugf("New Focusrite Device Arrived ID:%d SN:%s", device.ID, device.SerialNumber)
+	// devices are only announced to approved clients
+	if ad.approval != monitorcontroller.ApprovalApproved {
+		ad.setApproval(monitorcontroller.ApprovalApproved)
+	}
 	if device.SerialNumber == ad.config.FocusriteSerialNumber {
 		log.Debugf("configured device with SN: %s arrived with ID ID:%d", device.SerialNumber, device.ID)
-		ad.config.FocusriteDeviceId = device.ID
+		var errs []error
+		ad.config.update(func() {
+			ad.config.FocusriteDeviceId = device.ID
+			errs = ad.config.Resolve(&device)
+		})
+		for _, err := range errs {
+			log.Errorf("configured element not found on %s, it is not controlled: %s", device.Model, err.Error())
+		}
+		ad.subscribeDevice(&device)
+
+		for spkId, spk := range ad.config.Speaker {
+			ad.toController.Push(monitorcontroller.AdSetSpeakerChannels{Id: spkId, Names: spk.ChannelNames()})
+		}
 
-		ad.toController <- monitorcontroller.AdSetDeviceStatus{
+		ad.toController.Push(monitorcontroller.AdSetDeviceStatus{
 			DeviceId:        device.ID,
 			Model:           device.Model,
 			SerialNumber:    device.SerialNumber,
 			SampleRate:      device.Clocking.SampleRate.Value,
 			ConnectionState: ad.device.Connected(),
-		}
-		ad.toController <- monitorcontroller.AdUpdateRequest{}
+		})
+		ad.toController.Push(monitorcontroller.AdUpdateRequest{})
 	}
 }
 
 func (ad *AudioDeviceConnector) handleFcDeviceRemovalMsg(deviceId int) {
 	log.Debugf("Focusrite Device removed ID:%d", deviceId)
 	if deviceId != 0 && deviceId == ad.config.FocusriteDeviceId {
-		ad.config.FocusriteDeviceId = 0
+		ad.config.update(func() { ad.config.FocusriteDeviceId = 0 })
+		ad.unsubscribeDevice()
 	}
 
-	ad.toController <- monitorcontroller.AdSetDeviceStatus{
+	ad.toController.Push(monitorcontroller.AdSetDeviceStatus{
 		DeviceId:        0,
 		Model:           "",
 		SerialNumber:    "",
 		ConnectionState: ad.device.Connected(),
+	})
+}
+
+// subscribeDevice reports changes of the hardware monitor controls, speaker mutes, gains and names of the configured device to the controller
+func (ad *AudioDeviceConnector) subscribeDevice(device *focusritexml.Device) {
+	ad.unsubscribeDevice()
+
+	ids := []int{
+		int(ad.config.Master.DimSwitch.ID),
+		int(ad.config.Master.MuteSwitch.ID),
+		int(ad.config.Master.VolumeKnob.ID),
+		int(ad.config.Master.AltSwitch.ID),
+	}
+	for _, spk := range ad.config.Speaker {
+		ids = append(ids, int(spk.Name.ID))
+		for _, ch := range spk.Channels {
+			ids = append(ids, int(ch.Mute.ID), int(ch.OutputGain.ID))
+		}
+	}
+	ad.subscription = device.SubscribeIds(ids, ad.onFcElementChange)
+
+	// names are taken from the device, the rest is set by the controller after the update request
+	for _, spk := range ad.config.Speaker {
+		name, ok := device.Value(int(spk.Name.ID))
+		if ok && name != "" {
+			ad.handleFcElementChange(focusritexml.ElementChange{DeviceId: device.ID, ID: int(spk.Name.ID), New: name})
+		}
 	}
 }
 
-func (ad *AudioDeviceConnector) handleFcUpdateMsg(set focusritexml.Set) {
-	if set.DevID != ad.config.FocusriteDeviceId {
-		return
+func (ad *AudioDeviceConnector) unsubscribeDevice() {
+	if ad.subscription != nil {
+		ad.subscription.Unsubscribe()
+		ad.subscription = nil
 	}
+	ad.echoes.Reset()
+	ad.ramp.Reset()
+}
 
-	for _, s := range set.Items {
-		fcID := FocusriteId(s.ID)
+// onFcElementChange is called by the focusrite client when a subscribed element changed
+func (ad *AudioDeviceConnector) onFcElementChange(change focusritexml.ElementChange) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+	// the handler may still be called right after unsubscribing
+	if change.DeviceId != ad.config.FocusriteDeviceId {
+		return
+	}
+	ad.handleFcElementChange(change)
+}
 
-		if ad.config.Master.DimSwitch == fcID {
-			log.Debugf("Found Dim ID %d", s.ID)
-			boolValue, err := strconv.ParseBool(s.Value)
-			if err == nil {
-				ad.toController <- monitorcontroller.AdSetDim(boolValue)
+// handleFcElementChange forwards changes made in Focusrite Control or on the device, changes caused by our own sets are dropped
+func (ad *AudioDeviceConnector) handleFcElementChange(change focusritexml.ElementChange) {
+	if ad.echoes.IsEcho(change.ID, change.New) {
+		return
+	}
+	ad.ramp.TrackValue(change.ID, formatValue(change.New))
+	fcID := FocusriteId(change.ID)
+
+	switch v := change.New.(type) {
+	case bool:
+		if ad.config.Master.DimSwitch.ID == fcID {
+			log.Debugf("Dim changed on device: %t", v)
+			ad.toController.Push(monitorcontroller.AdSetDim(v))
+		}
+		if ad.config.Master.MuteSwitch.ID == fcID {
+			log.Debugf("Mute changed on device: %t", v)
+			ad.toController.Push(monitorcontroller.AdSetMute(v))
+		}
+		for spkId, spk := range ad.config.Speaker {
+			for _, ch := range spk.Channels {
+				if ch.Mute.ID == fcID {
+					ad.handleFcSpeakerMute(spkId, v)
+				}
 			}
-			continue
 		}
 
-		if ad.config.Master.MuteSwitch == fcID {
-			log.Debugf("Found Mute ID %d: %v", s.ID, s.Value)
-			boolValue, err := strconv.ParseBool(s.Value)
-			if err == nil {
-				ad.toController <- monitorcontroller.AdSetMute(boolValue)
+	case int:
+		if ad.config.Master.VolumeKnob.ID == fcID {
+			log.Debugf("Volume knob changed on device: %d", v)
+			ad.toController.Push(monitorcontroller.AdSetVolume(clampVolume(v)))
+		}
+		if ad.config.Master.AltSwitch.ID == fcID && ad.config.UseAlt() {
+			log.Debugf("Alt changed on device: %d", v)
+			ad.selectSpeakerSet(v != 0)
+		}
+		for spkId, spk := range ad.config.Speaker {
+			for _, ch := range spk.Channels {
+				if ch.OutputGain.ID == fcID {
+					ad.handleFcSpeakerGain(spkId, v)
+				}
 			}
-			continue
 		}
 
+	case string:
 		for spkId, spk := range ad.config.Speaker {
-			if spk.Name == FocusriteId(s.ID) {
-				ad.toController <- monitorcontroller.AdSetSpeakerName{Id: spkId, Name: s.Value}
-				continue
+			if spk.Name.ID == fcID {
+				ad.toController.Push(monitorcontroller.AdSetSpeakerName{Id: spkId, Name: v})
 			}
-
-			// FIXME Getting Backfire from Focusrite Control when setting a device mute or outputgain
-			// if spk.Mute == FocusriteId(s.ID) {}
-			// if spk.OutputGain == FocusriteId(s.ID) {}
 		}
 
+	default:
+		log.Warnf("unexpected value type %T of element %d", change.New, change.ID)
+	}
+}
+
+// handleFcSpeakerMute maps an output mute to the speaker selection, unmuting an output also unmutes the master
+func (ad *AudioDeviceConnector) handleFcSpeakerMute(spkId monitorcontroller.SpeakerID, mute bool) {
+	spk, ok := ad.state.Speaker[spkId]
+	if !ok || spk.Disabled {
+		return
+	}
+	log.Debugf("Speaker %d mute changed on device: %t", spkId, mute)
+
+	if !mute && ad.state.Master.Mute {
+		ad.toController.Push(monitorcontroller.AdSetMute(false))
+	}
+	if spk.Selected == mute {
+		ad.toController.Push(monitorcontroller.AdSpeakerSelect{Id: spkId, State: !mute})
+	}
+}
+
+// handleFcSpeakerGain sets the master volume, the dim offset is included in the output gain
+func (ad *AudioDeviceConnector) handleFcSpeakerGain(spkId monitorcontroller.SpeakerID, gain int) {
+	spk, ok := ad.state.Speaker[spkId]
+	if !ok || spk.Disabled {
+		return
+	}
+	log.Debugf("Speaker %d gain changed on device: %d", spkId, gain)
+
+	// while calibrating the gain is matched by the trim
+	if ad.state.Master.Calibration {
+		ad.toController.Push(monitorcontroller.AdSetSpeakerTrim{Id: spkId, TrimDB: gain - ad.state.Master.CalibrationLevelDB})
+		return
+	}
+
+	volume := gain - spk.TrimDB
+	if ad.state.Master.Dim {
+		volume = volume + ad.state.Master.DimOffset
+	}
+	ad.toController.Push(monitorcontroller.AdSetVolume(clampVolume(volume)))
+}
+
+// selectSpeakerSet selects the speakers of the main or alt set and deselects the others of both sets
+func (ad *AudioDeviceConnector) selectSpeakerSet(alt bool) {
+	selected, other := ad.config.MainSpeaker, ad.config.AltSpeaker
+	if alt {
+		selected, other = other, selected
+	}
+
+	sel := make(monitorcontroller.AdSelectSpeakers)
+	for _, spkId := range other {
+		sel[spkId] = false
+	}
+	for _, spkId := range selected {
+		sel[spkId] = true
+	}
+	ad.toController.Push(sel)
+}
+
+func (ad *AudioDeviceConnector) handleFcUpdateMsg(set focusritexml.Set) {
+	if set.DevID != ad.config.FocusriteDeviceId {
+		return
 	}
 
 	// Handle Speaker Level separately and use only first speaker selected
 	var spkForLevel *SpeakerFcConfig
-	for spkId, spk := range ad.config.Speaker {
-		if !ad.state.Speaker[spkId].Disabled && ad.state.Speaker[spkId].Selected && ad.state.Speaker[spkId].Type == monitorcontroller.Speaker {
+	for _, spkId := range ad.state.SpeakerIDs() {
+		state := ad.state.Speaker[spkId]
+		spk, ok := ad.config.Speaker[spkId]
+		if ok && !state.Disabled && state.Selected && state.Type == monitorcontroller.Speaker {
 			spkForLevel = spk
 			break //break after found one speaker
 		}
 	}
 
 	if spkForLevel != nil {
+		meterL, meterR := spkForLevel.MeterIds()
 		levelL := -127.0
 		levelR := -127.0
 		var err error
 
 		for _, s := range set.Items {
-			if spkForLevel.MeterL == FocusriteId(s.ID) {
+			if meterL == FocusriteId(s.ID) {
 				levelL, err = strconv.ParseFloat(s.Value, 64)
 				if err != nil {
 					log.Error(err.Error())
 				}
 			}
-			if spkForLevel.MeterR == FocusriteId(s.ID) {
+			if meterR == FocusriteId(s.ID) {
 				levelR, err = strconv.ParseFloat(s.Value, 64)
 				if err != nil {
 					log.Error(err.Error())
@@ -190,105 +436,271 @@ func (ad *AudioDeviceConnector) handleFcUpdateMsg(set focusritexml.Set) {
 }
 
 func (ad *AudioDeviceConnector) HandleSpeakerName(spkId monitorcontroller.SpeakerID, name string) {
-	ad.state.Speaker[spkId].Name = name
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
+	spk, ok := ad.state.Speaker[spkId]
+	spkConfig, configured := ad.config.Speaker[spkId]
+	if !ok || !configured {
+		return
+	}
+	spk.Name = name
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
-	fcUpdateSet.AddItemString(int(ad.config.Speaker[spkId].Name), name)
-	ad.device.ToFocusrite <- *fcUpdateSet
+	fcUpdateSet.AddItemString(int(spkConfig.Name.ID), name)
+	ad.send(fcUpdateSet)
 }
 
 func (ad *AudioDeviceConnector) HandleDim(dim bool) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
 
 	log.Debugf("Handling Dim: %t", dim)
 	ad.state.Master.Dim = dim
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
-	fcUpdateSet.AddItemBool(int(ad.config.Master.DimSwitch), ad.state.Master.Dim)
-	fcUpdateSet.AddItems(ad.getSpeakerVolumeUpdateSet().Items)
-	ad.device.ToFocusrite <- *fcUpdateSet
+	fcUpdateSet.AddItemBool(int(ad.config.Master.DimSwitch.ID), ad.state.Master.Dim)
+	ad.rampGains(fcUpdateSet, ad.config.Ramp.DimMs)
 
 }
 
+// HandleMute mutes at once, unmuting starts silent and ramps up to the speaker gains
 func (ad *AudioDeviceConnector) HandleMute(mute bool) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
 	ad.state.Master.Mute = mute
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
-	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch), mute)
+	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch.ID), mute)
 	fcUpdateSet.AddItems(ad.getSpeakerMuteUpdateSet().Items)
-	ad.device.ToFocusrite <- *fcUpdateSet
+
+	if mute || ad.config.Ramp.UnmuteMs == 0 {
+		ad.ramp.Stop()
+		ad.send(fcUpdateSet)
+		return
+	}
+
+	target := ad.getSpeakerGains()
+	silent := make(map[int]int, len(target))
+	for id := range target {
+		silent[id] = -127
+	}
+	ad.ramp.Start(ad.config.FocusriteDeviceId, []rampPhase{
+		{gains: silent},
+		{before: fcUpdateSet, gains: target, duration: rampDuration(ad.config.Ramp.UnmuteMs)},
+	}, ad.send)
 }
 
+// HandleVolume ramps volume jumps, smaller changes are sent directly
 func (ad *AudioDeviceConnector) HandleVolume(vol int) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
 	ad.state.Master.VolumeDB = vol
 
-	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
-	fcUpdateSet.AddItems(ad.getSpeakerVolumeUpdateSet().Items)
-	ad.device.ToFocusrite <- *fcUpdateSet
+	ms := 0
+	for id, gain := range ad.getSpeakerGains() {
+		if abs(gain-ad.ramp.Current(id, gain)) >= ad.config.Ramp.VolumeJumpDB {
+			ms = ad.config.Ramp.VolumeMs
+		}
+	}
+	ad.rampGains(ad.getHardwareVolumeUpdateSet(), ms)
 }
 
 func (ad *AudioDeviceConnector) HandleMeter(level int) {
 	//ignore meter values - nothing to show on device
 }
 
-func (ad *AudioDeviceConnector) HandleSpeakerSelect(spkId monitorcontroller.SpeakerID, sel bool) {
-	ad.state.Speaker[spkId].Selected = sel
+// HandleSpeakerSelection crossfades, the speakers turned off ramp down before the speakers turned on ramp up
+func (ad *AudioDeviceConnector) HandleSpeakerSelection(sel monitorcontroller.Selection) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
+	for spkId, selected := range sel {
+		spk, ok := ad.state.Speaker[spkId]
+		if !ok {
+			log.Warnf("Speaker %d selected before its update", spkId)
+			continue
+		}
+		spk.Selected = selected
+	}
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
 	fcUpdateSet.AddItems(ad.getSpeakerMuteUpdateSet().Items)
-	ad.device.ToFocusrite <- *fcUpdateSet
+	fcUpdateSet.AddItems(ad.getHardwareAltUpdateSet().Items)
+
+	// a crossfade still running stops where it is, the audible speakers are taken from there
+	ad.ramp.Stop()
+	target := ad.getSpeakerGains()
+	audible := ad.getSentAudibleGains()
+	now := ad.getAudibleGains()
+	down := make(map[int]int, len(target))
+	switched := false
+	for id, gain := range target {
+		down[id] = gain
+		if audible[id] != now[id] {
+			down[id] = -127
+			switched = true
+		}
+	}
+
+	if !switched || ad.config.Ramp.SwitchMs == 0 {
+		ad.send(fcUpdateSet)
+		return
+	}
 
+	half := rampDuration(ad.config.Ramp.SwitchMs / 2)
+	ad.ramp.Start(ad.config.FocusriteDeviceId, []rampPhase{
+		{gains: down, duration: half},
+		{before: fcUpdateSet, gains: target, duration: half}, // the old speakers are muted with the first step
+	}, ad.send)
 }
 func (ad *AudioDeviceConnector) HandleSpeakerUpdate(spkId monitorcontroller.SpeakerID, spk *monitorcontroller.SpeakerState) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
 	ad.state.Speaker[spkId] = spk
+	ad.ramp.Stop()
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
 	fcUpdateSet.AddItems(ad.getSpeakerMuteUpdateSet().Items)
+	fcUpdateSet.AddItems(ad.getSpeakerVolumeUpdateSet().Items)
 	fcUpdateSet.AddItems(ad.getSpeakerNameUpdateSet().Items)
+	fcUpdateSet.AddItems(ad.getHardwareAltUpdateSet().Items)
 
-	ad.device.ToFocusrite <- *fcUpdateSet
+	ad.send(fcUpdateSet)
 
 }
 
 func (ad *AudioDeviceConnector) HandleMasterUpdate(master *monitorcontroller.MasterState) {
+	ad.mu.Lock()
+	defer ad.mu.Unlock()
+
 	ad.state.Master = master
+	ad.ramp.Stop()
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
 	fcUpdateSet.AddItems(ad.getSpeakerMuteUpdateSet().Items)
 	fcUpdateSet.AddItems(ad.getSpeakerVolumeUpdateSet().Items)
-	fcUpdateSet.AddItemBool(int(ad.config.Master.DimSwitch), ad.state.Master.Dim)
-	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch), ad.state.Master.Mute)
-	ad.device.ToFocusrite <- *fcUpdateSet
+	fcUpdateSet.AddItemBool(int(ad.config.Master.DimSwitch.ID), ad.state.Master.Dim)
+	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch.ID), ad.state.Master.Mute)
+	fcUpdateSet.AddItems(ad.getHardwareVolumeUpdateSet().Items)
+	if ad.config.UseAlt() {
+		fcUpdateSet.AddItemBool(int(ad.config.Master.AltEnable.ID), true)
+		fcUpdateSet.AddItems(ad.getHardwareAltUpdateSet().Items)
+	}
+	ad.send(fcUpdateSet)
+}
+
+// send records the values in flight and queues the set, the set is dropped once the connector stopped.
+// The ramp calls it without ad.mu, so stopping a ramp while holding ad.mu can't wait for the lock.
+func (ad *AudioDeviceConnector) send(set *focusritexml.Set) {
+	if len(set.Items) == 0 {
+		return
+	}
+	ad.echoes.Sent(set)
+	ad.ramp.Track(set)
+	select {
+	case ad.device.ToFocusrite <- *set:
+	case <-ad.done:
+	}
+}
+
+// rampGains sends the items of set with the first step and ramps the speaker gains to the master level.
+// The switches of a cancelled crossfade or unmute are not sent by the ramp, they are sent here if they differ.
+func (ad *AudioDeviceConnector) rampGains(set *focusritexml.Set, ms int) {
+	ad.ramp.Stop()
+
+	before := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+	before.AddItems(set.Items)
+	before.AddItems(ad.ramp.Changed(ad.getSwitchUpdateSet()).Items)
+
+	ad.ramp.Start(ad.config.FocusriteDeviceId, []rampPhase{
+		{before: before, gains: ad.getSpeakerGains(), duration: rampDuration(ms)},
+	}, ad.send)
 }
 
 // Setters
 func (ad *AudioDeviceConnector) setMasterLevel(levelLeft, levelRight int) {
 	ad.state.Master.LevelLeft = levelLeft
 	ad.state.Master.LevelRight = levelRight
-	ad.toController <- monitorcontroller.AdSetLevel{Left: ad.state.Master.LevelLeft, Right: ad.state.Master.LevelRight}
+	ad.toController.Push(monitorcontroller.AdSetLevel{Left: ad.state.Master.LevelLeft, Right: ad.state.Master.LevelRight})
 }
 
 // Fc XNL Set generator functions
 func (ad *AudioDeviceConnector) getSpeakerVolumeUpdateSet() *focusritexml.Set {
-	volume := int(ad.state.Master.VolumeDB)
-	if volume >= 0 {
-		volume = 0
+	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+	for id, gain := range ad.getSpeakerGains() {
+		fcUpdateSet.AddItemInt(id, gain)
 	}
-	if ad.state.Master.Dim {
-		volume = volume - int(ad.state.Master.DimOffset)
+	return fcUpdateSet
+
+}
+
+// getSpeakerGains returns the output gain of every channel of the enabled speakers by element ID
+func (ad *AudioDeviceConnector) getSpeakerGains() map[int]int {
+	volume := ad.masterLevel()
+
+	gains := make(map[int]int)
+	for spkId, spk := range ad.config.Speaker {
+		state, ok := ad.state.Speaker[spkId]
+		if ok && !state.Disabled {
+			gain := clampVolume(volume + state.TrimDB)
+			for _, ch := range spk.Channels {
+				if ch.OutputGain.ID != 0 {
+					gains[int(ch.OutputGain.ID)] = gain
+				}
+			}
+			log.Debugf("Sending speaker %d level %d", spkId, gain)
+		}
 	}
-	if volume < -127 {
-		volume = -127
+	return gains
+}
+
+// getSentAudibleGains returns the gain element IDs of the channels last unmuted on the device
+func (ad *AudioDeviceConnector) getSentAudibleGains() map[int]bool {
+	audible := make(map[int]bool)
+	for _, spk := range ad.config.Speaker {
+		for _, ch := range spk.Channels {
+			if !ad.ramp.Bool(int(ch.Mute.ID), true) {
+				audible[int(ch.OutputGain.ID)] = true
+			}
+		}
 	}
+	return audible
+}
 
-	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+// getAudibleGains returns the gain element IDs of the channels unmuted by the current state
+func (ad *AudioDeviceConnector) getAudibleGains() map[int]bool {
+	audible := make(map[int]bool)
+	if ad.state.Master.Mute {
+		return audible
+	}
 	for spkId, spk := range ad.config.Speaker {
-		if !ad.state.Speaker[spkId].Disabled {
-			fcUpdateSet.AddItemInt(int(spk.OutputGain), volume)
+		state, ok := ad.state.Speaker[spkId]
+		if !ok || state.Disabled || !state.Selected {
+			continue
+		}
+		for i, ch := range spk.Channels {
+			if state.ChannelAudible(i) {
+				audible[int(ch.OutputGain.ID)] = true
+			}
 		}
+	}
+	return audible
+}
 
+// masterLevel is the output level without trim: the volume minus dim, or the reference level while calibrating
+func (ad *AudioDeviceConnector) masterLevel() int {
+	if ad.state.Master.Calibration {
+		return ad.state.Master.CalibrationLevelDB
 	}
-	log.Debugf("Sending speaker level %d", volume)
-	return fcUpdateSet
 
+	volume := min(ad.state.Master.VolumeDB, 0)
+	if ad.state.Master.Dim {
+		volume = volume - ad.state.Master.DimOffset
+	}
+	return volume
 }
 
 func (ad *AudioDeviceConnector) getSpeakerMuteUpdateSet() *focusritexml.Set {
@@ -296,7 +708,7 @@ func (ad *AudioDeviceConnector) getSpeakerMuteUpdateSet() *focusritexml.Set {
 	mute := ad.state.Master.Mute
 
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
-	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch), mute)
+	fcUpdateSet.AddItemBool(int(ad.config.Master.MuteSwitch.ID), mute)
 
 	// reset level if no speaker is selected
 	countEnabledSpeaker := 0
@@ -310,9 +722,12 @@ func (ad *AudioDeviceConnector) getSpeakerMuteUpdateSet() *focusritexml.Set {
 	}
 
 	for spkId, spk := range ad.config.Speaker {
-		if !ad.state.Speaker[spkId].Disabled {
-			state := mute || !ad.state.Speaker[spkId].Selected
-			fcUpdateSet.AddItemBool(int(spk.Mute), state)
+		spkState, ok := ad.state.Speaker[spkId]
+		if ok && !spkState.Disabled {
+			state := mute || !spkState.Selected
+			for i, ch := range spk.Channels {
+				fcUpdateSet.AddItemBool(int(ch.Mute.ID), state || !spkState.ChannelAudible(i))
+			}
 			log.Debugf("setting focusrite speaker %d Mute to %t", spkId, state)
 		}
 
@@ -320,12 +735,73 @@ func (ad *AudioDeviceConnector) getSpeakerMuteUpdateSet() *focusritexml.Set {
 	return fcUpdateSet
 }
 
+// getHardwareVolumeUpdateSet sets the volume knob to the master volume, dim is shown by the dim button
+func (ad *AudioDeviceConnector) getHardwareVolumeUpdateSet() *focusritexml.Set {
+	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+	fcUpdateSet.AddItemInt(int(ad.config.Master.VolumeKnob.ID), clampVolume(ad.state.Master.VolumeDB))
+	return fcUpdateSet
+}
+
+// getHardwareAltUpdateSet turns ALT on if the alt speakers are selected and the main speakers are not
+func (ad *AudioDeviceConnector) getHardwareAltUpdateSet() *focusritexml.Set {
+	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+	if !ad.config.UseAlt() {
+		return fcUpdateSet
+	}
+
+	allSelected := func(speakers []monitorcontroller.SpeakerID) bool {
+		for _, spkId := range speakers {
+			spk, ok := ad.state.Speaker[spkId]
+			if !ok || !spk.Selected {
+				return false
+			}
+		}
+		return true
+	}
+
+	alt := 0
+	if allSelected(ad.config.AltSpeaker) && !allSelected(ad.config.MainSpeaker) {
+		alt = 1
+	}
+	fcUpdateSet.AddItemInt(int(ad.config.Master.AltSwitch.ID), alt)
+	return fcUpdateSet
+}
+
+// getSwitchUpdateSet returns the master and speaker mutes and the ALT switch of the current state
+func (ad *AudioDeviceConnector) getSwitchUpdateSet() *focusritexml.Set {
+	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
+	fcUpdateSet.AddItems(ad.getSpeakerMuteUpdateSet().Items)
+	fcUpdateSet.AddItems(ad.getHardwareAltUpdateSet().Items)
+	return fcUpdateSet
+}
+
 func (ad *AudioDeviceConnector) getSpeakerNameUpdateSet() *focusritexml.Set {
 	fcUpdateSet := focusritexml.NewSet(ad.config.FocusriteDeviceId)
 	for spkId, spk := range ad.config.Speaker {
-		name := ad.state.Speaker[spkId].Name
-		fcUpdateSet.AddItemString(int(spk.Name), name)
+		state, ok := ad.state.Speaker[spkId]
+		if !ok {
+			continue
+		}
+		name := state.Name
+		fcUpdateSet.AddItemString(int(spk.Name.ID), name)
 		log.Debugf("setting focusrite speaker %d name to %s", spkId, name)
 	}
 	return fcUpdateSet
 }
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func clampVolume(volume int) int {
+	if volume > 0 {
+		return 0
+	}
+	if volume < -127 {
+		return -127
+	}
+	return volume
+}
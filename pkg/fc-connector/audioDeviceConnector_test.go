@@ -0,0 +1,438 @@
+package fcaudioconnector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	focusritesimulator "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-simulator"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/monitorcontroller"
+)
+
+const (
+	testFixture  string        = "../../xml/device-arrival-18i20.xml"
+	testWaitTime time.Duration = 3 * time.Second
+)
+
+// startConnector connects a connector with the default configuration to a simulator with the 18i20 fixture
+func startConnector(t *testing.T, authorised bool) (*AudioDeviceConnector, *focusritesimulator.Server, chan interface{}) {
+	t.Helper()
+	return startConnectorWith(t, authorised, DefaultConfiguration())
+}
+
+func startConnectorWith(t *testing.T, authorised bool, cfg *FcConfiguration) (*AudioDeviceConnector, *focusritesimulator.Server, chan interface{}) {
+	t.Helper()
+
+	simCfg := focusritesimulator.DefaultConfig()
+	simCfg.DiscoveryPorts = nil
+	simCfg.Authorised = authorised
+	sim := focusritesimulator.NewServer(simCfg)
+	if err := sim.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.AddDeviceFromFile(testFixture); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.FocusriteHost = "127.0.0.1"
+	cfg.FocusritePort = sim.Port()
+
+	ad := NewAudioDeviceConnector(cfg)
+	toController := make(chan interface{}, 1000)
+	ad.SetControlChannel(toController)
+	if err := ad.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		ad.Close()
+		sim.Close()
+	})
+	return ad, sim, toController
+}
+
+// expectMessage skips controller messages until match returns true
+func expectMessage(t *testing.T, ch chan interface{}, what string, match func(interface{}) bool) {
+	t.Helper()
+	timeout := time.After(testWaitTime)
+	for {
+		select {
+		case msg := <-ch:
+			if match(msg) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s", what)
+		}
+	}
+}
+
+// approvals returns the approval states sent to the controller within d
+func approvals(ch chan interface{}, d time.Duration) []monitorcontroller.ApprovalState {
+	states := make([]monitorcontroller.ApprovalState, 0)
+	timeout := time.After(d)
+	for {
+		select {
+		case msg := <-ch:
+			if a, ok := msg.(monitorcontroller.AdSetApproval); ok {
+				states = append(states, monitorcontroller.ApprovalState(a))
+			}
+		case <-timeout:
+			return states
+		}
+	}
+}
+
+func TestApprovalPendingUntilRefused(t *testing.T) {
+	_, sim, toController := startConnector(t, false)
+
+	// the first answer without approval means the server waits for the user
+	for _, a := range approvals(toController, time.Second) {
+		if a != monitorcontroller.ApprovalPending {
+			t.Fatalf("approval after connect: %s", a)
+		}
+	}
+
+	sim.SetApproval(false)
+	if got := approvals(toController, 500*time.Millisecond); len(got) != 1 || got[0] != monitorcontroller.ApprovalDenied {
+		t.Fatalf("approvals after refusal: %v", got)
+	}
+
+	sim.SetApproval(true)
+	if got := approvals(toController, 500*time.Millisecond); len(got) != 1 || got[0] != monitorcontroller.ApprovalApproved {
+		t.Fatalf("approvals after approval: %v", got)
+	}
+}
+
+func TestApprovalNotPendingOnDisconnect(t *testing.T) {
+	_, sim, toController := startConnector(t, true)
+	expectMessage(t, toController, "approval", func(msg interface{}) bool {
+		return msg == monitorcontroller.AdSetApproval(monitorcontroller.ApprovalApproved)
+	})
+
+	sim.Close()
+	for _, a := range approvals(toController, time.Second) {
+		if a == monitorcontroller.ApprovalPending {
+			t.Fatal("pending after disconnect")
+		}
+	}
+}
+
+// startConfigured starts a connector, waits for the configured device and sends the controller state.
+// It returns the device model of the simulator to resolve the element IDs.
+func startConfigured(t *testing.T) (*AudioDeviceConnector, *focusritesimulator.Server, chan interface{}, *focusritexml.Device) {
+	t.Helper()
+	ad, sim, toController := startConnector(t, true)
+
+	deviceId := 0
+	expectMessage(t, toController, "device status", func(msg interface{}) bool {
+		status, ok := msg.(monitorcontroller.AdSetDeviceStatus)
+		deviceId = status.DeviceId
+		return ok && status.DeviceId != 0
+	})
+	expectMessage(t, toController, "update request", func(msg interface{}) bool {
+		return msg == monitorcontroller.AdUpdateRequest{}
+	})
+	device, ok := sim.Device(deviceId)
+	if !ok {
+		t.Fatal("no device")
+	}
+
+	state := monitorcontroller.NewDefaultState()
+	state.Master.Mute = false
+	state.Master.VolumeDB = -20
+	ad.HandleMasterUpdate(state.Master)
+	for _, spkId := range state.SpeakerIDs() {
+		ad.HandleSpeakerUpdate(spkId, state.Speaker[spkId])
+	}
+
+	// the sets are sent after subscribing, so the simulator sends the changes once the gain arrived
+	gain := resolve(t, device, `outputs/analogue[name="Monitor Output 1"]/gain`)
+	timeout := time.After(testWaitTime)
+	for {
+		if v, _ := sim.Value(deviceId, gain); v == "-20" {
+			return ad, sim, toController, device
+		}
+		select {
+		case <-timeout:
+			t.Fatal("controller state not sent to the device")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func resolve(t *testing.T, device *focusritexml.Device, path string) int {
+	t.Helper()
+	id, err := device.Resolve(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+type recordedItem struct {
+	path  string
+	value string
+}
+
+// changes made in Focusrite Control or on the device, one set per entry
+func TestRecordedSets(t *testing.T) {
+	tests := []struct {
+		name string
+		sets [][]recordedItem
+		want interface{}
+	}{
+		{
+			name: "dim button",
+			sets: [][]recordedItem{{{PATH_HW_DIM, "true"}}},
+			want: monitorcontroller.AdSetDim(true),
+		},
+		{
+			name: "mute button",
+			sets: [][]recordedItem{{{PATH_HW_MUTE, "true"}}},
+			want: monitorcontroller.AdSetMute(true),
+		},
+		{
+			name: "volume knob",
+			sets: [][]recordedItem{{{PATH_HW_GAIN, "-30"}}, {{PATH_HW_GAIN, "-31"}}, {{PATH_HW_GAIN, "-32"}}},
+			want: monitorcontroller.AdSetVolume(-32),
+		},
+		{
+			name: "output unmuted",
+			sets: [][]recordedItem{{{`outputs/analogue[name="Line Output 3"]/mute`, "false"}}},
+			want: monitorcontroller.AdSpeakerSelect{Id: monitorcontroller.SpeakerB, State: true},
+		},
+		{
+			name: "output renamed",
+			sets: [][]recordedItem{{{`outputs/analogue[name="Monitor Output 1"]/nickname`, "Nearfield"}}},
+			want: monitorcontroller.AdSetSpeakerName{Id: monitorcontroller.SpeakerA, Name: "Nearfield"},
+		},
+		{
+			name: "meters",
+			sets: [][]recordedItem{{
+				{`outputs/analogue[name="Monitor Output 1"]/meter`, "-12.0"},
+				{`outputs/analogue[name="Monitor Output 2"]/meter`, "-14.5"},
+				{`outputs/analogue[name="Line Output 3"]/meter`, "-3.0"},
+			}},
+			want: monitorcontroller.AdSetLevel{Left: -12, Right: -14},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, sim, toController, device := startConfigured(t)
+			for _, items := range tt.sets {
+				set := focusritexml.NewSet(device.ID)
+				for _, item := range items {
+					set.AddItem(focusritexml.Item{ID: resolve(t, device, item.path), Value: item.value})
+				}
+				sim.SendSet(*set)
+			}
+			expectMessage(t, toController, fmt.Sprint(tt.want), func(msg interface{}) bool {
+				return msg == tt.want
+			})
+		})
+	}
+}
+
+// the controller, the client and the subscription callbacks use the state concurrently
+func TestConcurrentChanges(t *testing.T) {
+	ad, sim, toController, device := startConfigured(t)
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for range toController {
+		}
+	}()
+
+	knob := resolve(t, device, PATH_HW_GAIN)
+	meterL := resolve(t, device, `outputs/analogue[name="Monitor Output 1"]/meter`)
+	meterR := resolve(t, device, `outputs/analogue[name="Monitor Output 2"]/meter`)
+	mute := resolve(t, device, `outputs/analogue[name="Line Output 3"]/mute`)
+
+	stop := make(chan struct{})
+	time.AfterFunc(time.Second, func() { close(stop) })
+	loop := func(wg *sync.WaitGroup, f func(i int)) {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			f(i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go loop(&wg, func(i int) {
+		ad.HandleVolume(-40 + i%30)
+		ad.HandleDim(i%10 == 0)
+		alt := i%20 < 10
+		ad.HandleSpeakerSelection(monitorcontroller.Selection{monitorcontroller.SpeakerA: !alt, monitorcontroller.SpeakerB: alt})
+		ad.HandleMeter(-20)
+		if i%50 == 0 {
+			ad.HandleMasterUpdate(monitorcontroller.NewDefaultState().Master)
+		}
+	})
+	// meters are not subscribed, they are handled with the raw updates only
+	go loop(&wg, func(i int) {
+		set := focusritexml.NewSet(device.ID)
+		set.AddItemString(meterL, fmt.Sprintf("%d.0", -i%60))
+		set.AddItemString(meterR, fmt.Sprintf("%d.0", -i%50))
+		sim.SendSet(*set)
+	})
+	go loop(&wg, func(i int) {
+		set := focusritexml.NewSet(device.ID)
+		set.AddItemInt(knob, -60+i%40)
+		set.AddItemBool(mute, i%2 == 0)
+		sim.SendSet(*set)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		ad.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("connector blocked")
+	}
+	close(toController)
+	<-received
+}
+
+// the controller may still send commands while the connector is closed, they must not block
+func TestHandleAfterClose(t *testing.T) {
+	ad, _, _, _ := startConfigured(t)
+	if err := ad.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 300; i++ {
+			ad.HandleSpeakerSelection(monitorcontroller.Selection{monitorcontroller.SpeakerA: i%2 == 0, monitorcontroller.SpeakerB: i%2 != 0})
+			ad.HandleVolume(-30 - i%60)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testWaitTime):
+		t.Fatal("HandleVolume blocked after Close")
+	}
+}
+
+// the commands stop a running ramp holding the connector state, the ramp must not wait for it
+func TestRampStoppedHoldingState(t *testing.T) {
+	ad, _, _, _ := startConfigured(t)
+	ad.config.Ramp.SwitchMs = 5000
+
+	ad.HandleSpeakerSelection(monitorcontroller.Selection{monitorcontroller.SpeakerA: false, monitorcontroller.SpeakerB: true})
+	if err := ad.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ad.mu.Lock()
+		ad.ramp.Stop()
+		ad.mu.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testWaitTime):
+		t.Fatal("ramp didn't stop")
+	}
+}
+
+// a volume change cancelling a crossfade still switches the speakers
+func TestCancelledCrossfadeSwitchesSpeakers(t *testing.T) {
+	ad, sim, _, device := startConfigured(t)
+
+	muteA := resolve(t, device, `outputs/analogue[name="Monitor Output 1"]/mute`)
+	muteB := resolve(t, device, `outputs/analogue[name="Line Output 3"]/mute`)
+
+	ad.HandleSpeakerSelection(monitorcontroller.Selection{monitorcontroller.SpeakerA: false, monitorcontroller.SpeakerB: true})
+	ad.HandleVolume(-25)
+
+	timeout := time.After(testWaitTime)
+	for {
+		a, _ := sim.Value(device.ID, muteA)
+		b, _ := sim.Value(device.ID, muteB)
+		if a == "true" && b == "false" {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("mutes after crossfade: speaker A %s, speaker B %s", a, b)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// the configuration is saved while the connector resolves the elements of arriving devices
+func TestConfigSavedWhileConnected(t *testing.T) {
+	var mutex sync.Mutex
+	cfg := DefaultConfiguration()
+	cfg.SetLocker(&mutex)
+	_, sim, toController := startConnectorWith(t, true, cfg)
+
+	stop := make(chan struct{})
+	saved := make(chan int)
+	go func() {
+		count := 0
+		defer func() { saved <- count }()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			mutex.Lock()
+			_, err := yaml.Marshal(cfg)
+			mutex.Unlock()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			count++
+		}
+	}()
+
+	deviceId := 0
+	arrived := func(msg interface{}) bool {
+		status, ok := msg.(monitorcontroller.AdSetDeviceStatus)
+		if ok && status.DeviceId != 0 {
+			deviceId = status.DeviceId
+		}
+		return ok && status.DeviceId != 0
+	}
+	expectMessage(t, toController, "device status", arrived)
+	for i := 0; i < 20; i++ {
+		sim.RemoveDevice(deviceId)
+		sim.ArriveDevice(deviceId)
+		expectMessage(t, toController, "device status", arrived)
+	}
+
+	close(stop)
+	if count := <-saved; count == 0 {
+		t.Fatal("config not saved")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if cfg.FocusriteDeviceId != deviceId || cfg.ClientId == "" {
+		t.Fatalf("device id %d, client id %q", cfg.FocusriteDeviceId, cfg.ClientId)
+	}
+}
@@ -0,0 +1,71 @@
+package fcaudioconnector
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/monitorcontroller"
+)
+
+// controllerQueue forwards the messages to the controller in order without blocking the connector.
+// The controller calls the Handle functions from the goroutine reading its channel,
+// so the connector must not wait for the controller while it holds its state.
+type controllerQueue struct {
+	mutex   sync.Mutex
+	pending []interface{}
+	wake    chan struct{}
+}
+
+func newControllerQueue() *controllerQueue {
+	return &controllerQueue{
+		pending: make([]interface{}, 0),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Push queues a message, a meter level still pending is replaced by the newer one
+func (q *controllerQueue) Push(msg interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := msg.(monitorcontroller.AdSetLevel); ok {
+		q.pending = slices.DeleteFunc(q.pending, func(p interface{}) bool {
+			_, level := p.(monitorcontroller.AdSetLevel)
+			return level
+		})
+	}
+	q.pending = append(q.pending, msg)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run forwards the messages to ch until done is closed, the messages still pending are dropped
+func (q *controllerQueue) Run(ch chan interface{}, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-q.wake:
+		}
+
+		for {
+			q.mutex.Lock()
+			if len(q.pending) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			msg := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mutex.Unlock()
+
+			select {
+			case ch <- msg:
+			case <-done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package fcaudioconnector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+// Values sent to Focusrite Control come back as element changes: once when the client applies the set
+// to its device model and again when the server echoes it. While turning a knob the echo of an older
+// value may arrive after a newer value was sent, so every value sent within ECHO_TIMEOUT counts as echo.
+const ECHO_TIMEOUT time.Duration = 2 * time.Second
+
+type inFlightValue struct {
+	value   string
+	expires time.Time
+}
+
+// echoFilter tracks the values in flight per element ID
+type echoFilter struct {
+	mutex    sync.Mutex
+	timeout  time.Duration
+	inFlight map[int][]inFlightValue
+	now      func() time.Time
+}
+
+func newEchoFilter(timeout time.Duration) *echoFilter {
+	return &echoFilter{
+		timeout:  timeout,
+		inFlight: make(map[int][]inFlightValue),
+		now:      time.Now,
+	}
+}
+
+// Sent records all items of a set sent to the device
+func (f *echoFilter) Sent(set *focusritexml.Set) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	expires := now.Add(f.timeout)
+	for _, item := range set.Items {
+		values := f.expire(item.ID, now)
+		f.inFlight[item.ID] = append(values, inFlightValue{value: item.Value, expires: expires})
+	}
+}
+
+// IsEcho returns true if the value was sent to the element within the timeout
+func (f *echoFilter) IsEcho(id int, value interface{}) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s := formatValue(value)
+	for _, v := range f.expire(id, f.now()) {
+		if v.value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forgets all values in flight, e.g. when the device is gone
+func (f *echoFilter) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.inFlight = make(map[int][]inFlightValue)
+}
+
+// expire removes the expired values of an element, values are stored in sending order
+func (f *echoFilter) expire(id int, now time.Time) []inFlightValue {
+	values := f.inFlight[id]
+	i := 0
+	for i < len(values) && !now.Before(values[i].expires) {
+		i++
+	}
+	if i == len(values) {
+		delete(f.inFlight, id)
+		return nil
+	}
+	values = values[i:]
+	f.inFlight[id] = values
+	return values
+}
+
+// formatValue converts a typed element value to the format used in sets
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case bool:
+		return fmt.Sprintf("%t", value)
+	case int:
+		return fmt.Sprintf("%d", value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,136 @@
+package fcaudioconnector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+// FocusriteElement addresses a device element by its path, e.g. outputs/analogue[name="Monitor Output 1"]/mute.
+// The path is resolved to the ID when the device arrives.
+// Configs of older versions only have the ID, it's stored as path after the first arrival.
+type FocusriteElement struct {
+	Path string
+	ID   FocusriteId
+}
+
+// NewFocusriteElement creates an element with ID and path of the given device
+func NewFocusriteElement(device *focusritexml.Device, id int) FocusriteElement {
+	e := FocusriteElement{ID: FocusriteId(id)}
+	if p, ok := device.PathOf(id); ok {
+		e.Path = p.Path
+	}
+	return e
+}
+
+// IsSet returns true if the element is configured, it may still be unresolved
+func (e FocusriteElement) IsSet() bool {
+	return e.Path != "" || e.ID != 0
+}
+
+func (e FocusriteElement) String() string {
+	if e.Path == "" {
+		return strconv.Itoa(int(e.ID))
+	}
+	return fmt.Sprintf("%s (%d)", e.Path, e.ID)
+}
+
+// resolve updates the ID from the path, or the path from the ID if only the ID is known.
+// The ID is 0 if the path can't be resolved, so nothing is sent to a wrong element.
+func (e *FocusriteElement) resolve(device *focusritexml.Device) error {
+	if e.Path == "" {
+		if e.ID == 0 {
+			return nil
+		}
+		p, ok := device.PathOf(int(e.ID))
+		if !ok {
+			id := e.ID
+			e.ID = 0
+			return fmt.Errorf("no element with ID %d", id)
+		}
+		e.Path = p.Path
+		return nil
+	}
+
+	id, err := device.Resolve(e.Path)
+	e.ID = FocusriteId(id)
+	return err
+}
+
+// yaml: the path or the ID for unresolved configs of older versions
+func (e FocusriteElement) MarshalYAML() (interface{}, error) {
+	if e.Path != "" {
+		return e.Path, nil
+	}
+	return int(e.ID), nil
+}
+
+func (e *FocusriteElement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(value)
+	if err == nil {
+		*e = FocusriteElement{ID: FocusriteId(id)}
+		return nil
+	}
+	*e = FocusriteElement{Path: value}
+	return nil
+}
+
+// complete adds mute and gain of the output of the meter, they are missing in configs of older versions
+func (ch *SpeakerChannelFcConfig) complete() {
+	output, ok := strings.CutSuffix(ch.Meter.Path, "/meter")
+	if !ok {
+		return
+	}
+	if !ch.Mute.IsSet() {
+		ch.Mute = FocusriteElement{Path: output + "/mute"}
+	}
+	if !ch.OutputGain.IsSet() {
+		ch.OutputGain = FocusriteElement{Path: output + "/gain"}
+	}
+}
+
+// Resolve resolves all configured elements on the arrived device.
+// Returns an error for every element that can't be resolved.
+func (c *FcConfiguration) Resolve(device *focusritexml.Device) []error {
+	errs := make([]error, 0)
+
+	check := func(name string, e *FocusriteElement) {
+		err := e.resolve(device)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", name, err.Error()))
+		}
+	}
+
+	for spkId, spk := range c.Speaker {
+		if spk == nil {
+			continue
+		}
+		name := fmt.Sprintf("speaker %d", spkId)
+		check(name+" name", &spk.Name)
+		for i := range spk.Channels {
+			ch := &spk.Channels[i]
+			chName := fmt.Sprintf("%s channel %d", name, i+1)
+			check(chName+" meter", &ch.Meter)
+			ch.complete()
+			check(chName+" mute", &ch.Mute)
+			check(chName+" output gain", &ch.OutputGain)
+		}
+	}
+	if c.Master != nil {
+		check("master mute", &c.Master.MuteSwitch)
+		check("master dim", &c.Master.DimSwitch)
+		check("volume knob", &c.Master.VolumeKnob)
+		check("alt enable", &c.Master.AltEnable)
+		check("alt", &c.Master.AltSwitch)
+	}
+
+	return errs
+}
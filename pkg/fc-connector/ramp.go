@@ -0,0 +1,185 @@
+package fcaudioconnector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	focusriteclient "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-client"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+const (
+	DEFAULT_RAMP_UNMUTE_MS      int = 500
+	DEFAULT_RAMP_DIM_MS         int = 300
+	DEFAULT_RAMP_VOLUME_MS      int = 300
+	DEFAULT_RAMP_VOLUME_JUMP_DB int = 6
+	DEFAULT_RAMP_SWITCH_MS      int = 400
+)
+
+// RampConfig holds the ramp times in ms, 0 jumps straight to the target gain
+type RampConfig struct {
+	UnmuteMs     int
+	DimMs        int
+	VolumeMs     int
+	VolumeJumpDB int // smaller volume changes are sent directly, e.g. while turning a knob
+	SwitchMs     int // crossfade of a speaker switch, the old speakers ramp down before the new ones ramp up
+}
+
+func DefaultRampConfig() *RampConfig {
+	return &RampConfig{
+		UnmuteMs:     DEFAULT_RAMP_UNMUTE_MS,
+		DimMs:        DEFAULT_RAMP_DIM_MS,
+		VolumeMs:     DEFAULT_RAMP_VOLUME_MS,
+		VolumeJumpDB: DEFAULT_RAMP_VOLUME_JUMP_DB,
+		SwitchMs:     DEFAULT_RAMP_SWITCH_MS,
+	}
+}
+
+func rampDuration(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// rampPhase sends the before items with the first step and moves the gains to their targets within duration
+type rampPhase struct {
+	before   *focusritexml.Set
+	gains    map[int]int
+	duration time.Duration
+}
+
+// ramp runs one ramp at a time, one step per FC_SEND_INTERVAL, so every step gets its own send of the client queue
+type ramp struct {
+	mutex  sync.Mutex
+	values map[int]string // last value sent to or reported by the device, per element ID
+
+	runMutex sync.Mutex
+	cancel   chan struct{}
+	done     chan struct{}
+}
+
+func newRamp() *ramp {
+	return &ramp{
+		values: make(map[int]string),
+	}
+}
+
+// Track records the values of a set, ramps start from there
+func (r *ramp) Track(set *focusritexml.Set) {
+	for _, item := range set.Items {
+		r.TrackValue(item.ID, item.Value)
+	}
+}
+
+// TrackValue records a value reported by the device
+func (r *ramp) TrackValue(id int, value string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.values[id] = value
+}
+
+// Current returns the last known gain of an element, or fallback if it's unknown
+func (r *ramp) Current(id int, fallback int) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	v, err := strconv.Atoi(r.values[id])
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Bool returns the last known state of a switch, or fallback if it's unknown
+func (r *ramp) Bool(id int, fallback bool) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	v, err := strconv.ParseBool(r.values[id])
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Changed returns the items of set differing from the last known values
+func (r *ramp) Changed(set *focusritexml.Set) *focusritexml.Set {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	changed := focusritexml.NewSet(set.DevID)
+	for _, item := range set.Items {
+		if r.values[item.ID] != item.Value {
+			changed.AddItem(item)
+		}
+	}
+	return changed
+}
+
+// Reset forgets the values, e.g. when the device is gone
+func (r *ramp) Reset() {
+	r.Stop()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.values = make(map[int]string)
+}
+
+// Stop cancels a running ramp and waits until it stopped, the gains stay at the last step sent.
+// The items of the phases not started yet are dropped, the next command sends the switch states.
+func (r *ramp) Stop() {
+	r.runMutex.Lock()
+	defer r.runMutex.Unlock()
+	r.stop()
+}
+
+func (r *ramp) stop() {
+	if r.cancel == nil {
+		return
+	}
+	close(r.cancel)
+	<-r.done
+	r.cancel = nil
+	r.done = nil
+}
+
+// Start cancels a running ramp and runs the phases, send is called once per step.
+// send runs on the ramp goroutine and must not wait for a lock held by the callers of Stop.
+func (r *ramp) Start(deviceId int, phases []rampPhase, send func(*focusritexml.Set)) {
+	r.runMutex.Lock()
+	defer r.runMutex.Unlock()
+
+	r.stop()
+	r.cancel = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(deviceId, phases, send, r.cancel, r.done)
+}
+
+func (r *ramp) run(deviceId int, phases []rampPhase, send func(*focusritexml.Set), cancel chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(focusriteclient.FC_SEND_INTERVAL)
+	defer t.Stop()
+
+	for _, phase := range phases {
+		from := make(map[int]int, len(phase.gains))
+		for id, to := range phase.gains {
+			from[id] = r.Current(id, to)
+		}
+
+		steps := max(1, int(phase.duration/focusriteclient.FC_SEND_INTERVAL))
+		for step := 1; step <= steps; step++ {
+			set := focusritexml.NewSet(deviceId)
+			if step == 1 && phase.before != nil {
+				set.AddItems(phase.before.Items)
+			}
+			for id, to := range phase.gains {
+				set.AddItemInt(id, from[id]+(to-from[id])*step/steps)
+			}
+			if len(set.Items) > 0 {
+				send(set)
+			}
+
+			select {
+			case <-cancel:
+				return
+			case <-t.C:
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package fcaudioconnector
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	focusriteclient "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-client"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+// sentSets collects the sets sent by a ramp
+type sentSets struct {
+	mutex sync.Mutex
+	sets  []focusritexml.Set
+}
+
+func (s *sentSets) send(set *focusritexml.Set) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sets = append(s.sets, *set)
+}
+
+func (s *sentSets) items() map[int][]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	items := make(map[int][]string)
+	for _, set := range s.sets {
+		for _, item := range set.Items {
+			items[item.ID] = append(items[item.ID], item.Value)
+		}
+	}
+	return items
+}
+
+func TestRampCancelDropsPendingPhases(t *testing.T) {
+	const gain, mute = 1, 2
+
+	switches := focusritexml.NewSet(1)
+	switches.AddItemBool(mute, false)
+
+	sent := &sentSets{}
+	r := newRamp()
+	r.Start(1, []rampPhase{
+		{gains: map[int]int{gain: -127}, duration: 10 * focusriteclient.FC_SEND_INTERVAL},
+		{before: switches, gains: map[int]int{gain: -20}, duration: focusriteclient.FC_SEND_INTERVAL},
+	}, sent.send)
+
+	time.Sleep(2 * focusriteclient.FC_SEND_INTERVAL)
+	r.Stop()
+
+	items := sent.items()
+	if _, ok := items[mute]; ok {
+		t.Fatalf("switch of the cancelled phase sent: %v", items[mute])
+	}
+	if len(items[gain]) == 0 || len(items[gain]) >= 10 {
+		t.Fatalf("gain steps %v", items[gain])
+	}
+	for _, set := range sent.sets {
+		if len(set.Items) == 0 {
+			t.Fatal("empty set sent")
+		}
+	}
+}
+
+func TestRampSkipsEmptySteps(t *testing.T) {
+	sent := &sentSets{}
+	r := newRamp()
+	r.Start(1, []rampPhase{{gains: map[int]int{}, duration: 3 * focusriteclient.FC_SEND_INTERVAL}}, sent.send)
+	time.Sleep(5 * focusriteclient.FC_SEND_INTERVAL)
+	r.Stop()
+
+	if len(sent.sets) != 0 {
+		t.Fatalf("%d empty sets sent", len(sent.sets))
+	}
+}
+
+func TestRampChanged(t *testing.T) {
+	r := newRamp()
+	r.TrackValue(1, "true")
+	r.TrackValue(2, "false")
+
+	set := focusritexml.NewSet(1)
+	set.AddItemBool(1, true)
+	set.AddItemBool(2, true)
+	set.AddItemBool(3, false)
+
+	changed := r.Changed(set)
+	if len(changed.Items) != 2 || changed.Items[0].ID != 2 || changed.Items[1].ID != 3 {
+		t.Fatalf("changed items %v", changed.Items)
+	}
+}
@@ -0,0 +1,76 @@
+package focusritesimulator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ECUST-XX/xml"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+// simDevice is one simulated interface with its current element values
+type simDevice struct {
+	device  *focusritexml.Device
+	values  map[int]string
+	present bool
+}
+
+func newSimDevice(arrivalXml string) (*simDevice, error) {
+	arrivalXml = strings.TrimSpace(arrivalXml)
+
+	var arrival focusritexml.DeviceArrival
+	err := xml.Unmarshal([]byte(arrivalXml), &arrival)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &simDevice{
+		device: &arrival.Device,
+		values: make(map[int]string),
+	}
+	d.device.UpdateMap()
+	return d, nil
+}
+
+// apply stores all values of the set and updates the device model
+func (d *simDevice) apply(set focusritexml.Set) {
+	for _, item := range set.Items {
+		d.values[item.ID] = item.Value
+	}
+	d.device.UpdateSet(set)
+}
+
+// arrival returns the framed device-arrival of the current device, with all values set since it was added
+func (d *simDevice) arrival() (string, error) {
+	return focusritexml.ParseToXML(focusritexml.DeviceArrival{Device: *d.device})
+}
+
+// copyDevice returns a copy of the device model which doesn't share any elements
+func (d *simDevice) copyDevice() (*focusritexml.Device, error) {
+	data, err := xml.Marshal(d.device)
+	if err != nil {
+		return nil, err
+	}
+	var device focusritexml.Device
+	err = xml.Unmarshal(data, &device)
+	if err != nil {
+		return nil, err
+	}
+	device.UpdateMap()
+	return &device, nil
+}
+
+// valueSet returns all known values, sent to clients after subscribing
+func (d *simDevice) valueSet() focusritexml.Set {
+	ids := make([]int, 0, len(d.values))
+	for id := range d.values {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	set := focusritexml.NewSet(d.device.ID)
+	for _, id := range ids {
+		set.AddItemString(id, d.values[id])
+	}
+	return *set
+}
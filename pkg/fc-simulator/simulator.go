@@ -0,0 +1,547 @@
+package focusritesimulator
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ECUST-XX/xml"
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+	"github.com/sebastianrau/focusrite-mackie-control/pkg/logger"
+)
+
+var log *logger.CustomLogger = logger.WithPackage("focusrite-simulator")
+
+// Config of the simulated Focusrite Control server
+type Config struct {
+	Hostname       string
+	DiscoveryPorts []int // UDP ports answering client-discovery
+	TcpPort        int   // 0 = random free port
+	Authorised     bool  // approval state sent to new clients
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Hostname:       "Focusrite-Simulator.local",
+		DiscoveryPorts: []int{30096, 30097, 30098},
+		TcpPort:        0,
+		Authorised:     true,
+	}
+}
+
+// Server is a local stand-in for Focusrite Control.
+// It answers discovery requests, accepts TCP clients, announces its devices
+// and applies and echoes incoming sets like the real server does.
+type Server struct {
+	config Config
+
+	mu           sync.Mutex
+	devices      map[int]*simDevice
+	clients      map[*simClient]bool
+	nextClientId uint64
+
+	tcpListener  net.Listener
+	udpListeners []*net.UDPConn
+	wg           sync.WaitGroup
+}
+
+func NewServer(cfg Config) *Server {
+	return &Server{
+		config:       cfg,
+		devices:      make(map[int]*simDevice),
+		clients:      make(map[*simClient]bool),
+		nextClientId: 1,
+	}
+}
+
+// Start opens the TCP and discovery listeners
+func (s *Server) Start() error {
+	var err error
+	s.tcpListener, err = net.Listen("tcp4", fmt.Sprintf(":%d", s.config.TcpPort))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range s.config.DiscoveryPorts {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: p})
+		if err != nil {
+			s.Close()
+			return err
+		}
+		s.udpListeners = append(s.udpListeners, conn)
+		s.wg.Add(1)
+		go s.runDiscovery(conn)
+	}
+
+	s.wg.Add(1)
+	go s.runAccept()
+
+	log.Infof("Simulator listening on port %d", s.Port())
+	return nil
+}
+
+// Close stops all listeners and disconnects all clients
+func (s *Server) Close() error {
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
+	for _, u := range s.udpListeners {
+		u.Close()
+	}
+
+	s.mu.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Port returns the TCP port announced to clients
+func (s *Server) Port() int {
+	if s.tcpListener == nil {
+		return 0
+	}
+	return s.tcpListener.Addr().(*net.TCPAddr).Port
+}
+
+// AddDeviceFromFile loads a device-arrival fixture (e.g. xml/device-arrival-18i20.xml)
+func (s *Server) AddDeviceFromFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return s.AddDevice(string(data))
+}
+
+// AddDevice adds a device from its device-arrival xml and announces it to all clients
+func (s *Server) AddDevice(arrivalXml string) (int, error) {
+	d, err := newSimDevice(arrivalXml)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.devices[d.device.ID] = d
+	s.mu.Unlock()
+
+	s.ArriveDevice(d.device.ID)
+	return d.device.ID, nil
+}
+
+// LoadValuesFromFile applies a set fixture (e.g. xml/device-set.xml) to a device without sending it
+func (s *Server) LoadValuesFromFile(deviceId int, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var set focusritexml.Set
+	err = xml.Unmarshal(data, &set)
+	if err != nil {
+		return err
+	}
+	set.DevID = deviceId
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		return fmt.Errorf("unknown device %d", deviceId)
+	}
+	d.apply(set)
+	return nil
+}
+
+// RemoveDevice simulates unplugging a device
+func (s *Server) RemoveDevice(deviceId int) {
+	s.mu.Lock()
+	d, ok := s.devices[deviceId]
+	if !ok || !d.present {
+		s.mu.Unlock()
+		return
+	}
+	d.present = false
+	s.mu.Unlock()
+
+	log.Debugf("Removing device %d", deviceId)
+	s.broadcast(focusritexml.DeviceRemoval{Id: deviceId}, nil)
+}
+
+// ArriveDevice simulates plugging a known device in again
+func (s *Server) ArriveDevice(deviceId int) {
+	s.mu.Lock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	d.present = true
+	msg, err := d.arrival()
+	s.mu.Unlock()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	log.Debugf("Device %d arrived", deviceId)
+	s.broadcastRaw(msg, nil)
+}
+
+// SetApproval changes the approval state and notifies all clients
+func (s *Server) SetApproval(authorised bool) {
+	s.mu.Lock()
+	s.config.Authorised = authorised
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.send(c.approval(authorised))
+	}
+}
+
+// SendSet simulates a change made on the device or in Focusrite Control
+func (s *Server) SendSet(set focusritexml.Set) {
+	s.handleSet(set, nil)
+}
+
+// Device returns a copy of the device model of the simulator
+func (s *Server) Device(deviceId int) (*focusritexml.Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		return nil, false
+	}
+	device, err := d.copyDevice()
+	if err != nil {
+		log.Error(err.Error())
+		return nil, false
+	}
+	return device, true
+}
+
+// Value returns the current value of an element
+func (s *Server) Value(deviceId int, elementId int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		return "", false
+	}
+	v, ok := d.values[elementId]
+	return v, ok
+}
+
+// ClientCount returns the number of connected clients
+func (s *Server) ClientCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+func (s *Server) runDiscovery(conn *net.UDPConn) {
+	defer s.wg.Done()
+
+	buffer := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+
+		d, err := focusritexml.ParseFromXML(string(buffer[:n]))
+		if err != nil {
+			log.Warnf("invalid discovery request from %s: %s", addr, err.Error())
+			continue
+		}
+
+		req, ok := d.(focusritexml.ClientDiscovery)
+		if !ok {
+			log.Warnf("unexpected %T from %s", d, addr)
+			continue
+		}
+
+		msg, err := focusritexml.ParseToXML(focusritexml.ServerAnnouncement{
+			App:      req.App,
+			Version:  req.Version,
+			Hostname: s.config.Hostname,
+			Port:     s.Port(),
+		})
+		if err != nil {
+			log.Error(err.Error())
+			continue
+		}
+
+		_, err = conn.WriteToUDP([]byte(msg), addr)
+		if err != nil {
+			log.Warn(err.Error())
+		}
+	}
+}
+
+func (s *Server) runAccept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcpListener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		c := &simClient{
+			server:     s,
+			conn:       conn,
+			id:         strconv.FormatUint(s.nextClientId, 10),
+			subscribed: make(map[int]bool),
+		}
+		s.nextClientId++
+		s.clients[c] = true
+		s.mu.Unlock()
+
+		log.Debugf("Client %s connected from %s", c.id, conn.RemoteAddr())
+
+		s.wg.Add(1)
+		go c.run()
+	}
+}
+
+func (s *Server) removeClient(c *simClient) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	log.Debugf("Client %s disconnected", c.id)
+}
+
+// handleSet applies a set to the device model and echoes it to all subscribed clients
+func (s *Server) handleSet(set focusritexml.Set, from *simClient) {
+	s.mu.Lock()
+	d, ok := s.devices[set.DevID]
+	if !ok || !d.present {
+		s.mu.Unlock()
+		log.Warnf("set for unknown device %d", set.DevID)
+		return
+	}
+	d.apply(set)
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		if c.isSubscribed(set.DevID) {
+			c.sendXML(set)
+		}
+	}
+}
+
+func (s *Server) broadcast(data interface{}, except *simClient) {
+	msg, err := focusritexml.ParseToXML(data)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	s.broadcastRaw(msg, except)
+}
+
+func (s *Server) broadcastRaw(msg string, except *simClient) {
+	s.mu.Lock()
+	clients := s.clientList()
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		if c != except && c.isApproved() {
+			c.send(msg)
+		}
+	}
+}
+
+// clientList must be called with s.mu locked
+func (s *Server) clientList() []*simClient {
+	clients := make([]*simClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// arrivals returns the framed device-arrivals of all plugged devices sorted by ID
+func (s *Server) arrivals() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := ""
+	for _, d := range s.presentDevices() {
+		arrival, err := d.arrival()
+		if err != nil {
+			log.Error(err.Error())
+			continue
+		}
+		msg += arrival
+	}
+	return msg
+}
+
+// presentDevices returns all plugged devices sorted by ID, must be called with s.mu locked
+func (s *Server) presentDevices() []*simDevice {
+	devices := make([]*simDevice, 0, len(s.devices))
+	for _, d := range s.devices {
+		if d.present {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].device.ID < devices[j].device.ID })
+	return devices
+}
+
+type simClient struct {
+	server *Server
+	conn   net.Conn
+	id     string
+
+	mu         sync.Mutex
+	approved   bool
+	subscribed map[int]bool
+}
+
+func (c *simClient) run() {
+	defer c.server.wg.Done()
+	defer c.server.removeClient(c)
+	defer c.conn.Close()
+
+	decoder := focusritexml.NewFrameDecoder()
+	buf := make([]byte, 65536)
+	for {
+		n, err := c.conn.Read(buf)
+		if n > 0 {
+			decoder.Write(buf[:n])
+			for {
+				packet, ok := decoder.Next()
+				if !ok {
+					break
+				}
+				c.handlePacket(packet)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Debugf("Client %s: %s", c.id, err.Error())
+			}
+			return
+		}
+	}
+}
+
+func (c *simClient) handlePacket(packet string) {
+	d, err := focusritexml.ParseFromXML(packet)
+	if err != nil {
+		log.Warnf("Client %s: %s", c.id, err.Error())
+		return
+	}
+
+	switch m := d.(type) {
+	case focusritexml.ClientDetails:
+		log.Debugf("Client %s: details hostname=%s key=%s", c.id, m.Hostname, m.ClientKey)
+		c.sendXML(focusritexml.ClientDetails{Id: c.id})
+
+		c.server.mu.Lock()
+		authorised := c.server.config.Authorised
+		c.server.mu.Unlock()
+		c.send(c.approval(authorised))
+
+	case focusritexml.SubscribeMessage:
+		c.mu.Lock()
+		c.subscribed[m.DeviceId] = m.Subscribe
+		c.mu.Unlock()
+
+		if m.Subscribe {
+			c.server.mu.Lock()
+			dev, ok := c.server.devices[m.DeviceId]
+			var set focusritexml.Set
+			if ok {
+				set = dev.valueSet()
+			}
+			c.server.mu.Unlock()
+			if ok && len(set.Items) > 0 {
+				c.sendXML(set)
+			}
+		}
+
+	case focusritexml.Set:
+		if !c.isApproved() {
+			log.Warnf("Client %s: set without approval ignored", c.id)
+			return
+		}
+		c.server.handleSet(m, c)
+
+	case focusritexml.KeepAlive:
+		c.sendXML(focusritexml.KeepAlive{})
+
+	case focusritexml.UnknownMessage:
+		c.sendXML(focusritexml.ErrorMessage{Message: "unknown message " + m.Name})
+
+	default:
+		log.Warnf("Client %s: unhandled message %T", c.id, d)
+	}
+}
+
+// approval returns the approval message and sends the device list on a positive approval
+func (c *simClient) approval(authorised bool) string {
+	c.mu.Lock()
+	wasApproved := c.approved
+	c.approved = authorised
+	c.mu.Unlock()
+
+	msg, err := focusritexml.ParseToXML(focusritexml.Approval{
+		Hostname:   c.server.config.Hostname,
+		Id:         c.id,
+		Type:       "response",
+		Authorised: authorised,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return ""
+	}
+
+	if authorised && !wasApproved {
+		msg += c.server.arrivals()
+	}
+	return msg
+}
+
+func (c *simClient) isApproved() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.approved
+}
+
+func (c *simClient) isSubscribed(deviceId int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscribed[deviceId]
+}
+
+func (c *simClient) sendXML(data interface{}) {
+	msg, err := focusritexml.ParseToXML(data)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	c.send(msg)
+}
+
+func (c *simClient) send(msg string) {
+	if msg == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write([]byte(msg))
+	if err != nil {
+		log.Debugf("Client %s: %s", c.id, err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package focusritesimulator
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	focusritexml "github.com/sebastianrau/focusrite-mackie-control/pkg/fc-xml"
+)
+
+const (
+	testFixture   string = "../../xml/device-arrival-18i20.xml"
+	testNickname  int    = 1256 // nickname of input Analogue 1
+	testReadLimit        = 2 * time.Second
+)
+
+// testClient is a raw TCP client reading the framed messages of the simulator
+type testClient struct {
+	t       *testing.T
+	conn    net.Conn
+	decoder *focusritexml.FrameDecoder
+}
+
+func newTestServer(t *testing.T) (*Server, int) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.DiscoveryPorts = nil
+	s := NewServer(cfg)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	id, err := s.AddDeviceFromFile(testFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, id
+}
+
+func dial(t *testing.T, s *Server) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp4", fmt.Sprintf("127.0.0.1:%d", s.Port()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn, decoder: focusritexml.NewFrameDecoder()}
+}
+
+func (c *testClient) send(data interface{}) {
+	c.t.Helper()
+	msg, err := focusritexml.ParseToXML(data)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.conn.Write([]byte(msg)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next message, it fails the test if none arrives in time
+func (c *testClient) next() interface{} {
+	c.t.Helper()
+	buf := make([]byte, 65536)
+	c.conn.SetReadDeadline(time.Now().Add(testReadLimit))
+	for {
+		if frame, ok := c.decoder.Next(); ok {
+			msg, err := focusritexml.ParseFromXML(frame)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			return msg
+		}
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			c.t.Fatalf("no message: %v", err)
+		}
+		c.decoder.Write(buf[:n])
+	}
+}
+
+// expect skips messages until one of type T arrives
+func expect[T any](c *testClient) T {
+	c.t.Helper()
+	for {
+		if m, ok := c.next().(T); ok {
+			return m
+		}
+	}
+}
+
+// connect runs the handshake up to the device arrival
+func (c *testClient) connect() focusritexml.DeviceArrival {
+	c.t.Helper()
+	c.send(focusritexml.ClientDetails{Hostname: "test", ClientKey: "key"})
+	if details := expect[focusritexml.ClientDetails](c); details.Id == "" {
+		c.t.Fatal("no client id")
+	}
+	if approval := expect[focusritexml.Approval](c); !approval.Authorised {
+		c.t.Fatal("not approved")
+	}
+	return expect[focusritexml.DeviceArrival](c)
+}
+
+func TestHandshake(t *testing.T) {
+	s, id := newTestServer(t)
+	c := dial(t, s)
+
+	arrival := c.connect()
+	if arrival.Device.ID != id || arrival.Device.Model != "Scarlett 18i20 (3rd Gen)" {
+		t.Fatalf("unexpected arrival %d %s", arrival.Device.ID, arrival.Device.Model)
+	}
+}
+
+func TestSetIsAppliedAndEchoed(t *testing.T) {
+	s, id := newTestServer(t)
+	a, b := dial(t, s), dial(t, s)
+	a.connect()
+	b.connect()
+	a.send(focusritexml.SubscribeMessage{DeviceId: id, Subscribe: true})
+	b.send(focusritexml.SubscribeMessage{DeviceId: id, Subscribe: true})
+	time.Sleep(50 * time.Millisecond)
+
+	set := focusritexml.NewSet(id)
+	set.AddItemString(testNickname, "Kick")
+	a.send(*set)
+
+	for _, c := range []*testClient{a, b} {
+		echo := expect[focusritexml.Set](c)
+		for len(echo.Items) != 1 {
+			echo = expect[focusritexml.Set](c) // the values sent after subscribing
+		}
+		if echo.Items[0].ID != testNickname || echo.Items[0].Value != "Kick" {
+			t.Fatalf("unexpected echo %v", echo.Items)
+		}
+	}
+	if v, _ := s.Value(id, testNickname); v != "Kick" {
+		t.Fatalf("value %q", v)
+	}
+}
+
+func TestRearrivalKeepsValues(t *testing.T) {
+	s, id := newTestServer(t)
+	c := dial(t, s)
+	c.connect()
+
+	set := focusritexml.NewSet(id)
+	set.AddItemString(testNickname, "Kick")
+	s.SendSet(*set)
+
+	s.RemoveDevice(id)
+	if removal := expect[focusritexml.DeviceRemoval](c); removal.Id != id {
+		t.Fatalf("removal of %d", removal.Id)
+	}
+
+	s.ArriveDevice(id)
+	arrival := expect[focusritexml.DeviceArrival](c)
+	if nickname := arrival.Device.Inputs.Analogues[0].Nickname.Value; nickname != "Kick" {
+		t.Fatalf("nickname after re-arrival %q", nickname)
+	}
+}
+
+func TestDeviceIsCopy(t *testing.T) {
+	s, id := newTestServer(t)
+
+	device, ok := s.Device(id)
+	if !ok {
+		t.Fatal("no device")
+	}
+	device.Inputs.Analogues[0].Nickname.Value = "Changed"
+
+	set := focusritexml.NewSet(id)
+	set.AddItemString(testNickname, "Kick")
+	s.SendSet(*set)
+
+	if device.Inputs.Analogues[0].Nickname.Value != "Changed" {
+		t.Fatal("copy changed by a set")
+	}
+	again, _ := s.Device(id)
+	if again.Inputs.Analogues[0].Nickname.Value != "Kick" {
+		t.Fatalf("device value %q", again.Inputs.Analogues[0].Nickname.Value)
+	}
+}
@@ -0,0 +1,143 @@
+package focusritexml
+
+import (
+	"sync"
+)
+
+// ElementChange is passed to a ChangeHandler when an element value changed.
+// Old and New hold the typed value (string, int, bool or float64).
+type ElementChange struct {
+	DeviceId int
+	ID       int
+	Old      interface{}
+	New      interface{}
+}
+
+// ChangeHandler is called from the goroutine calling UpdateSet or Notify, it must not block.
+// The focusrite client calls it from its connection goroutine for the values reported by the server
+// and from its send queue goroutine for the values it sends, so a handler may run on both at the same time.
+type ChangeHandler func(change ElementChange)
+
+// Subscription of a ChangeHandler to some elements of a device
+type Subscription struct {
+	ids       map[int]bool
+	handler   ChangeHandler
+	observers *deviceObservers
+}
+
+// deviceObservers is shared by all copies of a Device
+type deviceObservers struct {
+	mutex         sync.Mutex
+	subscriptions []*Subscription
+}
+
+// Subscribe calls handler when the value of the element with the given ID changes
+func (d *Device) Subscribe(id int, handler ChangeHandler) *Subscription {
+	return d.SubscribeIds([]int{id}, handler)
+}
+
+// SubscribeIds calls handler when the value of one of the given elements changes
+func (d *Device) SubscribeIds(ids []int, handler ChangeHandler) *Subscription {
+	s := &Subscription{
+		ids:       make(map[int]bool, len(ids)),
+		handler:   handler,
+		observers: d.observerList(),
+	}
+	for _, id := range ids {
+		if id != 0 {
+			s.ids[id] = true
+		}
+	}
+
+	s.observers.mutex.Lock()
+	s.observers.subscriptions = append(s.observers.subscriptions, s)
+	s.observers.mutex.Unlock()
+	return s
+}
+
+// SubscribeTree calls handler when any element in subtree changes,
+// e.g. d.SubscribeTree(&d.Outputs, handler) for all outputs.
+func (d *Device) SubscribeTree(subtree ElementContainer, handler ChangeHandler) *Subscription {
+	elements := make(ElementIndex)
+	subtree.IndexElements(elements)
+
+	ids := make([]int, 0, len(elements))
+	for id := range elements {
+		ids = append(ids, id)
+	}
+	return d.SubscribeIds(ids, handler)
+}
+
+// Unsubscribe removes the subscription, the handler is not called afterwards
+func (s *Subscription) Unsubscribe() {
+	s.observers.mutex.Lock()
+	defer s.observers.mutex.Unlock()
+
+	for i, sub := range s.observers.subscriptions {
+		if sub == s {
+			s.observers.subscriptions = append(s.observers.subscriptions[:i], s.observers.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Ids returns the subscribed element IDs
+func (s *Subscription) Ids() []int {
+	ids := make([]int, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Value returns the typed value of the element with the given ID
+func (d *Device) Value(id int) (interface{}, bool) {
+	e, ok := d.elementsMap[id]
+	if !ok {
+		return nil, false
+	}
+	return e.Get(), true
+}
+
+func (d *Device) observerList() *deviceObservers {
+	if d.observers == nil {
+		d.observers = &deviceObservers{}
+	}
+	return d.observers
+}
+
+func (d *Device) hasObservers() bool {
+	if d.observers == nil {
+		return false
+	}
+	d.observers.mutex.Lock()
+	defer d.observers.mutex.Unlock()
+	return len(d.observers.subscriptions) > 0
+}
+
+// Notify calls the handlers of the changes returned by ApplySet, outside the lock, so handlers may (un)subscribe
+func (d *Device) Notify(changes []ElementChange) {
+	if len(changes) == 0 || d.observers == nil {
+		return
+	}
+
+	type call struct {
+		handler ChangeHandler
+		change  ElementChange
+	}
+	calls := make([]call, 0, len(changes))
+
+	d.observers.mutex.Lock()
+	for _, c := range changes {
+		for _, s := range d.observers.subscriptions {
+			if s.ids[c.ID] {
+				calls = append(calls, call{handler: s.handler, change: c})
+			}
+		}
+	}
+	d.observers.mutex.Unlock()
+
+	for _, c := range calls {
+		c.handler(c.change)
+	}
+}
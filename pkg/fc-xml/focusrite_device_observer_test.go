@@ -0,0 +1,207 @@
+package focusritexml
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// changeRecorder collects the changes passed to its handler
+type changeRecorder struct {
+	mutex   sync.Mutex
+	changes []ElementChange
+}
+
+func (r *changeRecorder) handle(change ElementChange) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *changeRecorder) received() []ElementChange {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.changes)
+}
+
+func checkChanges(t *testing.T, got []ElementChange, want []ElementChange) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+// the handler gets the typed old and new values of real changes only
+func TestSubscribeValueChanges(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	out := &d.Outputs.Analogues[0]
+	in := &d.Inputs.Analogues[0]
+	oldGain, oldMute, oldMeter, oldNickname := out.Gain.Value, out.Mute.Value, out.Meter.Value, in.Nickname.Value
+
+	r := &changeRecorder{}
+	d.SubscribeIds([]int{out.Gain.ID, out.Mute.ID, out.Meter.ID, in.Nickname.ID}, r.handle)
+
+	same := NewSet(d.ID)
+	same.AddItemInt(out.Gain.ID, oldGain)
+	same.AddItemBool(out.Mute.ID, oldMute)
+	same.AddItemString(in.Nickname.ID, oldNickname)
+	d.UpdateSet(*same)
+	checkChanges(t, r.received(), nil)
+
+	set := NewSet(d.ID)
+	set.AddItemInt(out.Gain.ID, oldGain-10)
+	set.AddItemBool(out.Mute.ID, !oldMute)
+	set.AddItemString(out.Meter.ID, "-12.5")
+	set.AddItemString(in.Nickname.ID, "Kick")
+	set.AddItemBool(d.Outputs.Analogues[1].Mute.ID, !d.Outputs.Analogues[1].Mute.Value) // not subscribed
+	d.UpdateSet(*set)
+
+	checkChanges(t, r.received(), []ElementChange{
+		{DeviceId: d.ID, ID: out.Gain.ID, Old: oldGain, New: oldGain - 10},
+		{DeviceId: d.ID, ID: out.Mute.ID, Old: oldMute, New: !oldMute},
+		{DeviceId: d.ID, ID: out.Meter.ID, Old: oldMeter, New: -12.5},
+		{DeviceId: d.ID, ID: in.Nickname.ID, Old: oldNickname, New: "Kick"},
+	})
+}
+
+func TestSubscribeTree(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	out := &d.Outputs.Analogues[2]
+	oldGain := out.Gain.Value
+
+	r := &changeRecorder{}
+	d.SubscribeTree(&d.Outputs, r.handle)
+
+	set := NewSet(d.ID)
+	set.AddItemInt(out.Gain.ID, oldGain-10)
+	set.AddItemString(d.Inputs.Analogues[0].Nickname.ID, "Kick")
+	d.UpdateSet(*set)
+
+	checkChanges(t, r.received(), []ElementChange{
+		{DeviceId: d.ID, ID: out.Gain.ID, Old: oldGain, New: oldGain - 10},
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	gain := d.Outputs.Analogues[0].Gain.ID
+
+	r, other := &changeRecorder{}, &changeRecorder{}
+	s := d.Subscribe(gain, r.handle)
+	d.Subscribe(gain, other.handle)
+
+	set := NewSet(d.ID)
+	set.AddItemInt(gain, -30)
+	d.UpdateSet(*set)
+	s.Unsubscribe()
+	set = NewSet(d.ID)
+	set.AddItemInt(gain, -40)
+	d.UpdateSet(*set)
+
+	if n := len(r.received()); n != 1 {
+		t.Errorf("unsubscribed handler called %d times", n)
+	}
+	if n := len(other.received()); n != 2 {
+		t.Errorf("other handler called %d times", n)
+	}
+}
+
+// the focusrite client hands out copies of its device models, subscribing to a copy observes the model
+func TestSubscriptionSharedByCopies(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	gain := d.Outputs.Analogues[0].Gain.ID
+
+	arrival := *d
+	r := &changeRecorder{}
+	arrival.Subscribe(gain, r.handle)
+
+	set := NewSet(d.ID)
+	set.AddItemInt(gain, -30)
+	d.UpdateSet(*set)
+	if n := len(r.received()); n != 1 {
+		t.Fatalf("handler of the copy called %d times", n)
+	}
+
+	// a copy with its own index notifies the subscribers of the model as well
+	copied := *d
+	copied.UpdateMap()
+	set = NewSet(d.ID)
+	set.AddItemInt(gain, -40)
+	copied.UpdateSet(*set)
+	if n := len(r.received()); n != 2 {
+		t.Fatalf("handler called %d times for the changes of a copy", n)
+	}
+}
+
+func TestApplySetNotifiesLater(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	gain := d.Outputs.Analogues[0].Gain.ID
+
+	r := &changeRecorder{}
+	d.Subscribe(gain, r.handle)
+
+	set := NewSet(d.ID)
+	set.AddItemInt(gain, -30)
+	n, changes := d.ApplySet(*set)
+	if n != 1 || len(changes) != 1 {
+		t.Fatalf("applied %d items, %d changes", n, len(changes))
+	}
+	checkChanges(t, r.received(), nil)
+
+	d.Notify(changes)
+	checkChanges(t, r.received(), changes)
+}
+
+// like the focusrite client: the sets are applied under a lock, the handlers run outside of it on several goroutines
+// while subscriptions come and go, run with -race
+func TestNotifyConcurrently(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	gain := d.Outputs.Analogues[0].Gain.ID
+	nickname := d.Inputs.Analogues[0].Nickname.ID
+
+	r := &changeRecorder{}
+	d.SubscribeIds([]int{gain, nickname}, r.handle)
+
+	var lock sync.Mutex
+	apply := func(set *Set) {
+		lock.Lock()
+		_, changes := d.ApplySet(*set)
+		lock.Unlock()
+		d.Notify(changes)
+	}
+
+	const updates = 200
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			set := NewSet(d.ID)
+			set.AddItemInt(gain, -i)
+			apply(set)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			set := NewSet(d.ID)
+			set.AddItemInt(nickname, i)
+			apply(set)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			d.Subscribe(gain, func(ElementChange) {}).Unsubscribe()
+		}
+	}()
+	wg.Wait()
+
+	if n := len(r.received()); n != 2*updates {
+		t.Fatalf("%d changes, want %d", n, 2*updates)
+	}
+}
@@ -0,0 +1,361 @@
+package focusritexml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Elements are addressed by the xml element names from the device root, separated by "/".
+// Lists are filtered by an attribute or a 1-based index:
+//
+//	outputs/analogue[name="Monitor Output 1"]/mute
+//	monitoring/hardware-controls/hardware-controls/dim
+//	mixer/mixes/mix[2]/input[1]/gain
+//
+// Paths don't depend on the element IDs, so they survive firmware updates and work on other devices of the same family.
+
+type ElementType int
+
+const (
+	ElementTypeUnknown ElementType = iota
+	ElementTypeString
+	ElementTypeInt
+	ElementTypeBool
+	ElementTypeFloat
+)
+
+var elementTypeName map[ElementType]string = map[ElementType]string{
+	ElementTypeUnknown: "unknown",
+	ElementTypeString:  "string",
+	ElementTypeInt:     "int",
+	ElementTypeBool:    "bool",
+	ElementTypeFloat:   "float",
+}
+
+func (t ElementType) String() string {
+	return elementTypeName[t]
+}
+
+// ElementPath is the result of a reverse lookup
+type ElementPath struct {
+	Path string
+	Type ElementType
+}
+
+type pathSegment struct {
+	name      string
+	attr      string // filter by attribute value
+	value     string
+	index     int // filter by 1-based index, 0 if unused
+	predicate string
+}
+
+// Resolve returns the ID of the element addressed by path
+func (d *Device) Resolve(path string) (int, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	val := reflect.ValueOf(d).Elem()
+	for i := 0; i < len(segments); {
+		var n int
+		val, n, err = resolveSegment(val, segments[i:])
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", strings.Join(segmentNames(segments[:i+max(n, 1)]), "/"), err.Error())
+		}
+		i += n
+	}
+
+	e, ok := asElement(val)
+	if !ok {
+		return 0, fmt.Errorf("%s is no element", path)
+	}
+	if e.Id() == 0 {
+		return 0, fmt.Errorf("%s is not available on %s", path, d.Model)
+	}
+	return e.Id(), nil
+}
+
+// PathOf returns path and value type of the element with the given ID
+func (d *Device) PathOf(id int) (ElementPath, bool) {
+	p, ok := d.pathIndex()[id]
+	return p, ok
+}
+
+func (d *Device) pathIndex() map[int]ElementPath {
+	if d.paths == nil {
+		d.paths = make(map[int]ElementPath)
+		collectPaths(reflect.ValueOf(d).Elem(), "", d.paths)
+	}
+	return d.paths
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	segments := make([]pathSegment, 0)
+	for len(path) > 0 {
+		// split at the next "/" outside of a predicate, names may contain "/".
+		// ">" of raw xml tags like "mixes>mix" is a separator as well, paths exported by older versions contain them.
+		end := len(path)
+		quoted := false
+		for i, c := range path {
+			if c == '"' {
+				quoted = !quoted
+			}
+			if (c == '/' || c == '>') && !quoted {
+				end = i
+				break
+			}
+		}
+
+		seg, err := parseSegment(path[:end])
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+		path = path[min(end+1, len(path)):]
+	}
+	return segments, nil
+}
+
+func parseSegment(s string) (pathSegment, error) {
+	open := strings.Index(s, "[")
+	if open < 0 {
+		return pathSegment{name: s}, nil
+	}
+	if !strings.HasSuffix(s, "]") {
+		return pathSegment{}, fmt.Errorf("invalid path segment %q", s)
+	}
+
+	seg := pathSegment{
+		name:      s[:open],
+		predicate: s[open+1 : len(s)-1],
+	}
+
+	if idx, err := strconv.Atoi(seg.predicate); err == nil {
+		if idx < 1 {
+			return pathSegment{}, fmt.Errorf("invalid index in %q, first element is 1", s)
+		}
+		seg.index = idx
+		return seg, nil
+	}
+
+	attr, value, ok := strings.Cut(seg.predicate, "=")
+	if !ok {
+		return pathSegment{}, fmt.Errorf("invalid predicate in %q", s)
+	}
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != value[len(value)-1] || (value[0] != '"' && value[0] != '\'') {
+		return pathSegment{}, fmt.Errorf("value in %q must be quoted", s)
+	}
+	seg.attr = strings.TrimSpace(attr)
+	seg.value = value[1 : len(value)-1]
+	return seg, nil
+}
+
+func segmentNames(segments []pathSegment) []string {
+	names := make([]string, len(segments))
+	for i, s := range segments {
+		names[i] = s.name
+		if s.predicate != "" {
+			names[i] += "[" + s.predicate + "]"
+		}
+	}
+	return names
+}
+
+// resolveSegment resolves the first segments, it returns the number of segments used.
+// Fields of nested xml tags like "mixes>mix" use one segment per tag.
+func resolveSegment(val reflect.Value, segments []pathSegment) (reflect.Value, int, error) {
+	field, n, ok := childByName(val, segments)
+	if !ok {
+		return reflect.Value{}, 1, fmt.Errorf("no element %q", segments[0].name)
+	}
+	seg := segments[n-1]
+	for _, wrapper := range segments[:n-1] {
+		if wrapper.predicate != "" {
+			return reflect.Value{}, n, fmt.Errorf("%s can't be filtered, it has no attributes", wrapper.name)
+		}
+	}
+	v, err := filterSegment(field, seg)
+	return v, n, err
+}
+
+func filterSegment(field reflect.Value, seg pathSegment) (reflect.Value, error) {
+
+	if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+		if seg.predicate != "" && seg.index != 1 && !matchAttr(field, seg.attr, seg.value) {
+			return reflect.Value{}, fmt.Errorf("no %s with [%s]", seg.name, seg.predicate)
+		}
+		return field, nil
+	}
+
+	if seg.index > 0 {
+		if seg.index > field.Len() {
+			return reflect.Value{}, fmt.Errorf("only %d %s elements", field.Len(), seg.name)
+		}
+		return field.Index(seg.index - 1), nil
+	}
+
+	if seg.attr == "" {
+		if field.Len() == 1 {
+			return field.Index(0), nil
+		}
+		return reflect.Value{}, fmt.Errorf("%d %s elements, select one by attribute or index", field.Len(), seg.name)
+	}
+
+	for i := 0; i < field.Len(); i++ {
+		if matchAttr(field.Index(i), seg.attr, seg.value) {
+			return field.Index(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("no %s with [%s]", seg.name, seg.predicate)
+}
+
+// childByName returns the field with the xml element names of the first segments and the number of segments matched
+func childByName(val reflect.Value, segments []pathSegment) (reflect.Value, int, bool) {
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, 0, false
+	}
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := xmlElementName(t.Field(i))
+		if !ok {
+			continue
+		}
+		tags := strings.Split(name, ">")
+		if len(tags) > len(segments) {
+			continue
+		}
+		matched := true
+		for j, tag := range tags {
+			if segments[j].name != tag {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return val.Field(i), len(tags), true
+		}
+	}
+	return reflect.Value{}, 0, false
+}
+
+func matchAttr(val reflect.Value, attr string, value string) bool {
+	if val.Kind() != reflect.Struct {
+		return false
+	}
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if n, ok := xmlAttrName(t.Field(i)); ok && n == attr {
+			return fmt.Sprint(val.Field(i).Interface()) == value
+		}
+	}
+	return false
+}
+
+// collectPaths stores the canonical path of every element with an ID.
+// List entries are addressed by name if it is unique, else by index.
+func collectPaths(val reflect.Value, prefix string, paths map[int]ElementPath) {
+	if e, ok := asElement(val); ok {
+		if e.Id() != 0 {
+			paths[e.Id()] = ElementPath{Path: prefix, Type: elementType(val)}
+		}
+		return
+	}
+	if val.Kind() != reflect.Struct {
+		return
+	}
+
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := xmlElementName(t.Field(i))
+		if !ok {
+			continue
+		}
+		path := strings.ReplaceAll(name, ">", "/")
+		if prefix != "" {
+			path = prefix + "/" + path
+		}
+
+		field := val.Field(i)
+		if field.Kind() != reflect.Slice && field.Kind() != reflect.Array {
+			collectPaths(field, path, paths)
+			continue
+		}
+
+		names := make(map[string]int)
+		for j := 0; j < field.Len(); j++ {
+			names[attrValue(field.Index(j), "name")]++
+		}
+		for j := 0; j < field.Len(); j++ {
+			n := attrValue(field.Index(j), "name")
+			if n != "" && names[n] == 1 && !strings.ContainsAny(n, "\"") {
+				collectPaths(field.Index(j), fmt.Sprintf("%s[name=%q]", path, n), paths)
+			} else {
+				collectPaths(field.Index(j), fmt.Sprintf("%s[%d]", path, j+1), paths)
+			}
+		}
+	}
+}
+
+func attrValue(val reflect.Value, attr string) string {
+	if val.Kind() != reflect.Struct {
+		return ""
+	}
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if n, ok := xmlAttrName(t.Field(i)); ok && n == attr {
+			return fmt.Sprint(val.Field(i).Interface())
+		}
+	}
+	return ""
+}
+
+func xmlElementName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() || f.Name == "XMLName" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(f.Tag.Get("xml"), ",")
+	if name == "" || name == "-" || opts != "" {
+		return "", false
+	}
+	return name, true
+}
+
+func xmlAttrName(f reflect.StructField) (string, bool) {
+	name, opts, _ := strings.Cut(f.Tag.Get("xml"), ",")
+	if !f.IsExported() || name == "" || !strings.Contains(opts, "attr") {
+		return "", false
+	}
+	return name, true
+}
+
+func asElement(val reflect.Value) (Elements, bool) {
+	if !val.IsValid() || !val.CanAddr() {
+		return nil, false
+	}
+	e, ok := val.Addr().Interface().(Elements)
+	return e, ok
+}
+
+func elementType(val reflect.Value) ElementType {
+	switch val.Interface().(type) {
+	case ElementString:
+		return ElementTypeString
+	case ElementInt:
+		return ElementTypeInt
+	case ElementBool:
+		return ElementTypeBool
+	case ElementFloat:
+		return ElementTypeFloat
+	}
+	return ElementTypeUnknown
+}
@@ -0,0 +1,91 @@
+package focusritexml
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ECUST-XX/xml"
+)
+
+// loadDevice parses a device-arrival fixture and builds the element index
+func loadDevice(t testing.TB, path string) *Device {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var arrival DeviceArrival
+	if err := xml.Unmarshal(data, &arrival); err != nil {
+		t.Fatal(err)
+	}
+	arrival.Device.UpdateMap()
+	return &arrival.Device
+}
+
+func TestResolveDocumentedPaths(t *testing.T) {
+	d := loadDevice(t, "../../xml/device-arrival-18i20.xml")
+
+	tests := []struct {
+		path string
+		id   int
+	}{
+		{`outputs/analogue[name="Monitor Output 1"]/mute`, d.Outputs.Analogues[0].Mute.ID},
+		{`monitoring/hardware-controls/hardware-controls/dim`, d.Monitoring.HardwareControls.Controls.Dim.ID},
+		{`mixer/mixes/mix[2]/input[1]/gain`, d.Mixer.Mixes[1].Inputs[0].Gain.ID},
+		{`mixer/mixes>mix[2]/input[1]/gain`, d.Mixer.Mixes[1].Inputs[0].Gain.ID}, // exported by older versions
+	}
+	for _, tt := range tests {
+		id, err := d.Resolve(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if id == 0 || id != tt.id {
+			t.Errorf("%s: got id %d, want %d", tt.path, id, tt.id)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	d := loadDevice(t, "../../xml/device-arrival-18i20.xml")
+
+	for _, path := range []string{
+		`mixer/mixes[1]/mix[2]/input[1]/gain`,
+		`outputs/analogue[name="No Output"]/mute`,
+		`outputs/analogue/mute`,
+		`mixer/mixes/mix[99]/input[1]/gain`,
+	} {
+		if id, err := d.Resolve(path); err == nil {
+			t.Errorf("%s: resolved to %d", path, id)
+		}
+	}
+}
+
+// every element is found by its path and the path resolves to the element again
+func TestPathOfRoundTrip(t *testing.T) {
+	d := loadDevice(t, "../../xml/device-arrival-18i20.xml")
+
+	if len(d.elementsMap) == 0 {
+		t.Fatal("no elements")
+	}
+	for id := range d.elementsMap {
+		p, ok := d.PathOf(id)
+		if !ok {
+			t.Errorf("no path for %d", id)
+			continue
+		}
+		if strings.Contains(p.Path, ">") {
+			t.Errorf("%d: raw xml tag in path %s", id, p.Path)
+		}
+		resolved, err := d.Resolve(p.Path)
+		if err != nil || resolved != id {
+			t.Errorf("%d: %s resolves to %d: %v", id, p.Path, resolved, err)
+		}
+	}
+
+	p, _ := d.PathOf(d.Mixer.Mixes[1].Inputs[0].Gain.ID)
+	if !strings.HasPrefix(p.Path, "mixer/mixes/mix[") || p.Type != ElementTypeInt {
+		t.Errorf("mix input gain path %s (%s)", p.Path, p.Type)
+	}
+}
@@ -0,0 +1,157 @@
+package focusritexml
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const SNAPSHOT_VERSION int = 1
+
+// not part of a snapshot: read only values, meters and actions
+var snapshotExclude map[string]bool = map[string]bool{
+	"meter":            true,
+	"available":        true,
+	"state":            true,
+	"snapshot":         true,
+	"save-snapshot":    true,
+	"reset-device":     true,
+	"record-outputs":   true,
+	"pairable-devices": true,
+	"firmware":         true,
+	"dante":            true,
+}
+
+// DeviceSnapshot holds the element values of a device keyed by element path,
+// so it can be restored on a device with other IDs.
+type DeviceSnapshot struct {
+	Version      int                    `json:"version" yaml:"version"`
+	Model        string                 `json:"model" yaml:"model"`
+	SerialNumber string                 `json:"serial-number" yaml:"serial-number"`
+	Created      time.Time              `json:"created" yaml:"created"`
+	Values       map[string]interface{} `json:"values" yaml:"values"`
+}
+
+// ExportSnapshot exports the current values of all writable elements
+func (d *Device) ExportSnapshot() *DeviceSnapshot {
+	s := &DeviceSnapshot{
+		Version:      SNAPSHOT_VERSION,
+		Model:        d.Model,
+		SerialNumber: d.SerialNumber,
+		Created:      time.Now(),
+		Values:       make(map[string]interface{}),
+	}
+
+	for id, p := range d.pathIndex() {
+		if excludedFromSnapshot(p.Path) {
+			continue
+		}
+		value, ok := d.Value(id)
+		if ok {
+			s.Values[p.Path] = value
+		}
+	}
+	return s
+}
+
+// RestoreSet returns a set with all values of the snapshot that differ from the device.
+// Paths that don't exist on the device or invalid values are returned as errors, the rest is restored anyway.
+func (d *Device) RestoreSet(s *DeviceSnapshot) (*Set, []error) {
+	set := NewSet(d.ID)
+	errs := make([]error, 0)
+
+	paths := make([]string, 0, len(s.Values))
+	for p := range s.Values {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		id, err := d.Resolve(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		current, ok := d.elementsMap[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: element %d not indexed", path, id))
+			continue
+		}
+
+		value := formatValue(s.Values[path])
+		restored := newElement(current)
+		err = restored.Set(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q: %s", path, value, err.Error()))
+			continue
+		}
+
+		if restored.Get() != current.Get() {
+			set.AddItemString(id, value)
+		}
+	}
+	return set, errs
+}
+
+// JSON encodes the snapshot
+func (s *DeviceSnapshot) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// YAML encodes the snapshot
+func (s *DeviceSnapshot) YAML() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+// ParseSnapshot reads a snapshot from YAML or JSON
+func ParseSnapshot(data []byte) (*DeviceSnapshot, error) {
+	var s DeviceSnapshot
+	err := yaml.Unmarshal(data, &s) // JSON is valid YAML
+	if err != nil {
+		return nil, err
+	}
+	if s.Version == 0 || s.Version > SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	return &s, nil
+}
+
+func excludedFromSnapshot(path string) bool {
+	for _, seg := range strings.Split(path, "/") {
+		name, _, _ := strings.Cut(seg, "[")
+		if snapshotExclude[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue converts a typed value to the protocol format
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func newElement(e Elements) Elements {
+	switch e.(type) {
+	case *ElementInt:
+		return &ElementInt{ID: e.Id()}
+	case *ElementBool:
+		return &ElementBool{ID: e.Id()}
+	case *ElementFloat:
+		return &ElementFloat{ID: e.Id()}
+	default:
+		return &ElementString{ID: e.Id()}
+	}
+}
@@ -0,0 +1,111 @@
+package focusritexml
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// an exported snapshot restores the changed values only, through both file formats
+func TestSnapshotRoundTrip(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	out := &d.Outputs.Analogues[0]
+	in := &d.Inputs.Analogues[0]
+	gain, mute, nickname := out.Gain.ID, out.Mute.ID, in.Nickname.ID
+	want := map[int]string{
+		gain:     strconv.Itoa(out.Gain.Value),
+		mute:     strconv.FormatBool(out.Mute.Value),
+		nickname: in.Nickname.Value,
+	}
+
+	exported := d.ExportSnapshot()
+	for path := range exported.Values {
+		if excludedFromSnapshot(path) {
+			t.Fatalf("excluded element exported: %s", path)
+		}
+	}
+
+	for name, encode := range map[string]func() ([]byte, error){"json": exported.JSON, "yaml": exported.YAML} {
+		t.Run(name, func(t *testing.T) {
+			d := loadDevice(t, testArrival)
+			data, err := encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshot, err := ParseSnapshot(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snapshot.Model != d.Model || snapshot.SerialNumber != d.SerialNumber || len(snapshot.Values) != len(exported.Values) {
+				t.Fatalf("parsed %s (%s) with %d values", snapshot.Model, snapshot.SerialNumber, len(snapshot.Values))
+			}
+
+			set, errs := d.RestoreSet(snapshot)
+			if len(set.Items) != 0 || len(errs) != 0 {
+				t.Fatalf("unchanged device restores %v, errors %v", set.Items, errs)
+			}
+
+			changes := NewSet(d.ID)
+			changes.AddItemInt(gain, -60)
+			changes.AddItemBool(mute, !d.Outputs.Analogues[0].Mute.Value)
+			changes.AddItemString(nickname, "changed")
+			changes.AddItemString(d.Outputs.Analogues[0].Meter.ID, "-3") // not part of a snapshot
+			d.UpdateSet(*changes)
+
+			set, errs = d.RestoreSet(snapshot)
+			if len(errs) != 0 {
+				t.Fatal(errs)
+			}
+			got := make(map[int]string)
+			for _, item := range set.Items {
+				got[item.ID] = item.Value
+			}
+			if len(got) != len(want) {
+				t.Fatalf("restored %v, want %v", got, want)
+			}
+			for id, value := range want {
+				if got[id] != value {
+					t.Errorf("element %d restored to %q, want %q", id, got[id], value)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotRestoreErrors(t *testing.T) {
+	d := loadDevice(t, testArrival)
+	snapshot := d.ExportSnapshot()
+
+	mutePath, _ := d.PathOf(d.Outputs.Analogues[0].Mute.ID)
+	snapshot.Values[mutePath.Path] = "maybe"
+	snapshot.Values[`outputs/analogue[name="Missing Output"]/gain`] = -10
+
+	changes := NewSet(d.ID)
+	changes.AddItemInt(d.Outputs.Analogues[1].Gain.ID, -60)
+	d.UpdateSet(*changes)
+
+	set, errs := d.RestoreSet(snapshot)
+	if len(errs) != 2 {
+		t.Fatalf("errors %v", errs)
+	}
+	for _, err := range errs {
+		if !strings.Contains(err.Error(), "Missing Output") && !strings.Contains(err.Error(), "maybe") {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if len(set.Items) != 1 || set.Items[0].ID != d.Outputs.Analogues[1].Gain.ID {
+		t.Fatalf("the valid values aren't restored: %v", set.Items)
+	}
+}
+
+func TestParseSnapshotVersion(t *testing.T) {
+	for _, data := range []string{
+		`{"model": "Scarlett 18i20", "values": {}}`,
+		`version: 99`,
+		`version: [`,
+	} {
+		if _, err := ParseSnapshot([]byte(data)); err == nil {
+			t.Errorf("%s parsed", data)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package focusritexml
+
+import (
+	"bytes"
+	"strconv"
+)
+
+const (
+	framePrefix     string = "Length="
+	frameLenDigits  int    = 6
+	frameHeaderSize int    = len(framePrefix) + frameLenDigits + 1 // "Length=XXXXXX "
+)
+
+// FrameDecoder splits a Focusrite Control TCP stream into single messages.
+// Every message is framed as "Length=XXXXXX <xml>", where XXXXXX is the hex
+// encoded length of the xml part (see ParseToXML).
+// Partial frames are buffered until they are complete, several frames in one
+// read are split up and garbage in between is skipped until the next header.
+type FrameDecoder struct {
+	buffer []byte // unread data, frames are consumed from the front
+}
+
+func NewFrameDecoder() *FrameDecoder {
+	return &FrameDecoder{
+		buffer: make([]byte, 0, 4096),
+	}
+}
+
+// Write appends received stream data to the decoder buffer
+func (d *FrameDecoder) Write(p []byte) (int, error) {
+	d.buffer = append(d.buffer, p...)
+	return len(p), nil
+}
+
+// Next returns the next complete frame including its length header,
+// ready to be used with ParseFromXML. ok is false if no complete frame is buffered.
+func (d *FrameDecoder) Next() (frame string, ok bool) {
+	for {
+		if !d.resync() {
+			return "", false
+		}
+
+		if len(d.buffer) < frameHeaderSize {
+			return "", false
+		}
+
+		lenField := d.buffer[len(framePrefix) : len(framePrefix)+frameLenDigits]
+		length, err := strconv.ParseUint(string(lenField), 16, 32)
+		if err != nil || d.buffer[frameHeaderSize-1] != ' ' {
+			log.Warnf("invalid frame header %q, resyncing", d.buffer[:frameHeaderSize])
+			d.discard(1)
+			continue
+		}
+
+		frameSize := frameHeaderSize + int(length)
+		if len(d.buffer) < frameSize {
+			return "", false
+		}
+
+		frame = string(d.buffer[:frameSize])
+		d.discard(frameSize)
+		return frame, true
+	}
+}
+
+// Buffered returns the number of bytes waiting for a complete frame
+func (d *FrameDecoder) Buffered() int {
+	return len(d.buffer)
+}
+
+// Reset drops all buffered data, e.g. after a reconnect
+func (d *FrameDecoder) Reset() {
+	d.buffer = d.buffer[:0]
+}
+
+// resync drops everything in front of the next frame header.
+// returns false if more data is needed to find a header.
+func (d *FrameDecoder) resync() bool {
+	idx := bytes.Index(d.buffer, []byte(framePrefix))
+	if idx < 0 {
+		// keep a possible partial prefix at the end of the buffer
+		keep := min(len(d.buffer), len(framePrefix)-1)
+		if dropped := len(d.buffer) - keep; dropped > 0 {
+			if len(bytes.TrimSpace(d.buffer[:dropped])) > 0 {
+				log.Warnf("dropping %d bytes without frame header", dropped)
+			}
+			d.discard(dropped)
+		}
+		return false
+	}
+
+	if idx > 0 {
+		if len(bytes.TrimSpace(d.buffer[:idx])) > 0 {
+			log.Warnf("dropping %d bytes in front of frame header", idx)
+		}
+		d.discard(idx)
+	}
+	return true
+}
+
+func (d *FrameDecoder) discard(n int) {
+	d.buffer = d.buffer[n:]
+}
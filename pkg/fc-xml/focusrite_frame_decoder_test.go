@@ -0,0 +1,118 @@
+package focusritexml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtureFrames returns every file of the xml directory as framed message
+func fixtureFrames(t testing.TB) []string {
+	t.Helper()
+	files, err := filepath.Glob("../../xml/*.xml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fixtures: %v", err)
+	}
+	frames := make([]string, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, AddLenHeader(string(data)))
+	}
+	return frames
+}
+
+// decodeChunks writes the stream in chunks of size and collects all frames
+func decodeChunks(stream string, size int) []string {
+	d := NewFrameDecoder()
+	frames := make([]string, 0)
+	for start := 0; start < len(stream); start += size {
+		d.Write([]byte(stream[start:min(start+size, len(stream))]))
+		for {
+			frame, ok := d.Next()
+			if !ok {
+				break
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+func checkFrames(t *testing.T, got []string, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("frame %d differs:\n got %.80q\nwant %.80q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFrameDecoderChunks(t *testing.T) {
+	frames := fixtureFrames(t)
+	stream := strings.Join(frames, "")
+
+	for _, size := range []int{1, 2, 3, 7, 13, 64, 1000, 4096, len(stream)} {
+		checkFrames(t, decodeChunks(stream, size), frames)
+	}
+}
+
+func TestFrameDecoderMergedFrames(t *testing.T) {
+	frames := fixtureFrames(t)
+
+	d := NewFrameDecoder()
+	d.Write([]byte(strings.Join(frames, "")))
+	got := make([]string, 0)
+	for {
+		frame, ok := d.Next()
+		if !ok {
+			break
+		}
+		got = append(got, frame)
+	}
+	checkFrames(t, got, frames)
+	if d.Buffered() != 0 {
+		t.Errorf("%d bytes left in buffer", d.Buffered())
+	}
+}
+
+func TestFrameDecoderResync(t *testing.T) {
+	frames := fixtureFrames(t)
+
+	stream := "garbage" + frames[0] + "\n\nLength=zzzzzz <broken/>" + frames[1] + "Len" + "xx" + frames[2]
+	for _, size := range []int{1, 5, 100, len(stream)} {
+		checkFrames(t, decodeChunks(stream, size), frames[:3])
+	}
+}
+
+func TestFrameDecoderSplitHeader(t *testing.T) {
+	frame := AddLenHeader(`<keep-alive/>`)
+
+	// split inside the prefix, the length digits and right before the blank
+	for _, split := range []int{3, len(framePrefix), len(framePrefix) + 3, frameHeaderSize - 1, frameHeaderSize} {
+		d := NewFrameDecoder()
+		d.Write([]byte(frame[:split]))
+		if _, ok := d.Next(); ok {
+			t.Fatalf("split %d: frame from incomplete data", split)
+		}
+		d.Write([]byte(frame[split:]))
+		got, ok := d.Next()
+		if !ok || got != frame {
+			t.Fatalf("split %d: got %q %t", split, got, ok)
+		}
+	}
+}
+
+func TestFrameDecoderParse(t *testing.T) {
+	for _, frame := range decodeChunks(strings.Join(fixtureFrames(t), ""), 17) {
+		if _, err := ParseFromXML(frame); err != nil {
+			t.Errorf("parse %.60q: %v", frame, err)
+		}
+	}
+}
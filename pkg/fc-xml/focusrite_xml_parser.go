@@ -18,8 +18,12 @@ func ParseToXML(data interface{}) (string, error) {
 		return "", fmt.Errorf("error encoding data: %v", err)
 	}
 	xmlString := strings.ReplaceAll(string(buffer), " />", "/>")
-	xmlString = fmt.Sprintf("Length=%06X %s", len(xmlString), xmlString)
-	return xmlString, nil
+	return AddLenHeader(xmlString), nil
+}
+
+// AddLenHeader frames an already encoded xml message with the length header
+func AddLenHeader(xmlString string) string {
+	return fmt.Sprintf("%s%06X %s", framePrefix, len(xmlString), xmlString)
 }
 
 func SplitLenXML(data string) (string, error) {
@@ -90,7 +94,36 @@ func ParseFromXML(in string) (interface{}, error) {
 			return nil, err
 		}
 		return v, nil
+
+	case "device-subscribe":
+		var v SubscribeMessage
+		if err := xml.Unmarshal([]byte(xmlData), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case "client-discovery":
+		var v ClientDiscovery
+		if err := xml.Unmarshal([]byte(xmlData), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case "server-announcement":
+		var v ServerAnnouncement
+		if err := xml.Unmarshal([]byte(xmlData), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
+	case "error":
+		var v ErrorMessage
+		if err := xml.Unmarshal([]byte(xmlData), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+
 	default:
-		return nil, fmt.Errorf("unknown XML type: %s\n%s", wrapper.XMLName.Local, xmlData)
+		return UnknownMessage{Name: wrapper.XMLName.Local, Raw: xmlData}, nil
 	}
 }
@@ -0,0 +1,133 @@
+package focusritexml
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ECUST-XX/xml"
+)
+
+// roundTrip parses the frame, marshals the message again and parses the result
+func roundTrip(t *testing.T, frame string) (interface{}, interface{}) {
+	t.Helper()
+	first, err := ParseFromXML(frame)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	encoded, err := ParseToXML(first)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", first, err)
+	}
+	second, err := ParseFromXML(encoded)
+	if err != nil {
+		t.Fatalf("parse marshalled %T: %v", first, err)
+	}
+	return first, second
+}
+
+func TestRoundTripFixtures(t *testing.T) {
+	files, _ := filepath.Glob("../../xml/*.xml")
+	frames := fixtureFrames(t)
+
+	for i, frame := range frames {
+		t.Run(filepath.Base(files[i]), func(t *testing.T) {
+			first, second := roundTrip(t, frame)
+			if _, ok := first.(UnknownMessage); ok {
+				t.Skip("no message, parsed as fragment")
+			}
+			if reflect.TypeOf(first) != reflect.TypeOf(second) {
+				t.Fatalf("%T parsed as %T after marshal", first, second)
+			}
+			// elements missing in the fixture are written empty, so compare the encoded messages
+			a, _ := ParseToXML(first)
+			b, _ := ParseToXML(second)
+			if a != b {
+				t.Fatalf("%T differs after marshal and parse", first)
+			}
+		})
+	}
+}
+
+// the fixtures without message root are parts of a device
+func TestRoundTripFragments(t *testing.T) {
+	for file, v := range map[string]interface{}{
+		"Scarlett 4i4 (3rd Gen).xml":                 &Device{},
+		"device-arrival-18i20_hardware-controls.xml": &HardwareControls{},
+		"device-arrival-18i20_outputs.xml":           &Analogue{},
+	} {
+		data, err := os.ReadFile(filepath.Join("../../xml", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.Unmarshal(data, v); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		encoded, err := xml.Marshal(v)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		again := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		if err := xml.Unmarshal(encoded, again); err != nil {
+			t.Fatalf("%s: parse marshalled: %v", file, err)
+		}
+		reencoded, _ := xml.Marshal(again)
+		if string(encoded) != string(reencoded) {
+			t.Errorf("%s: %T differs after marshal and parse", file, v)
+		}
+	}
+}
+
+func TestRoundTripMessages(t *testing.T) {
+	set := NewSet(1)
+	set.AddItemString(10, "Kick")
+	set.AddItemInt(11, -20)
+	set.AddItemBool(12, true)
+
+	for _, msg := range []interface{}{
+		ClientDetails{Hostname: "host", ClientKey: "key"},
+		*set,
+		KeepAlive{},
+		Approval{Authorised: true},
+		SubscribeMessage{DeviceId: 1, Subscribe: true},
+		DeviceRemoval{Id: 1},
+	} {
+		frame, err := ParseToXML(msg)
+		if err != nil {
+			t.Fatalf("marshal %T: %v", msg, err)
+		}
+		first, second := roundTrip(t, frame)
+		if reflect.TypeOf(first) != reflect.TypeOf(msg) || !reflect.DeepEqual(first, second) {
+			t.Errorf("%T: got %#v, then %#v", msg, first, second)
+		}
+	}
+}
+
+func TestParseUnknownRoot(t *testing.T) {
+	raw := `<future-message id="3"><value>1</value></future-message>`
+
+	msg, err := ParseFromXML(AddLenHeader(raw))
+	if err != nil {
+		t.Fatalf("unknown root returned error: %v", err)
+	}
+	unknown, ok := msg.(UnknownMessage)
+	if !ok {
+		t.Fatalf("got %T, want UnknownMessage", msg)
+	}
+	if unknown.Name != "future-message" || unknown.Raw != raw {
+		t.Fatalf("got %q %q", unknown.Name, unknown.Raw)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"Length=000005",
+		AddLenHeader("<broken"),
+	} {
+		if msg, err := ParseFromXML(in); err == nil {
+			t.Errorf("%q parsed as %T", in, msg)
+		}
+	}
+}
@@ -19,3 +19,12 @@ type Adat struct {
 	Stereo    ElementBool   `xml:"stereo"`
 	Source    ElementInt    `xml:"source"`
 }
+
+func (a *Adat) IndexElements(index ElementIndex) {
+	index.Add(&a.Available)
+	index.Add(&a.Meter)
+	index.Add(&a.Nickname)
+	index.Add(&a.Mute)
+	index.Add(&a.Stereo)
+	index.Add(&a.Source)
+}
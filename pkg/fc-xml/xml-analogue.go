@@ -23,3 +23,17 @@ type Analogue struct {
 	Gain            ElementInt    `xml:"gain"`
 	HardwareControl ElementString `xml:"hardware-control"`
 }
+
+func (a *Analogue) IndexElements(index ElementIndex) {
+	index.Add(&a.Available)
+	index.Add(&a.Meter)
+	index.Add(&a.Nickname)
+	index.Add(&a.Stereo)
+	index.Add(&a.SourceID)
+	index.Add(&a.Mode)
+	index.Add(&a.Air)
+	index.Add(&a.Pad)
+	index.Add(&a.Mute)
+	index.Add(&a.Gain)
+	index.Add(&a.HardwareControl)
+}
@@ -4,6 +4,11 @@ import "github.com/ECUST-XX/xml"
 
 // <approval hostname="Monitor Controller" id="10875864856933266113" type="response" authorised="true"/>
 
+const (
+	APPROVAL_REQUEST  string = "request"
+	APPROVAL_RESPONSE string = "response"
+)
+
 type Approval struct {
 	XMLName xml.Name `xml:"approval"`
 
@@ -0,0 +1,13 @@
+package focusritexml
+
+import "github.com/ECUST-XX/xml"
+
+// sent by UDP to port 30096 - 30098
+// <client-discovery app="SAFFIRE-CONTROL" version="4" device="iOS"/>
+type ClientDiscovery struct {
+	XMLName xml.Name `xml:"client-discovery"`
+
+	App     string `xml:"app,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Device  string `xml:"device,attr,omitempty"`
+}
@@ -10,3 +10,10 @@ type Clocking struct {
 	SampleRate  ElementString `xml:"sample-rate"`
 	ClockMaster ElementString `xml:"clock-master"`
 }
+
+func (c *Clocking) IndexElements(index ElementIndex) {
+	index.Add(&c.Locked)
+	index.Add(&c.ClockSource)
+	index.Add(&c.SampleRate)
+	index.Add(&c.ClockMaster)
+}
@@ -2,7 +2,6 @@ package focusritexml
 
 import (
 	"fmt"
-	"reflect"
 	"strings"
 
 	"github.com/ECUST-XX/xml"
@@ -40,71 +39,77 @@ type Device struct {
 	QuickStart   QuickStart   `xml:"quick-start"`
 	HaloSettings HaloSettings `xml:"halo-settings"`
 
-	elementsMap map[int]Elements
+	elementsMap ElementIndex
+	paths       map[int]ElementPath // reverse lookup, built on first use
+	observers   *deviceObservers
 }
 
+// UpdateMap builds the element index, the entries point to the elements of the device
 func (d *Device) UpdateMap() {
-	if d.elementsMap == nil {
-		d.elementsMap = make(map[int]Elements)
-	}
-	UpdateAllMaps(d, d.elementsMap, 0)
+	d.elementsMap = make(ElementIndex, len(d.elementsMap))
+	d.IndexElements(d.elementsMap)
+	d.observerList()
 	log.Debugf("Updated Device Map with %d items", len(d.elementsMap))
 }
 
+func (d *Device) IndexElements(index ElementIndex) {
+	index.Add(&d.Nickname)
+	index.Add(&d.SealBroken)
+	index.Add(&d.Snapshot)
+	index.Add(&d.SaveSnapshot)
+	index.Add(&d.ResetDevice)
+	index.Add(&d.RecordOutputs)
+	index.Add(&d.Dante)
+	index.Add(&d.State)
+	index.Add(&d.PairableDevices)
+	index.Add(&d.Preset)
+	d.Firmware.IndexElements(index)
+	d.Mixer.IndexElements(index)
+	d.Inputs.IndexElements(index)
+	d.Outputs.IndexElements(index)
+	d.Monitoring.IndexElements(index)
+	d.Clocking.IndexElements(index)
+	d.Settings.IndexElements(index)
+	d.QuickStart.IndexElements(index)
+	d.HaloSettings.IndexElements(index)
+}
+
+// UpdateSet applies the set to the device and notifies the subscribers of changed elements
 func (d *Device) UpdateSet(set Set) int {
+	updateCount, changes := d.ApplySet(set)
+	d.Notify(changes)
+	return updateCount
+}
+
+// ApplySet applies the set to the device and returns the changes of subscribed elements for Notify,
+// so a device guarded by a lock can notify its subscribers outside of it
+func (d *Device) ApplySet(set Set) (int, []ElementChange) {
 	updateCount := 0
+	observed := d.hasObservers()
+	var changes []ElementChange
+
 	for _, v := range set.Items {
 		value, ok := d.elementsMap[v.ID]
 		if ok {
 			updateCount++
+			var old interface{}
+			if observed {
+				old = value.Get()
+			}
 			err := value.Set(v.Value)
 			if err != nil {
 				log.Errorf("item could not be updated: %d (String Value: %s): %s", v.ID, v.Value, err.Error())
+				continue
+			}
+			if observed && value.Get() != old {
+				changes = append(changes, ElementChange{DeviceId: d.ID, ID: v.ID, Old: old, New: value.Get()})
 			}
 		} else {
 			log.Warnf("unknown ID to update: %d with value %s\n", v.ID, v.Value)
 		}
 	}
-	return updateCount
-}
 
-func UpdateAllMaps(v interface{}, elementsMap map[int]Elements, level int) {
-	val := reflect.ValueOf(v)
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-	}
-
-	switch e := v.(type) {
-	case ElementBool:
-		elementsMap[e.Id()] = &e
-		return
-
-	case ElementInt:
-		elementsMap[e.Id()] = &e
-		return
-
-	case ElementString:
-		elementsMap[e.Id()] = &e
-		return
-
-	case ElementFloat:
-		elementsMap[e.Id()] = &e
-		return
-	}
-
-	// Wenn es kein Element ist, durch alle Felder der Struktur iterieren
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		if field.Kind() == reflect.Struct || (field.Kind() == reflect.Ptr && field.Elem().Kind() == reflect.Struct) {
-			UpdateAllMaps(field.Interface(), elementsMap, level+1)
-		}
-
-		if field.Kind() == reflect.Array || field.Kind() == reflect.Slice {
-			for j := 0; j < field.Len(); j++ {
-				UpdateAllMaps(field.Index(j).Interface(), elementsMap, level+1)
-			}
-		}
-	}
+	return updateCount, changes
 }
 
 func IndentPrintf(indent int, format string, a ...interface{}) string {
@@ -0,0 +1,110 @@
+package focusritexml
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+const testArrival string = "../../xml/device-arrival-18i20.xml"
+
+// the index must point at the fields of the device, not at copies
+func TestUpdateSetChangesFields(t *testing.T) {
+	d := loadDevice(t, testArrival)
+
+	set := NewSet(d.ID)
+	set.AddItemString(d.Clocking.SampleRate.ID, "96000")
+	set.AddItemInt(d.Mixer.Mixes[1].Inputs[0].Gain.ID, -20)
+	set.AddItemBool(d.Outputs.Analogues[0].Mute.ID, !d.Outputs.Analogues[0].Mute.Value)
+	set.AddItemString(d.Outputs.Analogues[0].Meter.ID, "-12.5")
+	mute := !d.Outputs.Analogues[0].Mute.Value
+
+	if n := d.UpdateSet(*set); n != len(set.Items) {
+		t.Fatalf("updated %d of %d items", n, len(set.Items))
+	}
+	if d.Clocking.SampleRate.Value != "96000" {
+		t.Errorf("sample rate %q", d.Clocking.SampleRate.Value)
+	}
+	if d.Mixer.Mixes[1].Inputs[0].Gain.Value != -20 {
+		t.Errorf("mix gain %d", d.Mixer.Mixes[1].Inputs[0].Gain.Value)
+	}
+	if d.Outputs.Analogues[0].Mute.Value != mute {
+		t.Errorf("mute %t", d.Outputs.Analogues[0].Mute.Value)
+	}
+	if d.Outputs.Analogues[0].Meter.Value != -12.5 {
+		t.Errorf("meter %f", d.Outputs.Analogues[0].Meter.Value)
+	}
+}
+
+func TestUpdateSetUnknownId(t *testing.T) {
+	d := loadDevice(t, testArrival)
+
+	set := NewSet(d.ID)
+	set.AddItemString(-1, "unknown")
+	if n := d.UpdateSet(*set); n != 0 {
+		t.Fatalf("updated %d items", n)
+	}
+}
+
+func BenchmarkParseArrival18i20(b *testing.B) {
+	data, err := os.ReadFile(testArrival)
+	if err != nil {
+		b.Fatal(err)
+	}
+	frame := AddLenHeader(string(data))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+	for i := 0; i < b.N; i++ {
+		msg, err := ParseFromXML(frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+		arrival := msg.(DeviceArrival)
+		arrival.Device.UpdateMap()
+	}
+}
+
+// a set with every meter of the device, as sent while audio is playing
+func BenchmarkUpdateSetMeterBurst(b *testing.B) {
+	d := loadDevice(b, testArrival)
+
+	set := NewSet(d.ID)
+	for id, e := range d.elementsMap {
+		if _, ok := e.(*ElementFloat); ok {
+			set.AddItemString(id, strconv.FormatFloat(-float64(id%60), 'f', 1, 64))
+		}
+	}
+	if len(set.Items) == 0 {
+		b.Fatal("no meters")
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.UpdateSet(*set)
+	}
+}
+
+func BenchmarkParseUpdateSetMeterBurst(b *testing.B) {
+	d := loadDevice(b, testArrival)
+
+	set := NewSet(d.ID)
+	for id, e := range d.elementsMap {
+		if _, ok := e.(*ElementFloat); ok {
+			set.AddItemString(id, "-42.0")
+		}
+	}
+	frame, err := ParseToXML(*set)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		msg, err := ParseFromXML(frame)
+		if err != nil {
+			b.Fatal(err)
+		}
+		d.UpdateSet(msg.(Set))
+	}
+}
@@ -6,9 +6,25 @@ import (
 
 type Elements interface {
 	Set(value string) error
+	Get() interface{} // typed value
 	Id() int
 }
 
+// ElementIndex maps the IDs to the elements of a device
+type ElementIndex map[int]Elements
+
+// Add adds the element if it is present on the device
+func (idx ElementIndex) Add(e Elements) {
+	if e.Id() != 0 {
+		idx[e.Id()] = e
+	}
+}
+
+// ElementContainer is implemented by all parts of a device containing elements
+type ElementContainer interface {
+	IndexElements(index ElementIndex)
+}
+
 // ElementString
 type ElementString struct {
 	ID    int `xml:"id,attr,omitempty"`
@@ -20,6 +36,10 @@ func (e *ElementString) Set(value string) error {
 	return nil
 }
 
+func (e *ElementString) Get() interface{} {
+	return e.Value
+}
+
 func (e *ElementString) Id() int {
 	return e.ID
 }
@@ -39,6 +59,10 @@ func (e *ElementInt) Set(value string) error {
 	return nil
 }
 
+func (e *ElementInt) Get() interface{} {
+	return e.Value
+}
+
 func (e *ElementInt) Id() int {
 	return e.ID
 }
@@ -58,6 +82,10 @@ func (e *ElementBool) Set(value string) error {
 	return nil
 }
 
+func (e *ElementBool) Get() interface{} {
+	return e.Value
+}
+
 func (e *ElementBool) Id() int {
 	return e.ID
 }
@@ -77,6 +105,10 @@ func (e *ElementFloat) Set(value string) error {
 	return nil
 }
 
+func (e *ElementFloat) Get() interface{} {
+	return e.Value
+}
+
 func (e *ElementFloat) Id() int {
 	return e.ID
 }
@@ -0,0 +1,13 @@
+package focusritexml
+
+import "github.com/ECUST-XX/xml"
+
+// error reply of Focusrite Control to a request it can't handle
+// <error id="1234">unknown item</error>
+type ErrorMessage struct {
+	XMLName xml.Name `xml:"error"`
+
+	Id      string `xml:"id,attr,omitempty"`
+	Code    string `xml:"code,attr,omitempty"`
+	Message string `xml:",chardata"`
+}
@@ -11,3 +11,11 @@ type Firmware struct {
 	UpdateFirmware   ElementString `xml:"update-firmware"`
 	RestoreFactory   ElementString `xml:"restore-factory"`
 }
+
+func (f *Firmware) IndexElements(index ElementIndex) {
+	index.Add(&f.Version)
+	index.Add(&f.NeedsUpdate)
+	index.Add(&f.FirmwareProgress)
+	index.Add(&f.UpdateFirmware)
+	index.Add(&f.RestoreFactory)
+}
@@ -11,3 +11,14 @@ type HaloSettings struct {
 	EnablePreview    ElementBool     `xml:"enable-preview-mode"`
 	Halos            ElementString   `xml:"halos"`
 }
+
+func (h *HaloSettings) IndexElements(index ElementIndex) {
+	for i := range h.AvailableColours {
+		index.Add(&h.AvailableColours[i])
+	}
+	index.Add(&h.GoodMeterColour)
+	index.Add(&h.PreClipColour)
+	index.Add(&h.ClippingColour)
+	index.Add(&h.EnablePreview)
+	index.Add(&h.Halos)
+}
@@ -14,6 +14,11 @@ type HardwareControls struct {
 	Controls Controls `xml:"hardware-controls"`
 }
 
+func (h *HardwareControls) IndexElements(index ElementIndex) {
+	index.Add(&h.Talkback)
+	h.Controls.IndexElements(index)
+}
+
 type Controls struct {
 	Gain      ElementInt  `xml:"gain"`
 	Dim       ElementBool `xml:"dim"`
@@ -21,3 +26,11 @@ type Controls struct {
 	AltEnable ElementBool `xml:"alt-enable"`
 	Alt       ElementInt  `xml:"alt"`
 }
+
+func (c *Controls) IndexElements(index ElementIndex) {
+	index.Add(&c.Gain)
+	index.Add(&c.Dim)
+	index.Add(&c.Mute)
+	index.Add(&c.AltEnable)
+	index.Add(&c.Alt)
+}
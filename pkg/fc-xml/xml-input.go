@@ -8,3 +8,8 @@ type Input struct {
 	Source ElementString `xml:"source"`
 	Stereo ElementString `xml:"stereo"`
 }
+
+func (i *Input) IndexElements(index ElementIndex) {
+	index.Add(&i.Source)
+	index.Add(&i.Stereo)
+}
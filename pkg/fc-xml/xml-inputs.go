@@ -10,3 +10,18 @@ type Inputs struct {
 	SpdifRca  []SpdifRca `xml:"spdif-rca"`
 	Adat      []Adat     `xml:"adat"`
 }
+
+func (in *Inputs) IndexElements(index ElementIndex) {
+	for i := range in.Analogues {
+		in.Analogues[i].IndexElements(index)
+	}
+	for i := range in.Playbacks {
+		in.Playbacks[i].IndexElements(index)
+	}
+	for i := range in.SpdifRca {
+		in.SpdifRca[i].IndexElements(index)
+	}
+	for i := range in.Adat {
+		in.Adat[i].IndexElements(index)
+	}
+}
@@ -16,3 +16,14 @@ type Loopback struct {
 	Stereo     ElementBool   `xml:"stereo"`
 	Nickname   ElementString `xml:"nickname"`
 }
+
+func (l *Loopback) IndexElements(index ElementIndex) {
+	index.Add(&l.Available)
+	index.Add(&l.Meter)
+	index.Add(&l.AssignMix)
+	index.Add(&l.AssignTBM)
+	index.Add(&l.Mute)
+	index.Add(&l.Source)
+	index.Add(&l.Stereo)
+	index.Add(&l.Nickname)
+}
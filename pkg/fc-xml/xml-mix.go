@@ -11,3 +11,11 @@ type Mix struct {
 	Meter      ElementFloat `xml:"meter"`
 	Inputs     []MixInput   `xml:"input"`
 }
+
+func (m *Mix) IndexElements(index ElementIndex) {
+	index.Add(&m.Talkback)
+	index.Add(&m.Meter)
+	for i := range m.Inputs {
+		m.Inputs[i].IndexElements(index)
+	}
+}
@@ -9,3 +9,10 @@ type MixInput struct {
 	Mute    ElementBool `xml:"mute"`
 	Solo    ElementBool `xml:"solo"`
 }
+
+func (m *MixInput) IndexElements(index ElementIndex) {
+	index.Add(&m.Gain)
+	index.Add(&m.Pan)
+	index.Add(&m.Mute)
+	index.Add(&m.Solo)
+}
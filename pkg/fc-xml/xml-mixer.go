@@ -10,3 +10,11 @@ type Mixer struct {
 	Inputs MixerInputs `xml:"inputs"`
 	Mixes  []Mix       `xml:"mixes>mix"`
 }
+
+func (m *Mixer) IndexElements(index ElementIndex) {
+	index.Add(&m.Available)
+	m.Inputs.IndexElements(index)
+	for i := range m.Mixes {
+		m.Mixes[i].IndexElements(index)
+	}
+}
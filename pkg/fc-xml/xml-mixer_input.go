@@ -11,3 +11,14 @@ type MixerInputs struct {
 	FreeInputs           ElementString `xml:"free-inputs"`
 	InputList            []Input       `xml:"input"`
 }
+
+func (m *MixerInputs) IndexElements(index ElementIndex) {
+	index.Add(&m.AddInput)
+	index.Add(&m.AddInputWithoutReset)
+	index.Add(&m.AddStereoInput)
+	index.Add(&m.RemoveInput)
+	index.Add(&m.FreeInputs)
+	for i := range m.InputList {
+		m.InputList[i].IndexElements(index)
+	}
+}
@@ -10,3 +10,8 @@ type Monitoring struct {
 	HardwareControls HardwareControls `xml:"hardware-controls"`
 	Preset           ElementString    `xml:"preset"`
 }
+
+func (m *Monitoring) IndexElements(index ElementIndex) {
+	m.HardwareControls.IndexElements(index)
+	index.Add(&m.Preset)
+}
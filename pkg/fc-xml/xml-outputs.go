@@ -10,3 +10,18 @@ type Outputs struct {
 	SpdifRca  []SpdifRca `xml:"spdif-rca"`
 	Adat      []Adat     `xml:"adat"`
 }
+
+func (o *Outputs) IndexElements(index ElementIndex) {
+	for i := range o.Analogues {
+		o.Analogues[i].IndexElements(index)
+	}
+	for i := range o.Loopbacks {
+		o.Loopbacks[i].IndexElements(index)
+	}
+	for i := range o.SpdifRca {
+		o.SpdifRca[i].IndexElements(index)
+	}
+	for i := range o.Adat {
+		o.Adat[i].IndexElements(index)
+	}
+}
@@ -13,3 +13,9 @@ type Playback struct {
 	Meter            ElementFloat  `xml:"meter"`
 	Nickname         ElementString `xml:"nickname"`
 }
+
+func (p *Playback) IndexElements(index ElementIndex) {
+	index.Add(&p.Available)
+	index.Add(&p.Meter)
+	index.Add(&p.Nickname)
+}
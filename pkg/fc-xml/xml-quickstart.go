@@ -7,3 +7,7 @@ type QuickStart struct {
 	URL     string        `xml:"url,attr"`
 	MsdMode ElementString `xml:"msd-mode"`
 }
+
+func (q *QuickStart) IndexElements(index ElementIndex) {
+	index.Add(&q.MsdMode)
+}
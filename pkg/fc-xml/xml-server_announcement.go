@@ -0,0 +1,14 @@
+package focusritexml
+
+import "github.com/ECUST-XX/xml"
+
+// answer to the client-discovery
+// <server-announcement app='SAFFIRE-CONTROL' version='4' hostname='MacBook-Pro-von-Sebastian.local' port='55145'/>
+type ServerAnnouncement struct {
+	XMLName xml.Name `xml:"server-announcement"`
+
+	App      string `xml:"app,attr,omitempty"`
+	Version  string `xml:"version,attr,omitempty"`
+	Hostname string `xml:"hostname,attr,omitempty"`
+	Port     int    `xml:"port,attr,omitempty"`
+}
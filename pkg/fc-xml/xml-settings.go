@@ -6,3 +6,9 @@ type Settings struct {
 	SpdifMode          SpdifMode   `xml:"spdif-mode"`
 	Talkback           Talkback    `xml:"talkback"`
 }
+
+func (s *Settings) IndexElements(index ElementIndex) {
+	index.Add(&s.PhantomPersistence)
+	s.SpdifMode.IndexElements(index)
+	s.Talkback.IndexElements(index)
+}
@@ -4,3 +4,7 @@ type SpdifMode struct {
 	Name string        `xml:"name,attr"`
 	Mode ElementString `xml:"mode"`
 }
+
+func (s *SpdifMode) IndexElements(index ElementIndex) {
+	index.Add(&s.Mode)
+}
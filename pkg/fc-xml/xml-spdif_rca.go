@@ -13,3 +13,12 @@ type SpdifRca struct {
 	Stereo           ElementBool   `xml:"stereo"`
 	Source           ElementInt    `xml:"source"`
 }
+
+func (s *SpdifRca) IndexElements(index ElementIndex) {
+	index.Add(&s.Available)
+	index.Add(&s.Meter)
+	index.Add(&s.Nickname)
+	index.Add(&s.Mute)
+	index.Add(&s.Stereo)
+	index.Add(&s.Source)
+}
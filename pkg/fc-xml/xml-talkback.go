@@ -8,3 +8,9 @@ type Talkback struct {
 	SourceAttenuation ElementInt    `xml:"source-attenuation"`
 	Available         ElementBool   `xml:"talkback-available"`
 }
+
+func (t *Talkback) IndexElements(index ElementIndex) {
+	index.Add(&t.InputSource)
+	index.Add(&t.SourceAttenuation)
+	index.Add(&t.Available)
+}
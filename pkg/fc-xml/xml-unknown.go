@@ -0,0 +1,8 @@
+package focusritexml
+
+// UnknownMessage is returned by ParseFromXML for root elements this version doesn't know,
+// so newer Focusrite Control versions don't break the connection.
+type UnknownMessage struct {
+	Name string // root element
+	Raw  string // xml without length header
+}
@@ -1,6 +1,7 @@
 package guiconfig
 
 import (
+	"context"
 	"fmt"
 
 	"fyne.io/fyne/v2"
@@ -15,6 +16,8 @@ import (
 type ConfigApp struct {
 	newConfig *config.Config
 	Content   *fyne.Container
+
+	focusriteConfig *FocusriteConfigGui
 }
 
 func NewConfigApp(window fyne.Window, cfg *config.Config, restartFunc func(), exitFunc func()) *ConfigApp {
@@ -30,7 +33,20 @@ func NewConfigApp(window fyne.Window, cfg *config.Config, restartFunc func(), ex
 	// Config Gui Parts
 	controllerConfig := NewControllerConfig(&c.newConfig.MonitorController)
 	midiConfig := NewMidiConfigGui(&c.newConfig.Midi)
-	focusriteConfig := NewFocusriteConfigGui(&c.newConfig.FocusriteDevice)
+	c.focusriteConfig = NewFocusriteConfigGui(&c.newConfig.FocusriteDevice)
+
+	// midi and focusrite settings follow the speaker list
+	controllerConfig.OnSpeakersChanged = func() {
+		midiConfig.UpdateSpeakers(&c.newConfig.MonitorController)
+		c.focusriteConfig.UpdateSpeakers(&c.newConfig.MonitorController)
+	}
+	controllerConfig.OnSpeakersChanged()
+
+	// and the midi scene switches the scene list
+	controllerConfig.OnScenesChanged = func() {
+		midiConfig.UpdateScenes(&c.newConfig.MonitorController)
+	}
+	controllerConfig.OnScenesChanged()
 
 	// Save
 	saveButton := widget.NewButton("Save & Restart", func() {
@@ -68,7 +84,7 @@ func NewConfigApp(window fyne.Window, cfg *config.Config, restartFunc func(), ex
 	configAccordion := widget.NewAccordion(
 		controllerConfig.Container,
 		midiConfig.Container,
-		focusriteConfig.Container,
+		c.focusriteConfig.Container,
 	)
 	configAccordion.Open(0)
 
@@ -84,3 +100,13 @@ func NewConfigApp(window fyne.Window, cfg *config.Config, restartFunc func(), ex
 	window.SetContent(c.Content)
 	return c
 }
+
+// Start the device discovery while the config window is shown
+func (c *ConfigApp) Start(ctx context.Context) error {
+	return c.focusriteConfig.Start(ctx)
+}
+
+// Close stops the device discovery
+func (c *ConfigApp) Close() error {
+	return c.focusriteConfig.Close()
+}
@@ -19,26 +19,37 @@ type ControllerConfigGui struct {
 	dimSlider *widget.Slider
 	dimLabel  *widget.Label
 
-	speakerADisable   *widget.Check
-	speakerAExclusive *widget.Check
-
-	speakerBDisable   *widget.Check
-	speakerBExclusive *widget.Check
-
-	speakerCDisable   *widget.Check
-	speakerCExclusive *widget.Check
-
-	speakerDDisable   *widget.Check
-	speakerDExclusive *widget.Check
-
-	speakerSubDisable *widget.Check
+	calibrationSlider *widget.Slider
+	calibrationLabel  *widget.Label
+
+	maxVolumeSlider  *widget.Slider
+	maxVolumeLabel   *widget.Label
+	maxStepSlider    *widget.Slider
+	maxStepLabel     *widget.Label
+	startupSelect    *widget.Select
+	startupMaxSlider *widget.Slider
+	startupMaxLabel  *widget.Label
+
+	speakerTrim      map[monitorcontroller.SpeakerID]*widget.Slider
+	speakerTrimLabel map[monitorcontroller.SpeakerID]*widget.Label
+	speakerContainer *fyne.Container
+	groupContainer   *fyne.Container
+	sceneContainer   *fyne.Container
+
+	OnSpeakersChanged func() // speakers added, removed, reordered or renamed
+	OnScenesChanged   func() // scenes removed, reordered or renamed
 
 	Container *widget.AccordionItem
 }
 
 func NewControllerConfig(cfg *monitorcontroller.ControllerSate) *ControllerConfigGui {
 	cg := &ControllerConfigGui{
-		newConfig: cfg,
+		newConfig:        cfg,
+		speakerTrim:      make(map[monitorcontroller.SpeakerID]*widget.Slider),
+		speakerTrimLabel: make(map[monitorcontroller.SpeakerID]*widget.Label),
+		speakerContainer: container.New(layout.NewFormLayout()),
+		groupContainer:   container.New(layout.NewFormLayout()),
+		sceneContainer:   container.New(layout.NewFormLayout()),
 	}
 
 	cg.dimLabel = widget.NewLabel("")
@@ -51,55 +62,82 @@ func NewControllerConfig(cfg *monitorcontroller.ControllerSate) *ControllerConfi
 		cg.Update()
 	}
 
-	cg.speakerADisable = widget.NewCheck("Disabled", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerA].Disabled = b
-	})
-
-	cg.speakerAExclusive = widget.NewCheck("Exclusive", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerA].Exclusive = b
-	})
+	cg.calibrationLabel = widget.NewLabel("")
 
-	cg.speakerBDisable = widget.NewCheck("Disabled", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerB].Disabled = b
-	})
+	cg.calibrationSlider = widget.NewSlider(-60, -6)
+	cg.calibrationSlider.Step = 1
+	cg.calibrationSlider.OnChanged = func(f float64) {
+		cg.newConfig.Master.CalibrationLevelDB = int(f)
+		cg.Update()
+	}
 
-	cg.speakerBExclusive = widget.NewCheck("Exclusive", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerB].Exclusive = b
-	})
+	// configs from older versions have no volume limits yet
+	if cg.newConfig.Limits.Startup == "" {
+		cg.newConfig.Limits = monitorcontroller.DefaultVolumeLimits()
+	}
 
-	cg.speakerCDisable = widget.NewCheck("Disabled", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerC].Disabled = b
-	})
+	cg.maxVolumeLabel = widget.NewLabel("")
+	cg.maxVolumeSlider = widget.NewSlider(-60, float64(monitorcontroller.VOLUME_MAX_DB))
+	cg.maxVolumeSlider.Step = 1
+	cg.maxVolumeSlider.OnChanged = func(f float64) {
+		cg.newConfig.Limits.MaxVolumeDB = int(f)
+		cg.Update()
+	}
 
-	cg.speakerCExclusive = widget.NewCheck("Exclusive", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerC].Exclusive = b
-	})
+	cg.maxStepLabel = widget.NewLabel("")
+	cg.maxStepSlider = widget.NewSlider(0, 40)
+	cg.maxStepSlider.Step = 1
+	cg.maxStepSlider.OnChanged = func(f float64) {
+		cg.newConfig.Limits.MaxStepDB = int(f)
+		cg.Update()
+	}
 
-	cg.speakerDDisable = widget.NewCheck("Disabled", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerD].Disabled = b
+	policies := make([]string, 0, len(monitorcontroller.StartupPolicies))
+	for _, p := range monitorcontroller.StartupPolicies {
+		policies = append(policies, string(p))
+	}
+	cg.startupSelect = widget.NewSelect(policies, func(s string) {
+		cg.newConfig.Limits.Startup = monitorcontroller.StartupPolicy(s)
+		cg.Update()
 	})
 
-	cg.speakerDExclusive = widget.NewCheck("Exclusive", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.SpeakerD].Exclusive = b
-	})
+	cg.startupMaxLabel = widget.NewLabel("")
+	cg.startupMaxSlider = widget.NewSlider(-60, float64(monitorcontroller.VOLUME_MAX_DB))
+	cg.startupMaxSlider.Step = 1
+	cg.startupMaxSlider.OnChanged = func(f float64) {
+		cg.newConfig.Limits.StartupMaxDB = int(f)
+		cg.Update()
+	}
 
-	cg.speakerSubDisable = widget.NewCheck("Disabled", func(b bool) {
-		cg.newConfig.Speaker[monitorcontroller.Sub].Disabled = b
+	addButton := widget.NewButton("Add Speaker", func() {
+		cg.newConfig.AddSpeaker("", monitorcontroller.Speaker)
+		cg.speakersChanged()
 	})
 
 	cg.Container = widget.NewAccordionItem("Monitor Controller",
-		container.New(layout.NewFormLayout(),
-			widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
-			cg.dimLabel, cg.dimSlider,
-			widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
-			widget.NewLabel("Speaker A:"), container.NewHBox(cg.speakerADisable, cg.speakerAExclusive),
-			widget.NewLabel("Speaker B:"), container.NewHBox(cg.speakerBDisable, cg.speakerBExclusive),
-			widget.NewLabel("Speaker C:"), container.NewHBox(cg.speakerCDisable, cg.speakerCExclusive),
-			widget.NewLabel("Speaker D:"), container.NewHBox(cg.speakerDDisable, cg.speakerDExclusive),
-			widget.NewLabel("Subwoofer:"), container.NewHBox(cg.speakerSubDisable),
+		container.NewVBox(
+			container.New(layout.NewFormLayout(),
+				widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+				cg.dimLabel, cg.dimSlider,
+				cg.calibrationLabel, cg.calibrationSlider,
+				widget.NewLabelWithStyle("Limits:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+				cg.maxVolumeLabel, cg.maxVolumeSlider,
+				cg.maxStepLabel, cg.maxStepSlider,
+				widget.NewLabel("Startup:"), cg.startupSelect,
+				cg.startupMaxLabel, cg.startupMaxSlider,
+				widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+			),
+			cg.speakerContainer,
+			addButton,
+			widget.NewLabelWithStyle("Groups:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			cg.groupContainer,
+			widget.NewLabelWithStyle("Scenes:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			cg.sceneContainer,
 		),
 	)
 
+	cg.updateSpeakerList()
+	cg.updateSceneList()
 	cg.Update()
 
 	return cg
@@ -111,25 +149,234 @@ func (cg *ControllerConfigGui) Update() {
 		cg.dimSlider.SetValue(float64(cg.newConfig.Master.DimOffset))
 	}
 
-	cg.updateSpeaker()
+	// configs from older versions have no calibration level yet
+	if cg.newConfig.Master.CalibrationLevelDB == 0 {
+		cg.newConfig.Master.CalibrationLevelDB = monitorcontroller.DEFAULT_CALIBRATION_LEVEL_DB
+	}
+	cg.calibrationLabel.SetText(fmt.Sprintf("Calibration: %d dB", cg.newConfig.Master.CalibrationLevelDB))
+	if int(cg.calibrationSlider.Value) != cg.newConfig.Master.CalibrationLevelDB {
+		cg.calibrationSlider.SetValue(float64(cg.newConfig.Master.CalibrationLevelDB))
+	}
+
+	limits := cg.newConfig.Limits
+	cg.maxVolumeLabel.SetText(fmt.Sprintf("Max Volume: %d dB", limits.MaxVolumeDB))
+	if int(cg.maxVolumeSlider.Value) != limits.MaxVolumeDB {
+		cg.maxVolumeSlider.SetValue(float64(limits.MaxVolumeDB))
+	}
+	if limits.MaxStepDB == 0 {
+		cg.maxStepLabel.SetText("Max Step: unlimited")
+	} else {
+		cg.maxStepLabel.SetText(fmt.Sprintf("Max Step: %d dB", limits.MaxStepDB))
+	}
+	if int(cg.maxStepSlider.Value) != limits.MaxStepDB {
+		cg.maxStepSlider.SetValue(float64(limits.MaxStepDB))
+	}
+	if cg.startupSelect.Selected != string(limits.Startup) {
+		cg.startupSelect.SetSelected(string(limits.Startup))
+	}
+	cg.startupMaxLabel.SetText(fmt.Sprintf("Startup Max: %d dB", limits.StartupMaxDB))
+	if int(cg.startupMaxSlider.Value) != limits.StartupMaxDB {
+		cg.startupMaxSlider.SetValue(float64(limits.StartupMaxDB))
+	}
+	if limits.Startup == monitorcontroller.StartupCapped {
+		cg.startupMaxSlider.Enable()
+	} else {
+		cg.startupMaxSlider.Disable()
+	}
+
+	for spkId, slider := range cg.speakerTrim {
+		trim := cg.newConfig.Speaker[spkId].TrimDB
+		cg.speakerTrimLabel[spkId].SetText(fmt.Sprintf("Trim: %+d dB", trim))
+		if int(slider.Value) != trim {
+			slider.SetValue(float64(trim))
+		}
+	}
 }
 
-func (cg *ControllerConfigGui) updateSpeaker() {
-	cg.speakerADisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerA].Disabled)
-	cg.speakerAExclusive.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerA].Exclusive)
+// speakersChanged rebuilds the speaker list and tells the other config parts
+func (cg *ControllerConfigGui) speakersChanged() {
+	cg.updateSpeakerList()
+	cg.Update()
+	if cg.OnSpeakersChanged != nil {
+		cg.OnSpeakersChanged()
+	}
+}
 
-	cg.speakerADisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerA].Disabled)
-	cg.speakerAExclusive.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerA].Exclusive)
+// updateSpeakerList creates the widgets of all speakers in list order
+func (cg *ControllerConfigGui) updateSpeakerList() {
+	cg.speakerContainer.RemoveAll()
+	cg.speakerTrim = make(map[monitorcontroller.SpeakerID]*widget.Slider)
+	cg.speakerTrimLabel = make(map[monitorcontroller.SpeakerID]*widget.Label)
+
+	ids := cg.newConfig.SpeakerIDs()
+	for i, spkId := range ids {
+		spk := cg.newConfig.Speaker[spkId]
+
+		name := widget.NewEntry()
+		name.SetText(spk.Name)
+		name.OnChanged = func(s string) {
+			spk.Name = s
+			if cg.OnSpeakersChanged != nil {
+				cg.OnSpeakersChanged()
+			}
+		}
+
+		disabled := widget.NewCheck("Disabled", func(b bool) {
+			spk.Disabled = b
+		})
+		disabled.SetChecked(spk.Disabled)
+
+		exclusive := widget.NewCheck("Exclusive", func(b bool) {
+			spk.Exclusive = b
+		})
+		exclusive.SetChecked(spk.Exclusive)
+
+		group := widget.NewEntry()
+		group.SetText(spk.Group)
+		group.SetPlaceHolder(spk.Type.String())
+		group.OnChanged = func(s string) {
+			spk.Group = s
+			cg.updateGroupList()
+		}
+
+		speakerType := widget.NewSelect([]string{monitorcontroller.Speaker.String(), monitorcontroller.Subwoofer.String()}, func(s string) {
+			for t, typeName := range monitorcontroller.SpeakerTypeName {
+				if typeName == s {
+					spk.Type = t
+				}
+			}
+			group.SetPlaceHolder(spk.Type.String())
+			cg.updateGroupList()
+		})
+		speakerType.SetSelected(spk.Type.String())
+
+		up := widget.NewButton("Up", func() {
+			cg.newConfig.MoveSpeaker(spkId, -1)
+			cg.speakersChanged()
+		})
+		if i == 0 {
+			up.Disable()
+		}
+		down := widget.NewButton("Down", func() {
+			cg.newConfig.MoveSpeaker(spkId, 1)
+			cg.speakersChanged()
+		})
+		if i == len(ids)-1 {
+			down.Disable()
+		}
+		remove := widget.NewButton("Remove", func() {
+			cg.newConfig.RemoveSpeaker(spkId)
+			cg.speakersChanged()
+		})
+		if len(ids) == 1 {
+			remove.Disable()
+		}
+
+		cg.speakerTrimLabel[spkId] = widget.NewLabel("")
+		trim := widget.NewSlider(float64(monitorcontroller.TRIM_MIN_DB), float64(monitorcontroller.TRIM_MAX_DB))
+		trim.Step = 1
+		trim.SetValue(float64(spk.TrimDB))
+		trim.OnChanged = func(f float64) {
+			log.Debugf("new trim %s: %f", spk.Name, f)
+			spk.TrimDB = int(f)
+			cg.Update()
+		}
+		cg.speakerTrim[spkId] = trim
+
+		cg.speakerContainer.Add(name)
+		cg.speakerContainer.Add(container.NewHBox(disabled, exclusive, up, down, remove))
+		cg.speakerContainer.Add(widget.NewLabel("Type / Group:"))
+		cg.speakerContainer.Add(container.NewGridWithColumns(2, speakerType, group))
+		cg.speakerContainer.Add(cg.speakerTrimLabel[spkId])
+		cg.speakerContainer.Add(trim)
+	}
+	cg.speakerContainer.Refresh()
 
-	cg.speakerBDisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerB].Disabled)
-	cg.speakerBExclusive.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerB].Exclusive)
+	cg.updateGroupList()
+}
+
+func (cg *ControllerConfigGui) scenesChanged() {
+	cg.updateSceneList()
+	if cg.OnScenesChanged != nil {
+		cg.OnScenesChanged()
+	}
+}
+
+// updateSceneList shows the scenes in recall order, scenes are stored in the main window or the tray menu
+func (cg *ControllerConfigGui) updateSceneList() {
+	cg.sceneContainer.RemoveAll()
+
+	for i, scene := range cg.newConfig.Scenes {
+		name := widget.NewEntry()
+		name.SetText(scene.Name)
+		name.OnChanged = func(s string) {
+			cg.newConfig.Scenes[i].Name = s
+			if cg.OnScenesChanged != nil {
+				cg.OnScenesChanged()
+			}
+		}
+
+		up := widget.NewButton("Up", func() {
+			cg.newConfig.MoveScene(i, -1)
+			cg.scenesChanged()
+		})
+		if i == 0 {
+			up.Disable()
+		}
+		down := widget.NewButton("Down", func() {
+			cg.newConfig.MoveScene(i, 1)
+			cg.scenesChanged()
+		})
+		if i == len(cg.newConfig.Scenes)-1 {
+			down.Disable()
+		}
+		remove := widget.NewButton("Remove", func() {
+			cg.newConfig.RemoveScene(i)
+			cg.scenesChanged()
+		})
+
+		cg.sceneContainer.Add(name)
+		cg.sceneContainer.Add(container.NewHBox(up, down, remove))
+	}
+	if len(cg.newConfig.Scenes) == 0 {
+		cg.sceneContainer.Add(widget.NewLabel("No scenes stored"))
+		cg.sceneContainer.Add(layout.NewSpacer())
+	}
+	cg.sceneContainer.Refresh()
+}
 
-	cg.speakerCDisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerC].Disabled)
-	cg.speakerCExclusive.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerC].Exclusive)
+// updateGroupList shows the selection rule of every group used by the speakers
+func (cg *ControllerConfigGui) updateGroupList() {
+	cg.groupContainer.RemoveAll()
 
-	cg.speakerDDisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerD].Disabled)
-	cg.speakerDExclusive.SetChecked(cg.newConfig.Speaker[monitorcontroller.SpeakerD].Exclusive)
+	options := make([]string, 0, len(monitorcontroller.SelectionRules))
+	for _, rule := range monitorcontroller.SelectionRules {
+		options = append(options, ruleName(rule))
+	}
 
-	cg.speakerSubDisable.SetChecked(cg.newConfig.Speaker[monitorcontroller.Sub].Disabled)
+	for _, name := range cg.newConfig.GroupNames() {
+		rule := widget.NewSelect(options, func(s string) {
+			for _, rule := range monitorcontroller.SelectionRules {
+				if ruleName(rule) != s {
+					continue
+				}
+				if cg.newConfig.Groups == nil {
+					cg.newConfig.Groups = make(map[string]monitorcontroller.SelectionRule)
+				}
+				cg.newConfig.Groups[name] = rule
+			}
+		})
+		rule.SetSelected(ruleName(cg.newConfig.Groups[name]))
+
+		cg.groupContainer.Add(widget.NewLabel(name + ":"))
+		cg.groupContainer.Add(rule)
+	}
+	cg.groupContainer.Refresh()
+}
 
+func ruleName(rule monitorcontroller.SelectionRule) string {
+	if rule == monitorcontroller.RuleExclusiveFlag {
+		return "exclusive speakers"
+	}
+	return string(rule)
 }
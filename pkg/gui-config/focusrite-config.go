@@ -1,7 +1,9 @@
 package guiconfig
 
 import (
+	"context"
 	"fmt"
+	"slices"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -13,6 +15,8 @@ import (
 	"github.com/sebastianrau/focusrite-mackie-control/pkg/monitorcontroller"
 )
 
+const SERVER_AUTO string = "Auto"
+
 type FocusriteConfigGui struct {
 	newConfig *fcaudioconnector.FcConfiguration
 
@@ -22,12 +26,12 @@ type FocusriteConfigGui struct {
 	fcSelectedDeviceString string
 	fcSelectedDevice       *focusritexml.Device
 
-	SpeakerASel   *widget.Select
-	SpeakerBSel   *widget.Select
-	SpeakerCSel   *widget.Select
-	SpeakerDSel   *widget.Select
-	SpeakerSubSel *widget.Select
+	fcServerList map[string]focusriteclient.DiscoveredServer
+
+	speakerSelect    map[monitorcontroller.SpeakerID]*widget.Select
+	speakerContainer *fyne.Container
 
+	ServerSelect       *widget.Select
 	DeviceSelect       *widget.Select
 	DeviceSnLabel      *widget.Label
 	DeviceMuteCheckbox *widget.Check
@@ -42,9 +46,28 @@ func NewFocusriteConfigGui(cfg *fcaudioconnector.FcConfiguration) *FocusriteConf
 		newConfig:        cfg,
 		fcSelectedDevice: nil,
 		fcDeviceList:     map[string]*focusritexml.Device{},
+		fcServerList:     map[string]focusriteclient.DiscoveredServer{},
+		speakerSelect:    map[monitorcontroller.SpeakerID]*widget.Select{},
+		speakerContainer: container.New(layout.NewFormLayout()),
 	}
 
-	fc.fClient = focusriteclient.NewFocusriteClient(focusriteclient.UpdateDevice)
+	fc.ServerSelect = widget.NewSelect([]string{SERVER_AUTO}, func(selected string) {
+		if selected == SERVER_AUTO {
+			fc.newConfig.FocusriteHost = ""
+			fc.newConfig.FocusritePort = 0
+			fc.newConfig.FocusriteHostname = ""
+			return
+		}
+
+		server, ok := fc.fcServerList[selected]
+		if !ok {
+			return
+		}
+		fc.newConfig.FocusriteHost = server.Address
+		fc.newConfig.FocusritePort = 0 // port changes with every start of Focusrite Control
+		fc.newConfig.FocusriteHostname = server.Hostname
+		log.Debugf("Selected Server: %s", selected)
+	})
 
 	fc.DeviceSelect = widget.NewSelect([]string{}, func(selected string) {
 		if selected != "" {
@@ -55,8 +78,12 @@ func NewFocusriteConfigGui(cfg *fcaudioconnector.FcConfiguration) *FocusriteConf
 			}
 
 			fc.newConfig.FocusriteSerialNumber = dev.SerialNumber
-			fc.newConfig.Master.MuteSwitch = fcaudioconnector.FocusriteId(dev.Monitoring.HardwareControls.Controls.Mute.ID)
-			fc.newConfig.Master.DimSwitch = fcaudioconnector.FocusriteId(dev.Monitoring.HardwareControls.Controls.Dim.ID)
+			fc.newConfig.Master.MuteSwitch = fcaudioconnector.NewFocusriteElement(dev, dev.Monitoring.HardwareControls.Controls.Mute.ID)
+			fc.newConfig.Master.DimSwitch = fcaudioconnector.NewFocusriteElement(dev, dev.Monitoring.HardwareControls.Controls.Dim.ID)
+			fc.newConfig.Master.VolumeKnob = fcaudioconnector.NewFocusriteElement(dev, dev.Monitoring.HardwareControls.Controls.Gain.ID)
+			fc.newConfig.Master.AltEnable = fcaudioconnector.NewFocusriteElement(dev, dev.Monitoring.HardwareControls.Controls.AltEnable.ID)
+			fc.newConfig.Master.AltSwitch = fcaudioconnector.NewFocusriteElement(dev, dev.Monitoring.HardwareControls.Controls.Alt.ID)
+			fc.resolveConfig(dev)
 
 			fc.fcSelectedDevice = dev
 			fc.fcSelectedDeviceString = fmt.Sprintf("%s (%s)", dev.Model, dev.SerialNumber)
@@ -69,77 +96,136 @@ func NewFocusriteConfigGui(cfg *fcaudioconnector.FcConfiguration) *FocusriteConf
 	fc.DeviceMuteCheckbox = widget.NewCheck("", func(b bool) {})
 	fc.DeviceDimCheckbox = widget.NewCheck("", func(b bool) {})
 
-	fc.SpeakerASel = widget.NewSelect([]string{}, func(s string) {
-		fc.updateSpeakerConfig(s, monitorcontroller.SpeakerA)
-		log.Debugf("Selected: %s", s)
-	})
-
-	fc.SpeakerBSel = widget.NewSelect([]string{}, func(s string) {
-		fc.updateSpeakerConfig(s, monitorcontroller.SpeakerB)
-		log.Debugf("Selected: %s", s)
-	})
-
-	fc.SpeakerCSel = widget.NewSelect([]string{}, func(s string) {
-		fc.updateSpeakerConfig(s, monitorcontroller.SpeakerC)
-		log.Debugf("Selected: %s", s)
-	})
-
-	fc.SpeakerDSel = widget.NewSelect([]string{}, func(s string) {
-		fc.updateSpeakerConfig(s, monitorcontroller.SpeakerD)
-		log.Debugf("Selected: %s", s)
-	})
-
-	fc.SpeakerSubSel = widget.NewSelect([]string{}, func(s string) {
-		fc.updateSpeakerConfig(s, monitorcontroller.Sub)
-		log.Debugf("Selected: %s", s)
-	})
+	// configs from older versions have no ramps yet
+	if cfg.Ramp == nil {
+		cfg.Ramp = fcaudioconnector.DefaultRampConfig()
+	}
+	rampContainer := container.New(layout.NewFormLayout(),
+		widget.NewLabelWithStyle("Ramps:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+	)
+	rampContainer.Objects = append(rampContainer.Objects, rampSlider("Unmute", "ms", &cfg.Ramp.UnmuteMs, 2000, 50)...)
+	rampContainer.Objects = append(rampContainer.Objects, rampSlider("Dim", "ms", &cfg.Ramp.DimMs, 2000, 50)...)
+	rampContainer.Objects = append(rampContainer.Objects, rampSlider("Volume Jump", "ms", &cfg.Ramp.VolumeMs, 2000, 50)...)
+	rampContainer.Objects = append(rampContainer.Objects, rampSlider("Jumps from", "dB", &cfg.Ramp.VolumeJumpDB, 40, 1)...)
+	rampContainer.Objects = append(rampContainer.Objects, rampSlider("Speaker Switch", "ms", &cfg.Ramp.SwitchMs, 2000, 50)...)
 
 	fc.Container = widget.NewAccordionItem("Focusrite:",
-		container.New(layout.NewFormLayout(),
-			widget.NewLabel("Device:"), fc.DeviceSelect,
-			widget.NewLabel("Serial Number"), fc.DeviceSnLabel,
-			widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
-			widget.NewLabel("Master Mute"), fc.DeviceMuteCheckbox,
-			widget.NewLabel("Master Dim"), fc.DeviceDimCheckbox,
-			widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
-			widget.NewLabel("Speaker A:"), fc.SpeakerASel,
-			widget.NewLabel("Speaker B:"), fc.SpeakerBSel,
-			widget.NewLabel("Speaker C:"), fc.SpeakerCSel,
-			widget.NewLabel("Speaker D:"), fc.SpeakerDSel,
-			widget.NewLabel("Subwoofer:"), fc.SpeakerSubSel,
+		container.NewVBox(
+			container.New(layout.NewFormLayout(),
+				widget.NewLabel("Server:"), fc.ServerSelect,
+				widget.NewLabel("Device:"), fc.DeviceSelect,
+				widget.NewLabel("Serial Number"), fc.DeviceSnLabel,
+				widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+				widget.NewLabel("Master Mute"), fc.DeviceMuteCheckbox,
+				widget.NewLabel("Master Dim"), fc.DeviceDimCheckbox,
+				widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+			),
+			fc.speakerContainer,
+			rampContainer,
 		),
 	)
 
-	widget.NewLabelWithStyle("test", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	return fc
+}
+
+// rampSlider returns a label and a slider editing value
+func rampSlider(name string, unit string, value *int, max float64, step float64) []fyne.CanvasObject {
+	label := widget.NewLabel(fmt.Sprintf("%s: %d %s", name, *value, unit))
+	slider := widget.NewSlider(0, max)
+	slider.Step = step
+	slider.SetValue(float64(*value))
+	slider.OnChanged = func(f float64) {
+		*value = int(f)
+		label.SetText(fmt.Sprintf("%s: %d %s", name, *value, unit))
+	}
+	return []fyne.CanvasObject{label, slider}
+}
 
-	go fc.run()
+// Start connects a Focusrite client to list the available devices
+func (fc *FocusriteConfigGui) Start(ctx context.Context) error {
+	if fc.fClient != nil {
+		return nil
+	}
 
-	return fc
+	client := focusriteclient.NewFocusriteClient(focusriteclient.UpdateDevice, fc.newConfig.ServerConfig())
+	// same key as the main connection, so the app needs to be approved only once
+	client.SetClientIdentity(fc.newConfig.ClientKey, "")
+	err := client.Start(ctx)
+	if err != nil {
+		return err
+	}
+	fc.fClient = client
+
+	go fc.run(client)
+	return nil
+}
+
+// Close disconnects the Focusrite client
+func (fc *FocusriteConfigGui) Close() error {
+	if fc.fClient == nil {
+		return nil
+	}
+	err := fc.fClient.Close()
+	fc.fClient = nil
+	return err
 }
 
-func (fc *FocusriteConfigGui) run() {
-	for msg := range fc.fClient.FromFocusrite {
+func (fc *FocusriteConfigGui) run(client *focusriteclient.FocusriteClient) {
+	for msg := range client.FromFocusrite {
 		switch m := msg.(type) {
 		case focusriteclient.ApprovalMessasge:
 		case focusriteclient.ConnectionStatusMessage:
 
+		case focusriteclient.DiscoveryMessage:
+			fc.updateServerSelect(m)
+
 		case focusriteclient.DeviceArrivalMessage:
 			log.Debugf("Device Arrived len:%d", len(fc.fcDeviceList))
-			fc.updateDeviceSelect(fc.fClient.DeviceList)
+			fc.updateDeviceSelect(client.DeviceList)
 
 		case focusriteclient.DeviceRemovalMessage:
 			log.Debugf("Device Removel: %d", m)
-			fc.updateDeviceSelect(fc.fClient.DeviceList)
+			fc.updateDeviceSelect(client.DeviceList)
 
 		case focusriteclient.DeviceUpdateMessage:
 			log.Debugf("Device Update: %s", m.SerialNumber)
-			fc.updateDeviceSelect(fc.fClient.DeviceList)
+			fc.updateDeviceSelect(client.DeviceList)
 
 		case focusriteclient.RawUpdateMessage:
 		}
 	}
 }
 
+func (fc *FocusriteConfigGui) updateServerSelect(servers []focusriteclient.DiscoveredServer) {
+	options := []string{SERVER_AUTO}
+	selected := SERVER_AUTO
+	fc.fcServerList = make(map[string]focusriteclient.DiscoveredServer)
+
+	for _, server := range servers {
+		text := server.String()
+		options = append(options, text)
+		fc.fcServerList[text] = server
+		if fc.newConfig.FocusriteHostname != "" && fc.newConfig.FocusriteHostname == server.Hostname {
+			selected = text
+		}
+	}
+
+	// keep a configured server which is currently not reachable
+	if selected == SERVER_AUTO && fc.newConfig.FocusriteHostname != "" {
+		server := focusriteclient.DiscoveredServer{
+			Hostname: fc.newConfig.FocusriteHostname,
+			Address:  fc.newConfig.FocusriteHost,
+			Port:     fc.newConfig.FocusritePort,
+		}
+		selected = server.String()
+		options = append(options, selected)
+		fc.fcServerList[selected] = server
+	}
+
+	fc.ServerSelect.SetOptions(options)
+	fc.ServerSelect.SetSelected(selected)
+}
+
 func (fc *FocusriteConfigGui) updateDeviceSelect(list focusriteclient.DeviceList) {
 	options := make([]string, 0)
 	fc.fcDeviceList = make(map[string]*focusritexml.Device)
@@ -152,6 +238,7 @@ func (fc *FocusriteConfigGui) updateDeviceSelect(list focusriteclient.DeviceList
 		if fc.newConfig.FocusriteSerialNumber == device.SerialNumber {
 			fc.fcSelectedDeviceString = text
 			fc.fcSelectedDevice = device
+			fc.resolveConfig(device)
 			log.Debugf("Selected Device: %s", text)
 		}
 	}
@@ -184,22 +271,97 @@ func (c *FocusriteConfigGui) updateDeviceDetails() {
 	// Set Config
 	c.DeviceSnLabel.SetText(c.newConfig.FocusriteSerialNumber)
 	c.DeviceDimCheckbox.Enable()
-	c.DeviceDimCheckbox.Checked = c.newConfig.Master.DimSwitch != 0
+	c.DeviceDimCheckbox.Checked = c.newConfig.Master.DimSwitch.ID != 0
 	c.DeviceDimCheckbox.Disable()
 
 	c.DeviceMuteCheckbox.Enable()
-	c.DeviceMuteCheckbox.Checked = c.newConfig.Master.MuteSwitch != 0
+	c.DeviceMuteCheckbox.Checked = c.newConfig.Master.MuteSwitch.ID != 0
 	c.DeviceMuteCheckbox.Disable()
 }
 
+// resolveConfig sets the IDs of the configured paths for the selected device
+func (fc *FocusriteConfigGui) resolveConfig(dev *focusritexml.Device) {
+	for _, err := range fc.newConfig.Resolve(dev) {
+		log.Warnf("%s (%s): %s", dev.Model, dev.SerialNumber, err.Error())
+	}
+}
+
+// UpdateSpeakers creates an output selection per speaker and drops the outputs of removed speakers
+func (fc *FocusriteConfigGui) UpdateSpeakers(speakers *monitorcontroller.ControllerSate) {
+	for spkId := range fc.newConfig.Speaker {
+		if _, ok := speakers.Speaker[spkId]; !ok {
+			delete(fc.newConfig.Speaker, spkId)
+		}
+	}
+	known := func(ids []monitorcontroller.SpeakerID) []monitorcontroller.SpeakerID {
+		return slices.DeleteFunc(ids, func(id monitorcontroller.SpeakerID) bool {
+			_, ok := speakers.Speaker[id]
+			return !ok
+		})
+	}
+	fc.newConfig.MainSpeaker = known(fc.newConfig.MainSpeaker)
+	fc.newConfig.AltSpeaker = known(fc.newConfig.AltSpeaker)
+
+	fc.speakerContainer.RemoveAll()
+	speakerSelect := make(map[monitorcontroller.SpeakerID]*widget.Select)
+
+	for _, spkId := range speakers.SpeakerIDs() {
+		sel := widget.NewSelect([]string{}, func(s string) {
+			fc.updateSpeakerConfig(s, spkId)
+			log.Debugf("Selected: %s", s)
+		})
+		speakerSelect[spkId] = sel
+
+		fc.speakerContainer.Add(widget.NewLabel(speakers.Speaker[spkId].Name + ":"))
+		fc.speakerContainer.Add(sel)
+	}
+	fc.speakerSelect = speakerSelect
+	fc.speakerContainer.Refresh()
+
+	fc.updateAllSpeakerSelect()
+}
+
 func (fc *FocusriteConfigGui) updateAllSpeakerSelect() {
-	fc.updateSpeakerSelect(fc.SpeakerASel, monitorcontroller.SpeakerA)
-	fc.updateSpeakerSelect(fc.SpeakerBSel, monitorcontroller.SpeakerB)
-	fc.updateSpeakerSelect(fc.SpeakerCSel, monitorcontroller.SpeakerC)
-	fc.updateSpeakerSelect(fc.SpeakerDSel, monitorcontroller.SpeakerD)
-	fc.updateSpeakerSelect(fc.SpeakerSubSel, monitorcontroller.Sub)
+	for spkId, sel := range fc.speakerSelect {
+		fc.updateSpeakerSelect(sel, spkId)
+	}
+}
+
+// outputGroup are consecutive analogue outputs used by one speaker
+type outputGroup struct {
+	label string
+	first int
+	count int
 }
 
+// outputGroups lists the stereo pairs, the 5.1 and 7.1 sets starting at a stereo pair and the mono outputs
+func outputGroups(dev *focusritexml.Device) []outputGroup {
+	outs := dev.Outputs.Analogues
+	groups := make([]outputGroup, 0)
+
+	for i, out := range outs {
+		if out.StereoName != "" && i+2 <= len(outs) {
+			groups = append(groups, outputGroup{label: out.StereoName, first: i, count: 2})
+		}
+	}
+	for _, layout := range []struct {
+		name  string
+		count int
+	}{{"5.1", 6}, {"7.1", 8}} {
+		for i, out := range outs {
+			if out.StereoName != "" && i+layout.count <= len(outs) {
+				label := fmt.Sprintf("%s - %s (%s)", out.Name, outs[i+layout.count-1].Name, layout.name)
+				groups = append(groups, outputGroup{label: label, first: i, count: layout.count})
+			}
+		}
+	}
+	for i, out := range outs {
+		groups = append(groups, outputGroup{label: out.Name, first: i, count: 1})
+	}
+	return groups
+}
+
+// updateSpeakerConfig maps the speaker to a mono, stereo or surround group of outputs
 func (c *FocusriteConfigGui) updateSpeakerConfig(selected string, spkID monitorcontroller.SpeakerID) {
 
 	if c.fcSelectedDevice == nil {
@@ -207,23 +369,36 @@ func (c *FocusriteConfigGui) updateSpeakerConfig(selected string, spkID monitorc
 	}
 
 	if selected == "none" {
-		c.newConfig.Speaker[spkID].Name = 0
-		c.newConfig.Speaker[spkID].MeterL = 0
-		c.newConfig.Speaker[spkID].MeterR = 0
-		c.newConfig.Speaker[spkID].Mute = 0
-		c.newConfig.Speaker[spkID].OutputGain = 0
-
+		delete(c.newConfig.Speaker, spkID)
+		return
 	}
 
 	dev := c.fcSelectedDevice
-	for i, anOut := range dev.Outputs.Analogues {
-		if anOut.StereoName == selected /*&& anOut.Available.Value == true*/ {
-			c.newConfig.Speaker[spkID].Name = fcaudioconnector.FocusriteId(anOut.Nickname.ID)
-			c.newConfig.Speaker[spkID].MeterL = fcaudioconnector.FocusriteId(anOut.Meter.ID)
-			c.newConfig.Speaker[spkID].Mute = fcaudioconnector.FocusriteId(anOut.Mute.ID)
-			c.newConfig.Speaker[spkID].OutputGain = fcaudioconnector.FocusriteId(anOut.Gain.ID)
-			c.newConfig.Speaker[spkID].MeterR = fcaudioconnector.FocusriteId(dev.Outputs.Analogues[i+1].Meter.ID)
+	for _, group := range outputGroups(dev) {
+		if group.label != selected {
+			continue
+		}
+
+		outputs := dev.Outputs.Analogues[group.first : group.first+group.count]
+		names := fcaudioconnector.ChannelNames(group.count)
+
+		spk := &fcaudioconnector.SpeakerFcConfig{
+			Name:     fcaudioconnector.NewFocusriteElement(dev, outputs[0].Nickname.ID),
+			Channels: make([]fcaudioconnector.SpeakerChannelFcConfig, 0, len(outputs)),
+		}
+		for i, out := range outputs {
+			spk.Channels = append(spk.Channels, fcaudioconnector.SpeakerChannelFcConfig{
+				Name:       names[i],
+				Mute:       fcaudioconnector.NewFocusriteElement(dev, out.Mute.ID),
+				OutputGain: fcaudioconnector.NewFocusriteElement(dev, out.Gain.ID),
+				Meter:      fcaudioconnector.NewFocusriteElement(dev, out.Meter.ID),
+			})
 		}
+		if c.newConfig.Speaker == nil {
+			c.newConfig.Speaker = make(map[monitorcontroller.SpeakerID]*fcaudioconnector.SpeakerFcConfig)
+		}
+		c.newConfig.Speaker[spkID] = spk
+		break
 	}
 
 	log.Debugf("New Config: %v", c.newConfig.Speaker[spkID])
@@ -233,22 +408,28 @@ func (c *FocusriteConfigGui) updateSpeakerSelect(sel *widget.Select, spkId monit
 	if c.fcSelectedDevice == nil {
 		return
 	}
-	log.Debugf("Updating Speaker Selector %s - %s", monitorcontroller.SpeakerName[spkId], monitorcontroller.SpeakerName[spkId])
+	log.Debugf("Updating Speaker Selector %d", spkId)
 
 	outputList := make([]string, 0)
 
 	outputList = append(outputList, "none")
 	selectedOutput := ""
 
-	for _, outs := range c.fcSelectedDevice.Outputs.Analogues {
-		if outs.StereoName != "" {
-			outputList = append(outputList, outs.StereoName)
+	var channels []fcaudioconnector.SpeakerChannelFcConfig
+	if spk, ok := c.newConfig.Speaker[spkId]; ok && spk != nil {
+		channels = spk.Channels
+	}
+	for _, group := range outputGroups(c.fcSelectedDevice) {
+		outputList = append(outputList, group.label)
 
-			if outs.Mute.ID == int(c.newConfig.Speaker[spkId].Mute) {
-				selectedOutput = outs.StereoName
-			}
+		first := c.fcSelectedDevice.Outputs.Analogues[group.first]
+		if len(channels) == group.count && first.Mute.ID == int(channels[0].Mute.ID) {
+			selectedOutput = group.label
 		}
 	}
+	if len(channels) == 0 {
+		selectedOutput = "none"
+	}
 	sel.SetOptions(outputList)
 	sel.Refresh()
 	sel.SetSelected(selectedOutput)
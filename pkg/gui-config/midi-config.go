@@ -1,6 +1,8 @@
 package guiconfig
 
 import (
+	"fmt"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
@@ -11,6 +13,8 @@ import (
 	"gitlab.com/gomidi/midi/v2"
 )
 
+const SWITCH_NONE string = "none"
+
 type MidiConfigGui struct {
 	newConfig *mcuconnector.McuConnectorConfig
 
@@ -21,18 +25,27 @@ type MidiConfigGui struct {
 	masterDimSelect   *widget.Select
 	masterFaderSelect *widget.Select
 
-	speakerASelect   *widget.Select
-	speakerBSelect   *widget.Select
-	speakerCSelect   *widget.Select
-	speakerDSelect   *widget.Select
-	speakerSubSelect *widget.Select
+	speakerSelect    map[monitorcontroller.SpeakerID]*widget.Select
+	speakerContainer *fyne.Container
+
+	sceneStoreSelect *widget.Select
+	sceneSelect      map[int]*widget.Select
+	sceneContainer   *fyne.Container
 
 	Container *widget.AccordionItem
 }
 
 func NewMidiConfigGui(cfg *mcuconnector.McuConnectorConfig) *MidiConfigGui {
 	mc := &MidiConfigGui{
-		newConfig: cfg,
+		newConfig:        cfg,
+		speakerSelect:    make(map[monitorcontroller.SpeakerID]*widget.Select),
+		speakerContainer: container.New(layout.NewFormLayout()),
+		sceneSelect:      make(map[int]*widget.Select),
+		sceneContainer:   container.New(layout.NewFormLayout()),
+	}
+
+	if mc.newConfig.SpeakerSelect == nil {
+		mc.newConfig.SpeakerSelect = make(map[monitorcontroller.SpeakerID]gomcu.Switch)
 	}
 
 	// Midi Config
@@ -62,71 +75,48 @@ func NewMidiConfigGui(cfg *mcuconnector.McuConnectorConfig) *MidiConfigGui {
 		mc.newConfig.MasterMuteSwitch = sw
 	})
 
-	mc.masterFaderSelect = widget.NewSelect(getMcuChannels(), func(s string) {
-		sw, ok := gomcu.ChannelIDs[s]
-		if !ok {
-			log.Error("cant find midi ID")
-			return
-		}
-		mc.newConfig.MasterVolumeChannel = sw
-	})
+	if mc.newConfig.SceneSwitch == nil {
+		mc.newConfig.SceneSwitch = make(map[int]gomcu.Switch)
+	}
 
-	mc.speakerASelect = widget.NewSelect(getMcuSwtiches(), func(s string) {
-		sw, ok := gomcu.IDs[s]
-		if !ok {
-			log.Error("cant find midi ID")
-			return
-		}
-		mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerA] = sw
-	})
-	mc.speakerBSelect = widget.NewSelect(getMcuSwtiches(), func(s string) {
-		sw, ok := gomcu.IDs[s]
-		if !ok {
-			log.Error("cant find midi ID")
+	mc.sceneStoreSelect = widget.NewSelect(append([]string{SWITCH_NONE}, getMcuSwtiches()...), func(s string) {
+		if s == SWITCH_NONE {
+			mc.newConfig.SceneStoreSwitch = nil
 			return
 		}
-		mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerB] = sw
-	})
-	mc.speakerCSelect = widget.NewSelect(getMcuSwtiches(), func(s string) {
 		sw, ok := gomcu.IDs[s]
 		if !ok {
 			log.Error("cant find midi ID")
 			return
 		}
-		mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerC] = sw
+		mc.newConfig.SceneStoreSwitch = &sw
 	})
-	mc.speakerDSelect = widget.NewSelect(getMcuSwtiches(), func(s string) {
-		sw, ok := gomcu.IDs[s]
-		if !ok {
-			log.Error("cant find midi ID")
-			return
-		}
-		mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerD] = sw
-	})
-	mc.speakerSubSelect = widget.NewSelect(getMcuSwtiches(), func(s string) {
-		sw, ok := gomcu.IDs[s]
+
+	mc.masterFaderSelect = widget.NewSelect(getMcuChannels(), func(s string) {
+		sw, ok := gomcu.ChannelIDs[s]
 		if !ok {
 			log.Error("cant find midi ID")
 			return
 		}
-		mc.newConfig.SpeakerSelect[monitorcontroller.Sub] = sw
+		mc.newConfig.MasterVolumeChannel = sw
 	})
 
-	// Speaker Section:
-	//((SpeakerSelect map[monitorcontroller.SpeakerID][]gomcu.Switch
-
 	mc.Container = widget.NewAccordionItem("Midi:",
-		container.New(layout.NewFormLayout(),
-			widget.NewLabel("Input Port:"), mc.inputSelect,
-			widget.NewLabel("Output Port:"), mc.outputSelect,
-			widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(), widget.NewLabel("Fader:"), mc.masterFaderSelect,
-			widget.NewLabel("Mute:"), mc.masterMuteSelect,
-			widget.NewLabel("Dim:"), mc.masterDimSelect,
-			widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(), widget.NewLabel("Speaker A:"), mc.speakerASelect,
-			widget.NewLabel("Speaker B:"), mc.speakerBSelect,
-			widget.NewLabel("Speaker C:"), mc.speakerCSelect,
-			widget.NewLabel("Speaker D:"), mc.speakerDSelect,
-			widget.NewLabel("Subwoofer:"), mc.speakerSubSelect,
+		container.NewVBox(
+			container.New(layout.NewFormLayout(),
+				widget.NewLabel("Input Port:"), mc.inputSelect,
+				widget.NewLabel("Output Port:"), mc.outputSelect,
+				widget.NewLabelWithStyle("Master:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(), widget.NewLabel("Fader:"), mc.masterFaderSelect,
+				widget.NewLabel("Mute:"), mc.masterMuteSelect,
+				widget.NewLabel("Dim:"), mc.masterDimSelect,
+				widget.NewLabelWithStyle("Speaker:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+			),
+			mc.speakerContainer,
+			container.New(layout.NewFormLayout(),
+				widget.NewLabelWithStyle("Scenes:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), layout.NewSpacer(),
+				widget.NewLabel("Store:"), mc.sceneStoreSelect,
+			),
+			mc.sceneContainer,
 		),
 	)
 
@@ -140,13 +130,98 @@ func (mc *MidiConfigGui) Update() {
 	mc.UpdateSwtich(mc.newConfig.MasterDimSwitch, mc.masterDimSelect)
 	mc.UpdateSwtich(mc.newConfig.MasterMuteSwitch, mc.masterMuteSelect)
 	mc.UpdateChannel(mc.newConfig.MasterVolumeChannel, mc.masterFaderSelect)
+	mc.updateSpeakerSwitches()
+	mc.updateSceneSwitches()
+}
+
+func (mc *MidiConfigGui) updateSceneSwitches() {
+	if mc.newConfig.SceneStoreSwitch == nil {
+		mc.sceneStoreSelect.SetSelected(SWITCH_NONE)
+	} else {
+		mc.UpdateSwtich(*mc.newConfig.SceneStoreSwitch, mc.sceneStoreSelect)
+	}
+
+	for index, sel := range mc.sceneSelect {
+		sw, ok := mc.newConfig.SceneSwitch[index]
+		if !ok {
+			sel.SetSelected(SWITCH_NONE)
+			continue
+		}
+		mc.UpdateSwtich(sw, sel)
+	}
+}
+
+// UpdateScenes creates a switch selection per scene, switches stay with the scene index
+func (mc *MidiConfigGui) UpdateScenes(state *monitorcontroller.ControllerSate) {
+	mc.sceneContainer.RemoveAll()
+	mc.sceneSelect = make(map[int]*widget.Select)
+
+	for index, name := range state.SceneNames() {
+		sel := widget.NewSelect(append([]string{SWITCH_NONE}, getMcuSwtiches()...), func(s string) {
+			if s == SWITCH_NONE {
+				delete(mc.newConfig.SceneSwitch, index)
+				return
+			}
+			sw, ok := gomcu.IDs[s]
+			if !ok {
+				log.Error("cant find midi ID")
+				return
+			}
+			mc.newConfig.SceneSwitch[index] = sw
+		})
+		mc.sceneSelect[index] = sel
 
-	mc.UpdateSwtich(mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerA], mc.speakerASelect)
-	mc.UpdateSwtich(mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerB], mc.speakerBSelect)
-	mc.UpdateSwtich(mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerC], mc.speakerCSelect)
-	mc.UpdateSwtich(mc.newConfig.SpeakerSelect[monitorcontroller.SpeakerD], mc.speakerDSelect)
-	mc.UpdateSwtich(mc.newConfig.SpeakerSelect[monitorcontroller.Sub], mc.speakerSubSelect)
+		mc.sceneContainer.Add(widget.NewLabel(fmt.Sprintf("%d %s:", index+1, name)))
+		mc.sceneContainer.Add(sel)
+	}
+	mc.sceneContainer.Refresh()
+
+	mc.updateSceneSwitches()
+}
+
+func (mc *MidiConfigGui) updateSpeakerSwitches() {
+	for spkId, sel := range mc.speakerSelect {
+		sw, ok := mc.newConfig.SpeakerSelect[spkId]
+		if !ok {
+			sel.SetSelected(SWITCH_NONE)
+			continue
+		}
+		mc.UpdateSwtich(sw, sel)
+	}
+}
+
+// UpdateSpeakers creates a switch selection per speaker and drops the switches of removed speakers
+func (mc *MidiConfigGui) UpdateSpeakers(speakers *monitorcontroller.ControllerSate) {
+	for spkId := range mc.newConfig.SpeakerSelect {
+		if _, ok := speakers.Speaker[spkId]; !ok {
+			delete(mc.newConfig.SpeakerSelect, spkId)
+		}
+	}
+
+	mc.speakerContainer.RemoveAll()
+	mc.speakerSelect = make(map[monitorcontroller.SpeakerID]*widget.Select)
+
+	for _, spkId := range speakers.SpeakerIDs() {
+		sel := widget.NewSelect(append([]string{SWITCH_NONE}, getMcuSwtiches()...), func(s string) {
+			if s == SWITCH_NONE {
+				delete(mc.newConfig.SpeakerSelect, spkId)
+				return
+			}
+			sw, ok := gomcu.IDs[s]
+			if !ok {
+				log.Error("cant find midi ID")
+				return
+			}
+			mc.newConfig.SpeakerSelect[spkId] = sw
+		})
+		mc.speakerSelect[spkId] = sel
+
+		mc.speakerContainer.Add(widget.NewLabel(speakers.Speaker[spkId].Name + ":"))
+		mc.speakerContainer.Add(sel)
+	}
+	mc.speakerContainer.Refresh()
 
+	mc.updateSpeakerSwitches()
 }
 
 func (mc *MidiConfigGui) UpdateSwtich(sw gomcu.Switch, sel *widget.Select) {
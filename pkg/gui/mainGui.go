@@ -1,9 +1,12 @@
 package gui
 
 import (
+	"context"
+	"fmt"
 	"image/color"
 	"os"
 	"os/exec"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -25,18 +28,16 @@ const APP_TITLE_CFG string = "Monitor Controller Configuration"
 
 const INFO_NO_DEVICE string = "No device connected"
 const INFO_NO_CONNECTION string = "No Focusrite Control connection"
+const INFO_APPROVAL string = "%s in Focusrite Control"
 
+// the fader gets its own volume back once it rests, so it doesn't jump while dragging
+const FADER_ECHO_SETTLE time.Duration = 200 * time.Millisecond
+
+// speaker buttons use the speaker ID as button ID
 const (
-	SpeakerA ButtonID = iota
-	SpeakerB
-	SpeakerC
-	SpeakerD
-	Sub
-	Spacer
+	Spacer ButtonID = -1 - iota
 	Mute
 	Dim
-
-	LEN
 )
 
 type buttonConfig struct {
@@ -58,42 +59,63 @@ var (
 )
 
 var (
-	btnDefinition = []buttonConfig{
-		{ID: SpeakerA, Name: "Speaker A", Color: GREEN},
-		{ID: SpeakerB, Name: "Speaker B", Color: GREEN},
-		{ID: SpeakerC, Name: "Speaker B", Color: GREEN},
-		{ID: SpeakerD, Name: "Speaker D", Color: GREEN},
-		{ID: Sub, Name: "Sub", Color: GREEN},
+	masterBtnDefinition = []buttonConfig{
 		{ID: Spacer, Name: "", Color: BLACK},
 		{ID: Mute, Name: "Mute", Color: RED},
 		{ID: Dim, Name: "Dim", Color: YELLOW},
 	}
 )
 
+// btnDefinition returns a button per configured speaker in list order followed by the master buttons
+func btnDefinition(state *monitorcontroller.ControllerSate) []buttonConfig {
+	buttons := make([]buttonConfig, 0)
+	for _, spkId := range state.SpeakerIDs() {
+		buttons = append(buttons, buttonConfig{ID: ButtonID(spkId), Name: state.Speaker[spkId].Name, Color: GREEN})
+	}
+	return append(buttons, masterBtnDefinition...)
+}
+
 type MainGui struct {
 	fader           *AudioFader
 	levelMeter      *AudioMeter
 	buttonContainer *fyne.Container
 	buttons         map[ButtonID]*ToggleButton
 
-	infoLabel *canvas.Text
-	infoIcon  *widget.Icon
+	sceneSelect    *widget.Select
+	sceneStore     *widget.Button
+	sceneAdd       *widget.Button
+	sceneContainer *fyne.Container
+
+	infoLabel  *canvas.Text
+	infoIcon   *widget.Icon
+	deviceInfo monitorcontroller.DeviceInfo
+	approval   monitorcontroller.ApprovalState
 
 	menuShow       *fyne.MenuItem
 	menuMute       *fyne.MenuItem
 	menuDim        *fyne.MenuItem
+	menuCalibrate  *fyne.MenuItem
+	menuScenes     *fyne.MenuItem
 	menuConfig     *fyne.MenuItem
 	menuSystemTray *fyne.Menu
 
 	masterValueChanged chan AudioLevelChanged
 	buttonPressed      chan ButtonEvent
 
-	controllerChannel  chan interface{}
-	masterVolumeBuffer int
+	controllerChannel chan interface{}
+
+	// last state reported by the controller, restored when a command is rejected
+	volume    int
+	mute      bool
+	dim       bool
+	selection map[ButtonID]bool
 
 	app          fyne.App
 	window       fyne.Window
 	windowConfig fyne.Window
+	configApp    *guiconfig.ConfigApp
+
+	OnShutdown func() // stops all connectors, called before a restart
 }
 
 func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
@@ -109,6 +131,7 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 		masterValueChanged: make(chan AudioLevelChanged, 100),
 		buttonPressed:      make(chan ButtonEvent, 100),
 		buttons:            make(map[ButtonID]*ToggleButton),
+		selection:          make(map[ButtonID]bool),
 	}
 
 	//App
@@ -129,10 +152,15 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 	mainGui.windowConfig = mainGui.app.NewWindow(APP_TITLE_CFG)
 	mainGui.windowConfig.Resize(fyne.NewSize(450, 600))
 
-	guiconfig.NewConfigApp(
+	mainGui.configApp = guiconfig.NewConfigApp(
 		mainGui.windowConfig,
 		cfg,
 		func() { // on restart app from Config Dialog
+			mainGui.closeConfig()
+			if mainGui.OnShutdown != nil {
+				mainGui.OnShutdown()
+			}
+
 			exe, err := os.Executable()
 			if err != nil {
 				log.Error(err.Error())
@@ -147,9 +175,10 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 		},
 
 		func() { // on Close of Config Dialog
-			mainGui.windowConfig.Hide()
+			mainGui.closeConfig()
 		},
 	)
+	mainGui.windowConfig.SetCloseIntercept(mainGui.closeConfig)
 
 	mainGui.fader = NewAudioFaderMeter(-127, 0, -10, false, mainGui.masterValueChanged)
 	mainGui.fader.SetLevel(-20)
@@ -161,11 +190,33 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 
 	mainGui.buttonContainer = container.NewVBox()
 
+	// Scenes, recalled by index
+	mainGui.sceneSelect = widget.NewSelect(cfg.MonitorController.SceneNames(), func(s string) {
+		if i := mainGui.sceneSelect.SelectedIndex(); i >= 0 {
+			mainGui.controllerChannel <- monitorcontroller.RcRecallScene(i)
+		}
+	})
+	mainGui.sceneSelect.PlaceHolder = "Scenes"
+	mainGui.sceneStore = widget.NewButtonWithIcon("", theme.DocumentSaveIcon(), func() {
+		i := mainGui.sceneSelect.SelectedIndex()
+		if i < 0 {
+			i = len(mainGui.sceneSelect.Options)
+		}
+		mainGui.controllerChannel <- monitorcontroller.RcStoreScene{Index: i}
+	})
+	mainGui.sceneAdd = widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		mainGui.controllerChannel <- monitorcontroller.RcStoreScene{Index: len(mainGui.sceneSelect.Options)}
+	})
+	mainGui.sceneContainer = container.NewBorder(nil, nil, nil, container.NewHBox(mainGui.sceneStore, mainGui.sceneAdd), mainGui.sceneSelect)
+	mainGui.sceneSelect.Disable()
+	mainGui.sceneStore.Disable()
+	mainGui.sceneAdd.Disable()
+
 	mainGui.infoIcon = widget.NewIcon(theme.NewDisabledResource(theme.BrokenImageIcon()))
 	mainGui.infoLabel = canvas.NewText(INFO_NO_DEVICE, theme.Color(theme.ColorNameDisabled))
 
 	// Action Buttons
-	for _, b := range btnDefinition {
+	for _, b := range btnDefinition(&cfg.MonitorController) {
 		if b.ID == Spacer {
 			img := canvas.NewImageFromResource(resourceLogoPng)
 			img.FillMode = canvas.ImageFillContain
@@ -198,7 +249,18 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 		mainGui.controllerChannel <- monitorcontroller.RcSetDim(!mainGui.menuDim.Checked)
 	})
 
+	mainGui.menuCalibrate = fyne.NewMenuItem("Calibration", func() {
+		mainGui.controllerChannel <- monitorcontroller.RcSetCalibration(!mainGui.menuCalibrate.Checked)
+	})
+
+	mainGui.menuScenes = fyne.NewMenuItem("Scenes", nil)
+	mainGui.menuScenes.ChildMenu = mainGui.sceneMenu(cfg.MonitorController.SceneNames())
+
 	mainGui.menuConfig = fyne.NewMenuItem("Configuration", func() {
+		err := mainGui.configApp.Start(context.Background())
+		if err != nil {
+			log.Error(err.Error())
+		}
 		mainGui.windowConfig.Show()
 	})
 
@@ -217,7 +279,9 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 			fyne.NewMenuItemSeparator(),
 			mainGui.menuDim,
 			mainGui.menuMute,
+			mainGui.menuScenes,
 			fyne.NewMenuItemSeparator(),
+			mainGui.menuCalibrate,
 			mainGui.menuConfig,
 			fyne.NewMenuItemSeparator(),
 			exit,
@@ -233,7 +297,7 @@ func NewAppWindow(cfg *config.Config, closeFunction func()) (*MainGui, error) {
 
 	// Layouts
 	content := container.NewBorder(
-		nil, //top
+		mainGui.sceneContainer,                                 //top
 		container.NewHBox(mainGui.infoIcon, mainGui.infoLabel), // bot
 		mainGui.fader,           // left
 		mainGui.levelMeter,      // right
@@ -251,32 +315,50 @@ func (g *MainGui) run() {
 	for {
 		select {
 		case v := <-g.masterValueChanged:
-			volume := int(v.Value)
-			if g.masterVolumeBuffer != volume {
-				g.masterVolumeBuffer = volume
-				if g.controllerChannel != nil {
-					g.controllerChannel <- monitorcontroller.RcSetVolume(v.Value)
-					log.Debugf("Send new Volume: %d", int(v.Value))
-				}
+			// the controller ignores unchanged volumes, the fader gets the final value back with EchoFinal
+			if g.controllerChannel != nil {
+				g.controllerChannel <- monitorcontroller.RcSetVolume(v.Value)
+				log.Debugf("Send new Volume: %d", int(v.Value))
 			}
 
 		case v := <-g.buttonPressed:
 			switch v.Button.ID {
-			case SpeakerA,
-				SpeakerB,
-				SpeakerC,
-				SpeakerD,
-				Sub:
-				g.controllerChannel <- monitorcontroller.RcSpeakerSelect{Id: monitorcontroller.SpeakerID(v.Button.ID), State: !v.Button.state}
 			case Mute:
 				g.controllerChannel <- monitorcontroller.RcSetMute(!v.Button.state)
 			case Dim:
 				g.controllerChannel <- monitorcontroller.RcSetDim(!v.Button.state)
+			default:
+				g.controllerChannel <- monitorcontroller.RcSpeakerSelect{Id: monitorcontroller.SpeakerID(v.Button.ID), State: !v.Button.state}
 			}
 		}
 	}
 }
 
+// sceneMenu returns the tray menu of the scenes, selecting a scene recalls it
+func (g *MainGui) sceneMenu(names []string) *fyne.Menu {
+	items := make([]*fyne.MenuItem, 0, len(names)+2)
+	for i, name := range names {
+		items = append(items, fyne.NewMenuItem(name, func() {
+			g.controllerChannel <- monitorcontroller.RcRecallScene(i)
+		}))
+	}
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Store as new Scene", func() {
+			g.controllerChannel <- monitorcontroller.RcStoreScene{Index: len(names)}
+		}),
+	)
+	return fyne.NewMenu("Scenes", items...)
+}
+
+func (g *MainGui) closeConfig() {
+	g.windowConfig.Hide()
+	err := g.configApp.Close()
+	if err != nil {
+		log.Error(err.Error())
+	}
+}
+
 func (g *MainGui) SetLevelStereo(levelL, levelR float64) {
 	g.levelMeter.SetValueStereo(levelL, levelR)
 }
@@ -315,7 +397,11 @@ func (g *MainGui) SetButtonDisabled(id ButtonID, state bool) {
 
 func (g *MainGui) UpdateStatusText(dev *monitorcontroller.DeviceInfo) {
 	if dev.ConnectionState {
-		if dev.DeviceId == 0 {
+		if g.approval != monitorcontroller.ApprovalApproved {
+			g.infoLabel.Text = fmt.Sprintf(INFO_APPROVAL, g.approval)
+			g.infoLabel.Color = theme.Color(theme.ColorNameWarning)
+			g.infoIcon.SetResource(theme.NewWarningThemedResource(theme.WarningIcon()))
+		} else if dev.DeviceId == 0 {
 			g.infoLabel.Text = INFO_NO_DEVICE
 			g.infoLabel.Color = theme.Color(theme.ColorNameDisabled)
 			g.infoIcon.SetResource(theme.NewDisabledResource(theme.WarningIcon()))
@@ -338,6 +424,7 @@ func (g *MainGui) SetControlChannel(controllerChannel chan interface{}) {
 }
 
 func (g *MainGui) HandleDeviceUpdate(dev *monitorcontroller.DeviceInfo) {
+	g.deviceInfo = *dev
 	g.UpdateStatusText(dev)
 
 	if dev.ConnectionState && dev.DeviceId != 0 {
@@ -346,6 +433,9 @@ func (g *MainGui) HandleDeviceUpdate(dev *monitorcontroller.DeviceInfo) {
 		for _, btn := range g.buttons {
 			btn.Enable()
 		}
+		g.sceneSelect.Enable()
+		g.sceneStore.Enable()
+		g.sceneAdd.Enable()
 
 	} else {
 		g.fader.Disable()
@@ -353,12 +443,21 @@ func (g *MainGui) HandleDeviceUpdate(dev *monitorcontroller.DeviceInfo) {
 		for _, btn := range g.buttons {
 			btn.Disable()
 		}
+		g.sceneSelect.Disable()
+		g.sceneStore.Disable()
+		g.sceneAdd.Disable()
 	}
 
 }
 
+func (g *MainGui) HandleApproval(approval monitorcontroller.ApprovalState) {
+	g.approval = approval
+	g.UpdateStatusText(&g.deviceInfo)
+}
+
 // sNew Dim State
 func (g *MainGui) HandleDim(state bool) {
+	g.dim = state
 	g.SetButton(Dim, state)
 	g.menuDim.Checked = state
 	g.menuSystemTray.Refresh()
@@ -366,13 +465,19 @@ func (g *MainGui) HandleDim(state bool) {
 
 // New Mute State
 func (g *MainGui) HandleMute(state bool) {
+	g.mute = state
 	g.SetButton(Mute, state)
 	g.menuMute.Checked = state
 	g.menuSystemTray.Refresh()
 }
 
+func (g *MainGui) EchoPolicy() monitorcontroller.EchoPolicy {
+	return monitorcontroller.EchoPolicy{Mode: monitorcontroller.EchoFinal, Settle: FADER_ECHO_SETTLE}
+}
+
 // Volume -127 .. 0 dB
 func (g *MainGui) HandleVolume(volume int) {
+	g.volume = volume
 	g.SetFader(float64(volume))
 }
 
@@ -381,10 +486,13 @@ func (g *MainGui) HandleMeter(left, right int) {
 	g.SetLevelStereo(float64(left), float64(right))
 }
 
-// Speaker with given ID new selection State
-func (g *MainGui) HandleSpeakerSelect(id monitorcontroller.SpeakerID, state bool) {
-	log.Debugf("Speaker %d: %t", id, state)
-	g.SetButton(ButtonID(id), state)
+// New selection of all speakers
+func (g *MainGui) HandleSpeakerSelection(sel monitorcontroller.Selection) {
+	for id, state := range sel {
+		log.Debugf("Speaker %d: %t", id, state)
+		g.selection[ButtonID(id)] = state
+		g.SetButton(ButtonID(id), state)
+	}
 }
 
 func (g *MainGui) HandleSpeakerName(id monitorcontroller.SpeakerID, name string) {
@@ -394,11 +502,16 @@ func (g *MainGui) HandleSpeakerName(id monitorcontroller.SpeakerID, name string)
 func (g *MainGui) HandleSpeakerUpdate(id monitorcontroller.SpeakerID, spk *monitorcontroller.SpeakerState) {
 	log.Debugf("Speaker Update (%d) %s : sel: %t", id, spk.Name, spk.Selected)
 
+	g.selection[ButtonID(id)] = spk.Selected
 	g.SetButton(ButtonID(id), spk.Selected)
 	g.SetButtonlabel(ButtonID(id), spk.Name)
 	g.SetButtonDisabled(ButtonID(id), spk.Disabled)
 }
 func (g *MainGui) HandleMasterUpdate(master *monitorcontroller.MasterState) {
+	g.volume = master.VolumeDB
+	g.mute = master.Mute
+	g.dim = master.Dim
+
 	g.SetFader(float64(master.VolumeDB))
 	g.SetLevelStereo(float64(master.LevelLeft), float64(master.LevelRight))
 
@@ -407,6 +520,42 @@ func (g *MainGui) HandleMasterUpdate(master *monitorcontroller.MasterState) {
 
 	g.menuMute.Checked = master.Mute
 	g.menuDim.Checked = master.Dim
+	g.menuCalibrate.Checked = master.Calibration
+	g.menuSystemTray.Refresh()
+}
+
+// Scenes stored or changed
+func (g *MainGui) HandleScenes(scenes []monitorcontroller.Scene) {
+	names := make([]string, 0, len(scenes))
+	for _, scene := range scenes {
+		names = append(names, scene.Name)
+	}
+
+	g.sceneSelect.SetOptions(names)
+	if g.sceneSelect.SelectedIndex() < 0 && g.sceneSelect.Selected != "" {
+		g.sceneSelect.ClearSelected()
+	}
+
+	g.menuScenes.ChildMenu = g.sceneMenu(names)
+	g.menuSystemTray.Refresh()
+}
+
+// Result of a command sent by the GUI, rejections are shown as notification
+// and the controls are reset to the state of the controller
+func (g *MainGui) HandleResult(result monitorcontroller.CommandResult) {
+	if result.Status != monitorcontroller.Rejected {
+		return
+	}
+	g.app.SendNotification(fyne.NewNotification(APP_TITLE, result.Reason))
+
+	g.SetFader(float64(g.volume))
+	g.SetButton(Mute, g.mute)
+	g.SetButton(Dim, g.dim)
+	for id, state := range g.selection {
+		g.SetButton(id, state)
+	}
+	g.menuMute.Checked = g.mute
+	g.menuDim.Checked = g.dim
 	g.menuSystemTray.Refresh()
 }
 
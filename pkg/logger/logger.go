@@ -12,8 +12,9 @@ var packageLogLevels = map[string]logrus.Level{
 	"main":   logrus.WarnLevel,
 	"config": logrus.WarnLevel,
 
-	"focusriteclient": logrus.WarnLevel,
-	"fc-audio":        logrus.WarnLevel,
+	"focusriteclient":     logrus.WarnLevel,
+	"fc-audio":            logrus.WarnLevel,
+	"focusrite-simulator": logrus.WarnLevel,
 
 	"focusrite-xml":    logrus.WarnLevel,
 	"focusrite-config": logrus.WarnLevel,
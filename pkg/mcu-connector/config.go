@@ -7,6 +7,7 @@ import (
 
 var (
 	ALL_GOMCU_MUTES         []gomcu.Switch  = []gomcu.Switch{gomcu.Mute1, gomcu.Mute2, gomcu.Mute3, gomcu.Mute4, gomcu.Mute5, gomcu.Mute6, gomcu.Mute7, gomcu.Mute8}
+	ALL_GOMCU_REC           []gomcu.Switch  = []gomcu.Switch{gomcu.Rec1, gomcu.Rec2, gomcu.Rec3, gomcu.Rec4, gomcu.Rec5, gomcu.Rec6, gomcu.Rec7, gomcu.Rec8}
 	ALL_GOMUC_SOLO          []gomcu.Switch  = []gomcu.Switch{gomcu.Solo1, gomcu.Solo2, gomcu.Solo3, gomcu.Solo4, gomcu.Solo5, gomcu.Solo6, gomcu.Solo7, gomcu.Solo8}
 	ALL_GOMCU_FADER_CHANNEL []gomcu.Channel = []gomcu.Channel{gomcu.Channel1, gomcu.Channel2, gomcu.Channel3, gomcu.Channel4, gomcu.Channel5, gomcu.Channel6, gomcu.Channel7, gomcu.Channel8, gomcu.Master}
 )
@@ -21,6 +22,14 @@ type McuConnectorConfig struct {
 	MasterDimSwitch     gomcu.Switch
 	MasterVolumeChannel gomcu.Channel
 
+	// solo and cut of the channels (L, R, C, LFE ...) of the first selected speaker, by channel index
+	ChannelSoloSwitch []gomcu.Switch
+	ChannelCutSwitch  []gomcu.Switch
+
+	// recall of the scenes by scene index, after the store switch a scene switch stores the current setup
+	SceneSwitch      map[int]gomcu.Switch
+	SceneStoreSwitch *gomcu.Switch
+
 	FaderScaleLog bool
 }
 
@@ -55,6 +64,7 @@ func DefaultConfiguration() *McuConnectorConfig {
 		MasterMuteSwitch:    gomcu.Mute1,
 		MasterDimSwitch:     gomcu.Solo1,
 		MasterVolumeChannel: gomcu.Channel1,
+		ChannelSoloSwitch:   ALL_GOMCU_REC,
 		FaderScaleLog:       false,
 	}
 
@@ -1,6 +1,8 @@
 package mcuconnector
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"reflect"
 	"slices"
@@ -19,28 +21,57 @@ var log *logger.CustomLogger = logger.WithPackage("mcu-connector")
 
 const LEVEL_RATE_LIMIT_TIME time.Duration = 100 * time.Millisecond
 
+// how long a rejected command is shown in the time display
+const REJECTED_DISPLAY_TIME time.Duration = 1500 * time.Millisecond
+
+// the motor fader gets its own volume back once it rests, it would fight the hand while moving
+const FADER_ECHO_SETTLE time.Duration = 300 * time.Millisecond
+
+var approvalDisplayText map[monitorcontroller.ApprovalState]string = map[monitorcontroller.ApprovalState]string{
+	monitorcontroller.ApprovalPending:  "Approve App",
+	monitorcontroller.ApprovalApproved: "Monitor Control",
+	monitorcontroller.ApprovalDenied:   "Denied",
+	monitorcontroller.ApprovalRevoked:  "Revoked",
+}
+
 type McuConnector struct {
 	mcu    *mcu.Mcu
 	config *McuConnectorConfig
 
 	controllerChannel chan interface{}
 
-	state *monitorcontroller.ControllerSate
+	state    *monitorcontroller.ControllerSate
+	approval monitorcontroller.ApprovalState
 	//dim           bool
 	//mute          bool
 	faderValueRaw uint16
 	//speakerSelect []bool
 	//speakerName   []string
+	speakerMu sync.Mutex // speakers are added by the concurrent updates of the controller
+
+	sceneMu         sync.Mutex
+	sceneCount      int
+	sceneStoreArmed bool
 
 	mu                 sync.Mutex
 	meterValue         gomcu.MeterLevel
 	meterUpdateRequest bool
+	timeDisplay        string      // shown on the time display, restored after a message
+	messageTimer       *time.Timer // restores the time display after a message
+
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the connector stops, sends to the MCU are dropped from then on
+	wg     sync.WaitGroup
 }
 
 func NewMcuConnector(config *McuConnectorConfig) *McuConnector {
 	m := &McuConnector{
 		config: config,
-		state:  monitorcontroller.NewDefaultState(),
+		done:   make(chan struct{}),
+		state: &monitorcontroller.ControllerSate{
+			Master:  monitorcontroller.NewDefaultState().Master,
+			Speaker: make(map[monitorcontroller.SpeakerID]*monitorcontroller.SpeakerState),
+		},
 		//		speakerSelect: make([]bool, monitorcontroller.SPEAKER_LEN),
 		//		speakerName:   make([]string, monitorcontroller.SPEAKER_LEN),
 	}
@@ -50,13 +81,57 @@ func NewMcuConnector(config *McuConnectorConfig) *McuConnector {
 	if err != nil {
 		return nil
 	}
-
-	go m.run()
-	go m.runSendMeterValues()
 	return m
 }
 
+// Start opens the MIDI connection and handles the MCU until ctx is cancelled or Close is called
+func (mc *McuConnector) Start(ctx context.Context) error {
+	ctx, mc.cancel = context.WithCancel(ctx)
+
+	err := mc.mcu.Start(ctx)
+	if err != nil {
+		close(mc.done)
+		return err
+	}
+
+	mc.wg.Add(3)
+	go func() {
+		defer mc.wg.Done()
+		<-ctx.Done()
+		close(mc.done)
+	}()
+	go mc.run()
+	go mc.runSendMeterValues(ctx)
+	return nil
+}
+
+// send queues a command for the MCU, it's dropped once the connector stopped
+func (mc *McuConnector) send(cmd interface{}) {
+	select {
+	case mc.mcu.ToMcu <- cmd:
+	case <-mc.done:
+	}
+}
+
+// Close closes the MIDI ports and waits for all goroutines to exit
+func (mc *McuConnector) Close() error {
+	if mc.cancel == nil {
+		return nil
+	}
+	mc.mu.Lock()
+	if mc.messageTimer != nil {
+		mc.messageTimer.Stop()
+	}
+	mc.mu.Unlock()
+
+	mc.cancel()
+	err := mc.mcu.Close()
+	mc.wg.Wait()
+	return err
+}
+
 func (mc *McuConnector) run() {
+	defer mc.wg.Done()
 
 	for msg := range mc.mcu.FromMcu {
 		switch f := msg.(type) {
@@ -70,13 +145,28 @@ func (mc *McuConnector) run() {
 		case mcu.SelectMessage:
 			if mc.config.MasterVolumeChannel == f.FaderNumber {
 				log.Debugf("Channel Select Button detected: %d", f.FaderNumber)
-				mc.mcu.ToMcu <- mcu.FaderCommand{Fader: gomcu.Channel(f.FaderNumber), Value: mc.faderValueRaw}
+				mc.send(mcu.FaderCommand{Fader: gomcu.Channel(f.FaderNumber), Value: mc.faderValueRaw})
 				continue
 			}
 
+		case mcu.VPotChangeMessage:
+			if mc.isTrimVPot(f.FaderNumber) {
+				mc.trimSelectedSpeaker(f.ChangeAmount)
+			}
+
+		case mcu.VPotButtonMessage:
+			if mc.isTrimVPot(f.FaderNumber) {
+				mc.controllerChannel <- monitorcontroller.RcSetCalibration(!mc.state.Master.Calibration)
+			}
+
 		case mcu.KeyMessage:
 			log.Debugf("Key Msg: %s (%d)", f.HotkeyName, f.KeyNumber)
 
+			if f.KeyNumber >= gomcu.V1 && f.KeyNumber <= gomcu.V8 && mc.isTrimVPot(byte(f.KeyNumber-gomcu.V1)) {
+				mc.controllerChannel <- monitorcontroller.RcSetCalibration(!mc.state.Master.Calibration)
+				continue
+			}
+
 			if mc.config.MasterMuteSwitch == f.KeyNumber {
 				mc.controllerChannel <- monitorcontroller.RcSetMute(!mc.state.Master.Mute)
 				continue
@@ -87,10 +177,18 @@ func (mc *McuConnector) run() {
 				continue
 			}
 
+			if mc.handleChannelSwitch(f.KeyNumber) {
+				continue
+			}
+
+			if mc.handleSceneSwitch(f.KeyNumber) {
+				continue
+			}
+
 			for k, spk := range mc.config.SpeakerSelect {
 				if spk == f.KeyNumber {
 					log.Debugf("Speaker Select Button %s detected. SpeakerId %d ", f.HotkeyName, k)
-					mc.controllerChannel <- monitorcontroller.RcSpeakerSelect{Id: k, State: !mc.state.Speaker[k].Selected}
+					mc.controllerChannel <- monitorcontroller.RcSpeakerSelect{Id: k, State: !mc.speaker(k).Selected}
 					continue
 				}
 			}
@@ -135,16 +233,135 @@ func (mc *McuConnector) run() {
 	}
 }
 
-func (mc *McuConnector) runSendMeterValues() {
+func (mc *McuConnector) runSendMeterValues(ctx context.Context) {
+	defer mc.wg.Done()
+
+	t := time.NewTicker(LEVEL_RATE_LIMIT_TIME)
+	defer t.Stop()
+
 	for {
-		time.Sleep(LEVEL_RATE_LIMIT_TIME)
-		mc.mu.Lock()
-		if mc.meterUpdateRequest {
-			mc.mcu.ToMcu <- mcu.MeterCommand{Channel: mc.config.MasterVolumeChannel, Value: mc.meterValue}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			mc.mu.Lock()
+			update, value := mc.meterUpdateRequest, mc.meterValue
 			mc.meterUpdateRequest = false
+			mc.mu.Unlock()
+
+			if update {
+				mc.send(mcu.MeterCommand{Channel: mc.config.MasterVolumeChannel, Value: value})
+			}
+		}
+	}
+}
+
+// the V-Pot of the volume channel trims the selected speakers, pushing it toggles the calibration
+func (mc *McuConnector) isTrimVPot(channel byte) bool {
+	return gomcu.Channel(channel) == mc.config.MasterVolumeChannel
+}
+
+func (mc *McuConnector) trimSelectedSpeaker(amount int) {
+	for _, id := range mc.speakerIDs() {
+		spk := mc.speaker(id)
+		if spk.Selected && !spk.Disabled {
+			mc.controllerChannel <- monitorcontroller.RcSetSpeakerTrim{Id: id, TrimDB: spk.TrimDB + amount}
+		}
+	}
+}
+
+// handleChannelSwitch toggles solo or cut of a channel of the first selected speaker
+func (mc *McuConnector) handleChannelSwitch(key gomcu.Switch) bool {
+	solo := slices.Index(mc.config.ChannelSoloSwitch, key)
+	cut := slices.Index(mc.config.ChannelCutSwitch, key)
+	if solo < 0 && cut < 0 {
+		return false
+	}
+
+	id, spk, ok := mc.channelSpeaker()
+	if !ok {
+		return true
+	}
+	if solo >= 0 && solo < len(spk.Channels) {
+		mc.controllerChannel <- monitorcontroller.RcSetChannelSolo{Id: id, Channel: solo, State: !spk.Channels[solo].Solo}
+	}
+	if cut >= 0 && cut < len(spk.Channels) {
+		mc.controllerChannel <- monitorcontroller.RcSetChannelCut{Id: id, Channel: cut, State: !spk.Channels[cut].Cut}
+	}
+	return true
+}
+
+// handleSceneSwitch recalls a scene, the store switch arms storing into the next scene pressed
+func (mc *McuConnector) handleSceneSwitch(key gomcu.Switch) bool {
+	mc.sceneMu.Lock()
+	defer mc.sceneMu.Unlock()
+
+	store := mc.config.SceneStoreSwitch
+	if store != nil && *store == key {
+		mc.sceneStoreArmed = !mc.sceneStoreArmed
+		mc.updateSceneStoreLed()
+		return true
+	}
+
+	index := -1
+	for i, sw := range mc.config.SceneSwitch {
+		if sw == key {
+			index = i
 		}
-		mc.mu.Unlock()
 	}
+	if index < 0 {
+		return false
+	}
+	if mc.sceneStoreArmed {
+		mc.sceneStoreArmed = false
+		mc.updateSceneStoreLed()
+		// switches after the last scene add a new one
+		mc.controllerChannel <- monitorcontroller.RcStoreScene{Index: min(index, mc.sceneCount)}
+		return true
+	}
+	mc.controllerChannel <- monitorcontroller.RcRecallScene(index)
+	return true
+}
+
+func (mc *McuConnector) updateSceneStoreLed() {
+	if mc.config.SceneStoreSwitch == nil {
+		return
+	}
+	state := gomcu.StateOff
+	if mc.sceneStoreArmed {
+		state = gomcu.StateBlink
+	}
+	mc.setLed(*mc.config.SceneStoreSwitch, state)
+}
+
+// channelSpeaker returns the first selected speaker, its channels are controlled by the channel switches
+func (mc *McuConnector) channelSpeaker() (monitorcontroller.SpeakerID, *monitorcontroller.SpeakerState, bool) {
+	for _, id := range mc.speakerIDs() {
+		spk := mc.speaker(id)
+		if spk.Selected && !spk.Disabled && spk.Type == monitorcontroller.Speaker {
+			return id, spk, true
+		}
+	}
+	return 0, nil, false
+}
+
+// speaker returns the local state of a speaker and adds it if the controller did not report it yet
+func (mc *McuConnector) speaker(id monitorcontroller.SpeakerID) *monitorcontroller.SpeakerState {
+	mc.speakerMu.Lock()
+	defer mc.speakerMu.Unlock()
+
+	spk, ok := mc.state.Speaker[id]
+	if !ok {
+		spk = &monitorcontroller.SpeakerState{}
+		mc.state.Speaker[id] = spk
+	}
+	return spk
+}
+
+func (mc *McuConnector) speakerIDs() []monitorcontroller.SpeakerID {
+	mc.speakerMu.Lock()
+	defer mc.speakerMu.Unlock()
+	return mc.state.SpeakerIDs()
 }
 
 func (mc *McuConnector) SetControlChannel(controllerChannel chan interface{}) {
@@ -160,6 +377,10 @@ func (mc *McuConnector) HandleMute(mute bool) {
 	mc.SetMute(mute)
 }
 
+func (mc *McuConnector) EchoPolicy() monitorcontroller.EchoPolicy {
+	return monitorcontroller.EchoPolicy{Mode: monitorcontroller.EchoFinal, Settle: FADER_ECHO_SETTLE}
+}
+
 func (mc *McuConnector) HandleVolume(db int) {
 
 	if mc.config.FaderScaleLog {
@@ -175,9 +396,11 @@ func (mc *McuConnector) HandleMeter(left, right int) {
 	mc.updateAllMeterFader(level)
 }
 
-func (mc *McuConnector) HandleSpeakerSelect(id monitorcontroller.SpeakerID, sel bool) {
-	mc.state.Speaker[id].Selected = sel
-	mc.updateMcuLed(mc.config.SpeakerSelect[id], sel)
+func (mc *McuConnector) HandleSpeakerSelection(sel monitorcontroller.Selection) {
+	for id, selected := range sel {
+		mc.SetSpeakerSelect(id, selected)
+	}
+	mc.updateChannelLeds()
 }
 
 func (mc *McuConnector) HandleSpeakerName(id monitorcontroller.SpeakerID, name string) {
@@ -185,13 +408,20 @@ func (mc *McuConnector) HandleSpeakerName(id monitorcontroller.SpeakerID, name s
 }
 
 func (mc *McuConnector) HandleSpeakerUpdate(id monitorcontroller.SpeakerID, spk *monitorcontroller.SpeakerState) {
+	local := mc.speaker(id)
+	local.Type = spk.Type
+	local.Disabled = spk.Disabled
+
 	mc.SetSpeakerSelect(id, spk.Selected)
 	mc.SetSpeakerName(id, spk.Name)
+	mc.SetSpeakerTrim(id, spk.TrimDB)
+	mc.SetSpeakerChannels(id, spk.Channels)
 }
 
 func (mc *McuConnector) HandleMasterUpdate(master *monitorcontroller.MasterState) {
 	mc.SetMute(master.Mute)
 	mc.SetDim(master.Dim)
+	mc.SetCalibration(master.Calibration)
 
 	if mc.config.FaderScaleLog {
 		mc.SetVolume(DBToFaderLog(float64(master.VolumeDB)))
@@ -201,10 +431,65 @@ func (mc *McuConnector) HandleMasterUpdate(master *monitorcontroller.MasterState
 
 }
 
+// the scenes are only counted, switches after the last scene store new ones
+func (mc *McuConnector) HandleScenes(scenes []monitorcontroller.Scene) {
+	mc.sceneMu.Lock()
+	defer mc.sceneMu.Unlock()
+	mc.sceneCount = len(scenes)
+}
+
 func (mc *McuConnector) HandleDeviceUpdate(dev *monitorcontroller.DeviceInfo) {
 	mc.initMcu()
 }
 
+// show the approval state on the time display, there is no other place for text
+func (mc *McuConnector) HandleApproval(approval monitorcontroller.ApprovalState) {
+	mc.approval = approval
+	if mc.state.Master.Calibration {
+		return
+	}
+	text, ok := approvalDisplayText[approval]
+	if !ok {
+		text = approval.String()
+	}
+	mc.setTimeDisplay(text)
+}
+
+// Result of a command sent by the MCU, rejections are shown in the time display for a moment
+func (mc *McuConnector) HandleResult(result monitorcontroller.CommandResult) {
+	if result.Status != monitorcontroller.Rejected {
+		return
+	}
+	log.Infof("Command rejected: %s", result.Reason)
+	mc.showMessage("Rejected", REJECTED_DISPLAY_TIME)
+}
+
+// setTimeDisplay shows the text on the time display, it's restored after a message
+func (mc *McuConnector) setTimeDisplay(text string) {
+	mc.mu.Lock()
+	mc.timeDisplay = text
+	mc.mu.Unlock()
+	mc.send(mcu.TimeDisplayCommand{Text: text})
+}
+
+// showMessage shows text on the time display until the last text set is restored after d
+func (mc *McuConnector) showMessage(text string, d time.Duration) {
+	mc.mu.Lock()
+	if mc.messageTimer != nil {
+		mc.messageTimer.Stop()
+	}
+	mc.messageTimer = time.AfterFunc(d, mc.restoreTimeDisplay)
+	mc.mu.Unlock()
+	mc.send(mcu.TimeDisplayCommand{Text: text})
+}
+
+func (mc *McuConnector) restoreTimeDisplay() {
+	mc.mu.Lock()
+	text := mc.timeDisplay
+	mc.mu.Unlock()
+	mc.send(mcu.TimeDisplayCommand{Text: text})
+}
+
 //Setter
 
 func (mc *McuConnector) SetMute(mute bool) {
@@ -223,12 +508,44 @@ func (mc *McuConnector) SetVolume(vol uint16) {
 }
 
 func (mc *McuConnector) SetSpeakerSelect(id monitorcontroller.SpeakerID, sel bool) {
-	mc.state.Speaker[id].Selected = sel
+	mc.speaker(id).Selected = sel
 	mc.updateMcuLed(mc.config.SpeakerSelect[id], sel)
 }
 
+// the trim is shown on the time display while calibrating
+func (mc *McuConnector) SetSpeakerTrim(id monitorcontroller.SpeakerID, trim int) {
+	spk := mc.speaker(id)
+	changed := spk.TrimDB != trim
+	spk.TrimDB = trim
+	if changed && mc.state.Master.Calibration {
+		mc.setTimeDisplay(fmt.Sprintf("Trim %+ddB", trim))
+	}
+}
+
+func (mc *McuConnector) SetSpeakerChannels(id monitorcontroller.SpeakerID, channels []*monitorcontroller.ChannelState) {
+	spk := mc.speaker(id)
+	spk.Channels = make([]*monitorcontroller.ChannelState, 0, len(channels))
+	for _, ch := range channels {
+		c := *ch
+		spk.Channels = append(spk.Channels, &c)
+	}
+	mc.updateChannelLeds()
+}
+
+func (mc *McuConnector) SetCalibration(calibration bool) {
+	if mc.state.Master.Calibration == calibration {
+		return
+	}
+	mc.state.Master.Calibration = calibration
+	if calibration {
+		mc.setTimeDisplay("Calibrate")
+	} else {
+		mc.HandleApproval(mc.approval)
+	}
+}
+
 func (mc *McuConnector) SetSpeakerName(id monitorcontroller.SpeakerID, name string) {
-	mc.state.Speaker[id].Name = name
+	mc.speaker(id).Name = name
 }
 
 func (mc *McuConnector) initMcu() {
@@ -236,10 +553,30 @@ func (mc *McuConnector) initMcu() {
 	mc.updateMcuLed(mc.config.MasterDimSwitch, mc.state.Master.Dim)
 
 	for k, speaker := range mc.config.SpeakerSelect {
-		mc.updateMcuLed(speaker, mc.state.Speaker[k].Selected)
+		mc.updateMcuLed(speaker, mc.speaker(k).Selected)
 	}
 
 	mc.updateMcuFader(mc.config.MasterVolumeChannel, mc.faderValueRaw)
+	mc.updateChannelLeds()
+
+	mc.sceneMu.Lock()
+	mc.updateSceneStoreLed()
+	mc.sceneMu.Unlock()
+}
+
+// updateChannelLeds shows solo and cut of the first selected speaker, LEDs of missing channels are off
+func (mc *McuConnector) updateChannelLeds() {
+	var channels []*monitorcontroller.ChannelState
+	if _, spk, ok := mc.channelSpeaker(); ok {
+		channels = spk.Channels
+	}
+
+	for i, sw := range mc.config.ChannelSoloSwitch {
+		mc.updateMcuLed(sw, i < len(channels) && channels[i].Solo)
+	}
+	for i, sw := range mc.config.ChannelCutSwitch {
+		mc.updateMcuLed(sw, i < len(channels) && channels[i].Cut)
+	}
 }
 
 // MCU Led & Fader Hacks
@@ -248,7 +585,7 @@ func (mc *McuConnector) setLedBool(sw gomcu.Switch, state bool) {
 }
 
 func (mc *McuConnector) setLed(sw gomcu.Switch, state gomcu.State) {
-	mc.mcu.ToMcu <- mcu.LedCommand{Led: sw, State: state}
+	mc.send(mcu.LedCommand{Led: sw, State: state})
 }
 
 func (c *McuConnector) updateMcuLed(sw gomcu.Switch, state bool) {
@@ -256,8 +593,8 @@ func (c *McuConnector) updateMcuLed(sw gomcu.Switch, state bool) {
 }
 
 func (mc *McuConnector) updateMcuFader(channel gomcu.Channel, value uint16) {
-	mc.mcu.ToMcu <- mcu.FaderSelectCommand{Channel: channel, ChnnalValue: value}
-	mc.mcu.ToMcu <- mcu.FaderCommand{Fader: channel, Value: value}
+	mc.send(mcu.FaderSelectCommand{Channel: channel, ChnnalValue: value})
+	mc.send(mcu.FaderCommand{Fader: channel, Value: value})
 }
 
 func (mc *McuConnector) updateAllMeterFader(level gomcu.MeterLevel) {
@@ -1,7 +1,9 @@
 package mcu
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/sebastianrau/focusrite-mackie-control/pkg/logger"
@@ -31,9 +33,13 @@ type Mcu struct {
 	displayStringUpper []byte
 	displayStringLower []byte
 	selectedChannel    gomcu.Channel
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
-// Initialize the MCU runloop
+// Initialize the MCU, the runloop is started with Start
 func InitMcu(cfg *Configuration) (*Mcu, error) {
 
 	if cfg.MidiInputPort == "" {
@@ -61,10 +67,31 @@ func InitMcu(cfg *Configuration) (*Mcu, error) {
 	m.DecodeChannelSelect(uint8(gomcu.Channel1))
 
 	m.connection <- 0
-	go m.run()
 	return &m, nil
 }
 
+// Start the MCU runloop until ctx is cancelled or Close is called
+func (m *Mcu) Start(ctx context.Context) error {
+	if m.ctx != nil {
+		return fmt.Errorf("mcu already started")
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	m.wg.Add(1)
+	go m.run()
+	return nil
+}
+
+// Close stops the runloop, closes the MIDI ports and FromMcu
+func (m *Mcu) Close() error {
+	if m.ctx == nil {
+		return fmt.Errorf("mcu not started")
+	}
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
+
 // connects to the MCU, called from runloop
 func (m *Mcu) connect() {
 	var err error
@@ -156,7 +183,12 @@ func (m *Mcu) retryConnect() {
 	if m.connectRetry != nil {
 		m.connectRetry.Stop()
 	}
-	m.connectRetry = time.AfterFunc(3*time.Second, func() { m.connection <- 0 })
+	m.connectRetry = time.AfterFunc(3*time.Second, func() {
+		select {
+		case m.connection <- 0:
+		default:
+		}
+	})
 }
 
 // check if midi connection is still open,
@@ -295,11 +327,22 @@ func (m *Mcu) DecodeButtons(k uint8, v uint8) {
 
 // run the MCU
 func (m *Mcu) run() {
+	defer m.wg.Done()
+	defer close(m.FromMcu)
 	defer m.disconnect()
+	defer func() {
+		if m.connectRetry != nil {
+			m.connectRetry.Stop()
+		}
+	}()
+
 	for {
 		var err error
 
 		select {
+		case <-m.ctx.Done():
+			return
+
 		case state := <-m.connection:
 			if state == 0 {
 				m.FromMcu <- ConnectionMessage{Connection: false}
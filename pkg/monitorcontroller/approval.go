@@ -0,0 +1,22 @@
+package monitorcontroller
+
+// ApprovalState of this app in Focusrite Control
+type ApprovalState int
+
+const (
+	ApprovalPending ApprovalState = iota // connected, waiting for the user to approve the app
+	ApprovalApproved
+	ApprovalDenied
+	ApprovalRevoked // approval was withdrawn while connected
+)
+
+var ApprovalStateName map[ApprovalState]string = map[ApprovalState]string{
+	ApprovalPending:  "Approval pending",
+	ApprovalApproved: "Approved",
+	ApprovalDenied:   "Approval denied",
+	ApprovalRevoked:  "Approval revoked",
+}
+
+func (a ApprovalState) String() string {
+	return ApprovalStateName[a]
+}
@@ -7,7 +7,7 @@ type AudioDevice interface {
 	HandleMute(bool)                     // New Mute State
 	HandleVolume(int)                    // Volume -127 .. 0 dB
 	HandleMeter(int)                     // Meter Value in DB
-	HandleSpeakerSelect(SpeakerID, bool) // Speaker with given ID new selection State
+	HandleSpeakerSelection(Selection)    // New selection of all speakers
 	HandleSpeakerName(SpeakerID, string) // Speaker with given ID new Name Update
 	HandleSpeakerUpdate(SpeakerID, *SpeakerState)
 	HandleMasterUpdate(*MasterState)
@@ -17,17 +17,36 @@ type AdUpdateRequest struct{}
 type AdSetMute bool
 type AdSetDim bool
 type AdSetVolume int
+type AdSetApproval ApprovalState
 
 type AdSetLevel struct {
 	Left  int
 	Right int
 }
 
+type AdSetSpeakerTrim struct {
+	Id     SpeakerID
+	TrimDB int
+}
+
+// AdSetSpeakerChannels reports the channel names of a speaker set
+type AdSetSpeakerChannels struct {
+	Id    SpeakerID
+	Names []string
+}
+
 type AdSpeakerSelect struct {
 	Id    SpeakerID
 	State bool
 }
 
+// AdSelectSpeakers changes several speakers at once, e.g. a speaker set
+type AdSelectSpeakers Selection
+
+func (a AdSelectSpeakers) requests() []speakerRequest {
+	return Selection(a).requests()
+}
+
 type AdSetSpeakerName struct {
 	Id   SpeakerID
 	Name string
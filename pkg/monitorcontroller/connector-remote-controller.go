@@ -1,5 +1,7 @@
 package monitorcontroller
 
+import "time"
+
 type RemoteController interface {
 	SetControlChannel(controllerChannel chan interface{}) //sets the Channel To Controller for remote control
 
@@ -7,12 +9,53 @@ type RemoteController interface {
 	HandleMute(bool)                              // New Mute State
 	HandleVolume(int)                             // Volume -127 .. 0 dB
 	HandleMeter(int, int)                         // Meter Value in DB
-	HandleSpeakerSelect(SpeakerID, bool)          // Speaker with given ID new Selection State
+	HandleSpeakerSelection(Selection)             // New selection of all speakers, sent once per change
 	HandleSpeakerName(SpeakerID, string)          // Speaker with given ID new Name Update
-	HandleSpeakerUpdate(SpeakerID, *SpeakerState) // Send Speaker Update
+	HandleSpeakerUpdate(SpeakerID, *SpeakerState) // Send Speaker Update, incl. channel solo and cut
 	HandleMasterUpdate(*MasterState)              // Send Master Update
 	HandleDeviceUpdate(*DeviceInfo)               //Device Arrived / Connected etc.
+	HandleApproval(ApprovalState)                 // App approval in Focusrite Control changed
+	HandleScenes([]Scene)                         // Scenes stored or changed, by index
+
+}
+
+// RemoteID identifies a registered remote controller, NoRemote is the audio device or the controller itself
+type RemoteID int
+
+const NoRemote RemoteID = 0
+
+// RcCommand is a command tagged with the remote it came from.
+// Remotes send plain Rc* commands or an RcCommand with a correlation ID of their choice, the controller sets the origin.
+type RcCommand struct {
+	Origin        RemoteID
+	CorrelationID uint64
+	Command       interface{}
+}
+
+type EchoMode int
+
+const (
+	EchoAlways      EchoMode = iota // the origin gets its own values like every other remote
+	EchoNotToOrigin                 // the origin doesn't get the values it set itself
+	EchoFinal                       // the origin gets the value it set once it didn't change for the settle time
+)
 
+// EchoPolicy decides how a remote gets the fader and slider values it set itself, values changed by the controller,
+// e.g. by the volume limits, are always sent back
+type EchoPolicy struct {
+	Mode   EchoMode
+	Settle time.Duration
+}
+
+// EchoPolicyRemote is implemented by remotes with another policy than EchoAlways, e.g. for motor faders
+type EchoPolicyRemote interface {
+	EchoPolicy() EchoPolicy
+}
+
+// HeartbeatRemote is implemented by remotes which can go away, e.g. network clients.
+// A remote which returns an error or doesn't answer until the next heartbeat is unregistered.
+type HeartbeatRemote interface {
+	Heartbeat() error
 }
 
 type RcUpdateRequest bool
@@ -20,6 +63,35 @@ type RcSetMute bool
 type RcSetDim bool
 type RcSetVolume int
 
+type RcSetCalibration bool
+
+type RcSetSpeakerTrim struct {
+	Id     SpeakerID
+	TrimDB int
+}
+
+type RcSetChannelSolo struct {
+	Id      SpeakerID
+	Channel int
+	State   bool
+}
+
+type RcSetChannelCut struct {
+	Id      SpeakerID
+	Channel int
+	State   bool
+}
+
+// RcRecallScene recalls the scene with the given index
+type RcRecallScene int
+
+// RcStoreScene stores the current setup into the scene with the given index, the index after the last scene adds a new one.
+// An empty name keeps the name of the scene.
+type RcStoreScene struct {
+	Index int
+	Name  string
+}
+
 type RcSpeakerSelect struct {
 	Id    SpeakerID
 	State bool
@@ -1,37 +1,76 @@
 package monitorcontroller
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/sebastianrau/focusrite-mackie-control/pkg/logger"
 )
 
 var log *logger.CustomLogger = logger.WithPackage("monitor-controller")
 
+const REMOTE_HEARTBEAT_INTERVAL time.Duration = 5 * time.Second
+
+var remoteHeartbeatInterval time.Duration = REMOTE_HEARTBEAT_INTERVAL // shortened by the tests
+
 type Controller struct {
-	state *ControllerSate
+	state    *ControllerSate
+	approval ApprovalState
+	device   DeviceInfo
 
 	fromAudioInterface chan interface{}
 	audioDevice        AudioDevice
 
 	fromRemoteController chan interface{}
-	remoteController     []RemoteController
+	registerRemote       chan *remoteQueue
+	unregisterRemote     chan RemoteID
+	remotes              map[RemoteID]*remoteQueue // only used by run, every remote gets its updates in order
+	lastRemoteID         atomic.Int32
+	resultCount          uint64
+	heartbeatInterval    time.Duration
+
+	snapshotRequest chan chan *ControllerSate
+	subscribe       chan chan *ControllerSate
+	subscribers     []chan *ControllerSate
+	published       *ControllerSate
 }
 
-// NewMcuState creates a new McuState
+// NewController starts a controller with a copy of config, the controller is the only owner of its state.
+// Use Snapshot and Changes to read it.
 func NewController(audioDevice AudioDevice, config *ControllerSate) *Controller {
 	c := &Controller{
-		state: config,
+		state: config.Copy(),
 
 		fromAudioInterface: make(chan interface{}, 100),
 		audioDevice:        audioDevice,
 
 		fromRemoteController: make(chan interface{}, 100),
-		remoteController:     make([]RemoteController, 0),
+		registerRemote:       make(chan *remoteQueue),
+		unregisterRemote:     make(chan RemoteID),
+		remotes:              make(map[RemoteID]*remoteQueue),
+		heartbeatInterval:    remoteHeartbeatInterval,
+
+		snapshotRequest: make(chan chan *ControllerSate),
+		subscribe:       make(chan chan *ControllerSate),
+		subscribers:     make([]chan *ControllerSate, 0),
 	}
 
 	if c.audioDevice == nil {
 		return nil
 	}
 
+	// configs from older versions have no calibration level yet
+	if c.state.Master.CalibrationLevelDB == 0 {
+		c.state.Master.CalibrationLevelDB = DEFAULT_CALIBRATION_LEVEL_DB
+	}
+	// same for the speaker order
+	c.state.SpeakerOrder = c.state.SpeakerIDs()
+	// and the volume limits
+	if c.state.Limits.Startup == "" {
+		c.state.Limits = DefaultVolumeLimits()
+	}
+	c.state.applyStartupPolicy()
+
 	c.audioDevice.SetControlChannel(c.fromAudioInterface)
 
 	go c.run()
@@ -39,15 +78,19 @@ func NewController(audioDevice AudioDevice, config *ControllerSate) *Controller
 }
 
 func (c *Controller) run() {
+	heartbeat := time.NewTicker(c.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.publish()
 	for {
 		select {
 
 		case remote := <-c.fromAudioInterface:
 			switch r := remote.(type) {
 			case AdUpdateRequest:
-				c.audioDevice.HandleMasterUpdate(c.state.Master)
-				for spkId, spk := range c.state.Speaker {
-					c.audioDevice.HandleSpeakerUpdate(spkId, spk)
+				c.audioDevice.HandleMasterUpdate(copyMaster(c.state.Master))
+				for _, spkId := range c.state.SpeakerIDs() {
+					c.audioDevice.HandleSpeakerUpdate(spkId, copySpeaker(c.state.Speaker[spkId]))
 				}
 			case AdSetMute:
 				log.Debugf("setting mute: %t", bool(r))
@@ -56,107 +99,287 @@ func (c *Controller) run() {
 				log.Debugf("setting dim: %t", bool(r))
 				c.setDim(bool(r))
 			case AdSetVolume:
-				c.setMasterVolumeDB(int(r))
+				c.setMasterVolumeDB(int(r), false, NoRemote)
 			case AdSetSpeakerName:
 				c.setSpeakerName(r.Id, r.Name)
 			case AdSpeakerSelect:
-				c.setSpeakerSelected(r.Id, r.State)
+				c.selectSpeakers(speakerRequest{id: r.Id, state: r.State})
+			case AdSelectSpeakers:
+				c.selectSpeakers(r.requests()...)
+			case AdSetSpeakerTrim:
+				c.setSpeakerTrim(r.Id, r.TrimDB)
+			case AdSetSpeakerChannels:
+				c.setSpeakerChannels(r.Id, r.Names)
 			case AdSetLevel:
 				c.setMasterLevel(r.Left, r.Right)
+				continue // meter levels are no change of the state
 			case AdSetDeviceStatus:
 				c.fireDeviceUpdate(r)
+			case AdSetApproval:
+				c.setApproval(ApprovalState(r))
+
+			}
 
+		case q := <-c.registerRemote:
+			c.register(q)
+
+		case id := <-c.unregisterRemote:
+			c.unregister(id)
+			continue
+
+		case <-heartbeat.C:
+			c.checkRemotes()
+			continue
+
+		case reply := <-c.snapshotRequest:
+			reply <- c.state.Copy()
+			continue
+
+		case ch := <-c.subscribe:
+			c.subscribers = append(c.subscribers, ch)
+			if c.published != nil {
+				ch <- c.published.Copy()
 			}
+			continue
 
 		case remote := <-c.fromRemoteController:
-			switch r := remote.(type) {
-			case RcUpdateRequest:
-				c.fireMasterUpdate(c.state.Master)
-				for spkId := range c.state.Speaker {
-					c.fireSpeakerUpdate(spkId)
-				}
-			case RcSetMute:
-				c.setMute(bool(r))
-			case RcSetDim:
-				c.setDim(bool(r))
-			case RcSetVolume:
-				c.setMasterVolumeDB(int(r))
-			case RcSpeakerSelect:
-				c.setSpeakerSelected(r.Id, r.State)
+			cmd, ok := remote.(RcCommand)
+			if !ok {
+				cmd = RcCommand{Origin: NoRemote, Command: remote}
 			}
+			c.fireResult(cmd, c.handleRemote(cmd))
 		}
 
+		c.publish()
+	}
+}
+
+// handleRemote applies a remote command, commands which can't be applied are rejected before anything changes
+func (c *Controller) handleRemote(cmd RcCommand) CommandResult {
+	if reason := c.rejectReason(cmd.Command); reason != "" {
+		return rejected("%s", reason)
 	}
+
+	switch r := cmd.Command.(type) {
+	case RcUpdateRequest:
+		c.fireMasterUpdate(c.state.Master)
+		for _, spkId := range c.state.SpeakerIDs() {
+			c.fireSpeakerUpdate(spkId)
+		}
+	case RcSetMute:
+		c.setMute(bool(r))
+	case RcSetDim:
+		c.setDim(bool(r))
+	case RcSetVolume:
+		if vol := c.setMasterVolumeDB(int(r), true, cmd.Origin); vol != int(r) {
+			return clamped(vol)
+		}
+	case RcSpeakerSelect:
+		c.selectSpeakers(speakerRequest{id: r.Id, state: r.State})
+		if c.state.Speaker[r.Id].Selected != r.State {
+			return rejected("speaker %s is kept by the rule of its group", c.state.Speaker[r.Id].Name)
+		}
+	case RcSetSpeakerTrim:
+		if trim := c.setSpeakerTrim(r.Id, r.TrimDB); trim != r.TrimDB {
+			return clamped(trim)
+		}
+	case RcSetCalibration:
+		c.setCalibration(bool(r))
+	case RcSetChannelSolo:
+		c.setChannelSolo(r.Id, r.Channel, r.State)
+	case RcSetChannelCut:
+		c.setChannelCut(r.Id, r.Channel, r.State)
+	case RcRecallScene:
+		c.recallScene(int(r))
+	case RcStoreScene:
+		c.storeScene(r.Index, r.Name)
+	default:
+		return rejected("unknown command %T", r)
+	}
+	return accepted()
 }
 
 // Remote Controls
 func (c *Controller) RegisterRemoteController(r RemoteController) *Controller {
-	c.remoteController = append(c.remoteController, r)
-	r.SetControlChannel(c.fromRemoteController)
-	c.fireAllUpdate()
+	c.Register(r)
 	return c
 }
 
-func (c *Controller) fireDim() {
-	for _, rc := range c.remoteController {
-		go rc.HandleDim(c.state.Master.Dim)
-	}
+// Register adds a remote and returns its ID, its commands are tagged with it as origin
+func (c *Controller) Register(r RemoteController) RemoteID {
+	id := RemoteID(c.lastRemoteID.Add(1))
+	q := newRemoteQueue(id, r)
+	// the run loop knows the remote before its first command, so the result and echo reach it
+	c.registerRemote <- q
+
+	commands := make(chan interface{}, 100)
+	go c.forward(q, commands)
+	r.SetControlChannel(commands)
+	return id
 }
-func (c *Controller) fireMute() {
-	for _, rc := range c.remoteController {
-		go rc.HandleMute(c.state.Master.Mute)
+
+// Unregister removes a remote, it gets no more updates and its further commands are dropped
+func (c *Controller) Unregister(id RemoteID) {
+	c.unregisterRemote <- id
+}
+
+// forward tags the commands of a remote with its ID, a correlation ID set by the remote is kept.
+// Once the remote is unregistered its commands are drained and dropped, so a remote still sending never blocks.
+func (c *Controller) forward(q *remoteQueue, commands chan interface{}) {
+	for {
+		select {
+		case <-q.done:
+			for range commands {
+			}
+			return
+		case command := <-commands:
+			select {
+			case <-q.done:
+				continue // unregistered meanwhile
+			default:
+			}
+			cmd, ok := command.(RcCommand)
+			if !ok {
+				cmd = RcCommand{Command: command}
+			}
+			cmd.Origin = q.id
+			c.fromRemoteController <- cmd
+		}
 	}
 }
-func (c *Controller) fireVolume() {
-	for _, rc := range c.remoteController {
-		go rc.HandleVolume(c.state.Master.VolumeDB)
+
+// push queues an update for every remote
+func (c *Controller) push(key updateKey, deliver func(RemoteController)) {
+	c.pushFrom(NoRemote, key, deliver)
+}
+
+// pushFrom queues an update set by a command of origin, the echo policy of origin applies
+func (c *Controller) pushFrom(origin RemoteID, key updateKey, deliver func(RemoteController)) {
+	for _, q := range c.remotes {
+		q.push(remoteUpdate{key: key, origin: origin, deliver: deliver})
 	}
 }
+
+func (c *Controller) fireDim() {
+	dim := c.state.Master.Dim
+	c.push(updateKey{kind: updateDim}, func(rc RemoteController) { rc.HandleDim(dim) })
+}
+func (c *Controller) fireMute() {
+	mute := c.state.Master.Mute
+	c.push(updateKey{kind: updateMute}, func(rc RemoteController) { rc.HandleMute(mute) })
+}
+func (c *Controller) fireVolume(origin RemoteID) {
+	vol := c.state.Master.VolumeDB
+	c.pushFrom(origin, updateKey{kind: updateVolume}, func(rc RemoteController) { rc.HandleVolume(vol) })
+}
 func (c *Controller) fireLevel() {
-	for _, rc := range c.remoteController {
-		go rc.HandleMeter(c.state.Master.LevelLeft, c.state.Master.LevelRight)
-	}
+	left, right := c.state.Master.LevelLeft, c.state.Master.LevelRight
+	c.push(updateKey{kind: updateMeter}, func(rc RemoteController) { rc.HandleMeter(left, right) })
 }
-func (c *Controller) fireSpeakerSelect(id SpeakerID) {
-	for _, rc := range c.remoteController {
-		go rc.HandleSpeakerSelect(id, c.state.Speaker[id].Selected)
-	}
+func (c *Controller) fireSpeakerSelection(sel Selection) {
+	sel = copySelection(sel)
+	c.push(updateKey{kind: updateSpeakerSelection}, func(rc RemoteController) { rc.HandleSpeakerSelection(copySelection(sel)) })
 }
 func (c *Controller) fireSpeakerUpdate(id SpeakerID) {
-	for _, rc := range c.remoteController {
-		go rc.HandleSpeakerUpdate(id, c.state.Speaker[id])
-	}
-
+	spk := copySpeaker(c.state.Speaker[id])
+	c.push(updateKey{kind: updateSpeakerState, id: id}, func(rc RemoteController) { rc.HandleSpeakerUpdate(id, copySpeaker(spk)) })
 }
 func (c *Controller) fireMasterUpdate(master *MasterState) {
-	for _, rc := range c.remoteController {
-		go rc.HandleMasterUpdate(master)
-	}
+	master = copyMaster(master)
+	c.push(updateKey{kind: updateMasterState}, func(rc RemoteController) { rc.HandleMasterUpdate(copyMaster(master)) })
 }
 
 func (c *Controller) fireDeviceUpdate(status AdSetDeviceStatus) {
-
-	dev := &DeviceInfo{
+	c.device = DeviceInfo{
 		DeviceId:        status.DeviceId,
 		SerialNumber:    status.SerialNumber,
 		Model:           status.Model,
 		ConnectionState: status.ConnectionState,
 	}
-	for _, rc := range c.remoteController {
-		go rc.HandleDeviceUpdate(dev)
+	dev := c.device
+	c.push(updateKey{kind: updateDevice}, func(rc RemoteController) {
+		d := dev
+		rc.HandleDeviceUpdate(&d)
+	})
+}
+
+func (c *Controller) fireApproval() {
+	approval := c.approval
+	c.push(updateKey{kind: updateApproval}, func(rc RemoteController) { rc.HandleApproval(approval) })
+}
+
+// fireResult sends the result of a command to its origin, results are never dropped for newer ones
+func (c *Controller) fireResult(cmd RcCommand, result CommandResult) {
+	result.CorrelationID = cmd.CorrelationID
+	result.Command = cmd.Command
+	if result.Status != Accepted {
+		log.Debugf("Command %T from remote %d %s: %d %s", cmd.Command, cmd.Origin, result.Status, result.Value, result.Reason)
+	}
+
+	q, ok := c.remotes[cmd.Origin]
+	if !ok {
+		return
+	}
+	if _, ok := q.remote.(ResultRemote); !ok {
+		return
 	}
+	c.resultCount++
+	q.push(remoteUpdate{
+		key:     updateKey{kind: updateResult, seq: c.resultCount},
+		deliver: func(rc RemoteController) { rc.(ResultRemote).HandleResult(result) },
+	})
 }
 
-func (c *Controller) fireAllUpdate() {
-	for spkId, spk := range c.state.Speaker {
-		for _, rc := range c.remoteController {
-			go rc.HandleSpeakerUpdate(spkId, spk)
-		}
+func (c *Controller) fireScenes() {
+	scenes := copyScenes(c.state.Scenes)
+	c.push(updateKey{kind: updateScenes}, func(rc RemoteController) { rc.HandleScenes(copyScenes(scenes)) })
+}
+
+// register adds a remote and sends it the whole state
+func (c *Controller) register(q *remoteQueue) {
+	c.remotes[q.id] = q
+
+	for _, spkId := range c.state.SpeakerIDs() {
+		spk := copySpeaker(c.state.Speaker[spkId])
+		q.push(remoteUpdate{key: updateKey{kind: updateSpeakerState, id: spkId}, deliver: func(rc RemoteController) { rc.HandleSpeakerUpdate(spkId, spk) }})
 	}
-	for _, rc := range c.remoteController {
-		go rc.HandleMasterUpdate(c.state.Master)
+	master := copyMaster(c.state.Master)
+	q.push(remoteUpdate{key: updateKey{kind: updateMasterState}, deliver: func(rc RemoteController) { rc.HandleMasterUpdate(master) }})
+	approval := c.approval
+	q.push(remoteUpdate{key: updateKey{kind: updateApproval}, deliver: func(rc RemoteController) { rc.HandleApproval(approval) }})
+	if c.device.ConnectionState {
+		dev := c.device
+		q.push(remoteUpdate{key: updateKey{kind: updateDevice}, deliver: func(rc RemoteController) { rc.HandleDeviceUpdate(&dev) }})
 	}
+	scenes := copyScenes(c.state.Scenes)
+	q.push(remoteUpdate{key: updateKey{kind: updateScenes}, deliver: func(rc RemoteController) { rc.HandleScenes(scenes) }})
+}
 
+func (c *Controller) unregister(id RemoteID) {
+	q, ok := c.remotes[id]
+	if !ok {
+		return
+	}
+	delete(c.remotes, id)
+	q.stop()
+	log.Infof("Remote %d unregistered", id)
+}
+
+// checkRemotes sends a heartbeat to the remotes which can go away.
+// A remote which fails its heartbeat or didn't answer the last one is unregistered.
+func (c *Controller) checkRemotes() {
+	for id, q := range c.remotes {
+		if _, ok := q.remote.(HeartbeatRemote); !ok {
+			continue
+		}
+		if !q.beat(func(err error) {
+			log.Warnf("Remote %d failed its heartbeat: %s", id, err.Error())
+			c.Unregister(id)
+		}) {
+			log.Warnf("Remote %d doesn't answer", id)
+			c.unregister(id)
+		}
+	}
 }
 
 //Functional Methods
@@ -183,15 +406,37 @@ func (c *Controller) setDim(dim bool) {
 	c.fireDim()
 }
 
-func (c *Controller) setSpeakerSelected(id SpeakerID, sel bool) {
+// selectSpeakers resolves links and group rules first, the audio device and the remotes get one update with the final selection
+func (c *Controller) selectSpeakers(requests ...speakerRequest) {
+	sel := c.state.resolveSelection(requests)
+
+	changed := false
+	for id, selected := range sel {
+		spk := c.state.Speaker[id]
+		if spk.Selected != selected {
+			log.Debugf("Speaker %d selected: %t", id, selected)
+			spk.Selected = selected
+			changed = true
+		}
+	}
+	if !changed {
+		log.Debugf("Speaker selection %v, but no change needed", requests)
+		return
+	}
+
+	c.audioDevice.HandleSpeakerSelection(copySelection(sel))
+	c.fireSpeakerSelection(sel)
+}
+
+func (c *Controller) setSpeakerName(id SpeakerID, name string) {
 	speaker, ok := c.state.Speaker[id]
 	if !ok {
 		log.Warnf("No speaker to select: %d", id)
 		return
 	}
 
-	if speaker.Selected == sel {
-		log.Debugf("Set Speaker %d Enable: %t, but no change needed", id, sel)
+	if speaker.Name == name {
+		log.Debugf("Set Speaker %d name: %s, but no change needed", id, name)
 		return
 	}
 
@@ -199,62 +444,162 @@ func (c *Controller) setSpeakerSelected(id SpeakerID, sel bool) {
 		log.Debugf("Speaker is disabled. no action required")
 		return
 	}
+	speaker.Name = name
 
-	speaker.Selected = sel
+	c.audioDevice.HandleSpeakerUpdate(id, copySpeaker(speaker))
+	c.fireSpeakerUpdate(id)
+}
 
-	if speaker.Selected {
-		//if selected speaker is exclusive, disable all others with same type
-		if speaker.Exclusive {
-			for spkId, spk := range c.state.Speaker {
-				if speaker.Type == spk.Type && id != spkId && spk.Selected {
-					c.setSpeakerSelected(spkId, false)
-				}
-			}
-		} else {
-			//Check if other speakers set set to eclusive and must be deselected
-			for spkId, spk := range c.state.Speaker {
-				if speaker.Type == spk.Type && id != spkId && spk.Exclusive && spk.Selected {
-					c.setSpeakerSelected(spkId, false)
-				}
-			}
-		}
+// setSpeakerTrim returns the trim applied
+func (c *Controller) setSpeakerTrim(id SpeakerID, trim int) int {
+	speaker, ok := c.state.Speaker[id]
+	if !ok {
+		log.Warnf("No speaker to trim: %d", id)
+		return trim
 	}
 
-	c.audioDevice.HandleSpeakerSelect(id, speaker.Selected)
-	c.fireSpeakerSelect(id)
+	trim = max(TRIM_MIN_DB, min(TRIM_MAX_DB, trim))
+	if speaker.TrimDB == trim {
+		return trim
+	}
+	log.Debugf("Speaker %d trim: %d dB", id, trim)
+	speaker.TrimDB = trim
 
+	c.audioDevice.HandleSpeakerUpdate(id, copySpeaker(speaker))
+	c.fireSpeakerUpdate(id)
+	return trim
 }
 
-func (c *Controller) setSpeakerName(id SpeakerID, name string) {
+// setSpeakerChannels keeps solo and cut of channels with the same name
+func (c *Controller) setSpeakerChannels(id SpeakerID, names []string) {
 	speaker, ok := c.state.Speaker[id]
 	if !ok {
-		log.Warnf("No speaker to select: %d", id)
+		log.Warnf("No speaker for channels: %d", id)
 		return
 	}
 
-	if speaker.Name == name {
-		log.Debugf("Set Speaker %d name: %s, but no change needed", id, name)
+	old := make(map[string]*ChannelState, len(speaker.Channels))
+	for _, ch := range speaker.Channels {
+		old[ch.Name] = ch
+	}
+
+	speaker.Channels = make([]*ChannelState, 0, len(names))
+	for _, name := range names {
+		ch, ok := old[name]
+		if !ok {
+			ch = &ChannelState{Name: name}
+		}
+		speaker.Channels = append(speaker.Channels, ch)
+	}
+
+	c.audioDevice.HandleSpeakerUpdate(id, copySpeaker(speaker))
+	c.fireSpeakerUpdate(id)
+}
+
+func (c *Controller) setChannelSolo(id SpeakerID, channel int, solo bool) {
+	ch, ok := c.speakerChannel(id, channel)
+	if !ok || ch.Solo == solo {
 		return
 	}
+	log.Debugf("Speaker %d channel %s solo: %t", id, ch.Name, solo)
+	ch.Solo = solo
 
-	if speaker.Disabled {
-		log.Debugf("Speaker is disabled. no action required")
+	c.audioDevice.HandleSpeakerUpdate(id, copySpeaker(c.state.Speaker[id]))
+	c.fireSpeakerUpdate(id)
+}
+
+func (c *Controller) setChannelCut(id SpeakerID, channel int, cut bool) {
+	ch, ok := c.speakerChannel(id, channel)
+	if !ok || ch.Cut == cut {
 		return
 	}
-	speaker.Name = name
+	log.Debugf("Speaker %d channel %s cut: %t", id, ch.Name, cut)
+	ch.Cut = cut
 
-	c.audioDevice.HandleSpeakerUpdate(id, speaker)
+	c.audioDevice.HandleSpeakerUpdate(id, copySpeaker(c.state.Speaker[id]))
 	c.fireSpeakerUpdate(id)
 }
 
-func (c *Controller) setMasterVolumeDB(vol int) {
-	if c.state.Master.VolumeDB == vol {
+func (c *Controller) speakerChannel(id SpeakerID, channel int) (*ChannelState, bool) {
+	speaker, ok := c.state.Speaker[id]
+	if !ok || channel < 0 || channel >= len(speaker.Channels) {
+		log.Warnf("No channel %d of speaker %d", channel, id)
+		return nil, false
+	}
+	return speaker.Channels[channel], true
+}
+
+func (c *Controller) setCalibration(calibration bool) {
+	if c.state.Master.Calibration == calibration {
 		return
 	}
-	c.state.Master.VolumeDB = vol
+	log.Infof("Calibration: %t, reference level %d dB", calibration, c.state.Master.CalibrationLevelDB)
+	c.state.Master.Calibration = calibration
+
+	c.audioDevice.HandleMasterUpdate(copyMaster(c.state.Master))
+	c.fireMasterUpdate(c.state.Master)
+}
+
+// setMasterVolumeDB applies the volume limits, step limits the change of remote commands.
+// A limited volume is sent back to the audio device and all remotes, even if it didn't change,
+// the origin gets it regardless of its echo policy. It returns the volume applied.
+func (c *Controller) setMasterVolumeDB(vol int, step bool, origin RemoteID) int {
+	limited := c.state.limitVolume(vol, step)
+	if limited != vol {
+		log.Infof("Volume %d dB limited to %d dB", vol, limited)
+		origin = NoRemote
+	} else if c.state.Master.VolumeDB == vol {
+		return vol
+	}
+	c.state.Master.VolumeDB = limited
+
+	c.audioDevice.HandleVolume(limited)
+	c.fireVolume(origin)
+	return limited
+}
 
-	c.audioDevice.HandleVolume(vol)
-	c.fireVolume()
+// recallScene applies trims and dim offset first, so the new selection and volume start with the right levels
+func (c *Controller) recallScene(index int) {
+	if index < 0 || index >= len(c.state.Scenes) {
+		log.Warnf("No scene %d", index)
+		return
+	}
+	scene := c.state.Scenes[index]
+	log.Infof("Recall scene %d: %s", index, scene.Name)
+
+	for _, id := range c.state.SpeakerIDs() {
+		if trim, ok := scene.TrimDB[id]; ok {
+			c.setSpeakerTrim(id, trim)
+		}
+	}
+
+	if c.state.Master.DimOffset != scene.DimOffset {
+		c.state.Master.DimOffset = scene.DimOffset
+		c.audioDevice.HandleMasterUpdate(copyMaster(c.state.Master))
+		c.fireMasterUpdate(c.state.Master)
+	}
+
+	c.selectSpeakers(scene.Selection.requests()...)
+
+	c.setMasterVolumeDB(scene.VolumeDB, false, NoRemote)
+}
+
+func (c *Controller) storeScene(index int, name string) {
+	if !c.state.storeScene(index, name) {
+		log.Warnf("No scene %d to store", index)
+		return
+	}
+	log.Infof("Stored scene %d: %s", index, c.state.Scenes[index].Name)
+	c.fireScenes()
+}
+
+func (c *Controller) setApproval(approval ApprovalState) {
+	if c.approval == approval {
+		return
+	}
+	log.Debugf("Approval changed: %s", approval)
+	c.approval = approval
+	c.fireApproval()
 }
 
 func (c *Controller) setMasterLevel(left, right int) {
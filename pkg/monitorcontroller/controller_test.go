@@ -0,0 +1,344 @@
+package monitorcontroller
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDevice records the calls of the controller like the audio device connector gets them
+type testDevice struct {
+	mutex        sync.Mutex
+	calls        []string
+	toController chan interface{}
+}
+
+func (d *testDevice) record(format string, a ...interface{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.calls = append(d.calls, fmt.Sprintf(format, a...))
+}
+
+func (d *testDevice) received() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return slices.Clone(d.calls)
+}
+
+// send reports a change to the controller and returns once it's applied
+func (d *testDevice) send(c *Controller, msg interface{}) {
+	d.toController <- msg
+	for len(d.toController) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Snapshot() // served after the change
+}
+
+func (d *testDevice) SetControlChannel(ch chan interface{}) { d.toController = ch }
+func (d *testDevice) HandleDim(dim bool)                    { d.record("dim %t", dim) }
+func (d *testDevice) HandleMute(mute bool)                  { d.record("mute %t", mute) }
+func (d *testDevice) HandleVolume(vol int)                  { d.record("volume %d", vol) }
+func (d *testDevice) HandleMeter(level int)                 { d.record("meter %d", level) }
+func (d *testDevice) HandleSpeakerSelection(Selection)      { d.record("selection") }
+func (d *testDevice) HandleSpeakerName(id SpeakerID, name string) {
+	d.record("name %d %s", id, name)
+}
+func (d *testDevice) HandleSpeakerUpdate(id SpeakerID, spk *SpeakerState) {
+	d.record("speaker %d %t", id, spk.Selected)
+}
+func (d *testDevice) HandleMasterUpdate(*MasterState) { d.record("master") }
+
+// newTestController returns a controller with a connected test device
+func newTestController(t *testing.T, state *ControllerSate) (*Controller, *testDevice) {
+	t.Helper()
+	dev := &testDevice{}
+	c := NewController(dev, state)
+	if c == nil {
+		t.Fatal("no controller")
+	}
+	dev.send(c, AdSetDeviceStatus{DeviceId: 1, Model: "Scarlett 18i20", ConnectionState: true})
+	return c, dev
+}
+
+// waitResults returns the results once count were received
+func (r *testRemote) waitResults(t *testing.T, count int) []CommandResult {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		if results := r.commandResults(); len(results) >= count {
+			return results
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("got %d results, want %d", len(r.commandResults()), count)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// waitFor waits until the remote received update
+func (r *testRemote) waitFor(t *testing.T, update string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for !slices.Contains(r.received(), update) {
+		select {
+		case <-timeout:
+			t.Fatalf("got %v, want %s", r.received(), update)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// several remotes change the state while it's read by Snapshot and Changes, run with -race
+func TestControllerConcurrentRemotes(t *testing.T) {
+	c, dev := newTestController(t, NewDefaultState())
+
+	remotes := []*testRemote{{}, {}, {}}
+	for _, r := range remotes {
+		c.Register(r)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(3)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			s := c.Snapshot()
+			s.Master.VolumeDB = VOLUME_MAX_DB
+			s.Speaker[SpeakerA].Selected = !s.Speaker[SpeakerA].Selected
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		changes := c.Changes()
+		for {
+			select {
+			case <-stop:
+				return
+			case s := <-changes:
+				s.Master.Mute = !s.Master.Mute
+				s.SpeakerOrder[0] = Sub
+			}
+		}
+	}()
+	go func() {
+		defer readers.Done()
+		for level := 0; ; level-- {
+			select {
+			case <-stop:
+				return
+			case dev.toController <- AdSetLevel{Left: level % 100, Right: level % 100}:
+			}
+		}
+	}()
+
+	const commands = 300
+	var senders sync.WaitGroup
+	for i, r := range remotes {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for n := 1; n <= commands; n++ {
+				var command interface{}
+				switch n % 3 {
+				case 0:
+					command = RcSetVolume(-(n + i) % 100)
+				case 1:
+					command = RcSetMute(n%2 == 0)
+				case 2:
+					command = RcSpeakerSelect{Id: SpeakerID((n + i) % 3), State: n%4 != 0}
+				}
+				r.send(RcCommand{CorrelationID: uint64(n), Command: command})
+			}
+		}()
+	}
+	senders.Wait()
+
+	for _, r := range remotes {
+		results := r.waitResults(t, commands)
+		for n, result := range results {
+			if result.CorrelationID != uint64(n+1) {
+				t.Fatalf("result %d has correlation ID %d", n+1, result.CorrelationID)
+			}
+		}
+	}
+	close(stop)
+	readers.Wait()
+}
+
+func TestSnapshotIsDeepCopy(t *testing.T) {
+	state := NewDefaultState()
+	state.Groups = map[string]SelectionRule{"Speaker": RuleAtMostOne}
+	state.Links = []SpeakerLink{{Speaker: SpeakerB, Select: []SpeakerID{Sub}, Deselect: []SpeakerID{SpeakerA}}}
+	c, dev := newTestController(t, state)
+	dev.send(c, AdSetSpeakerChannels{Id: SpeakerA, Names: []string{"L", "R"}})
+
+	r := &testRemote{}
+	c.Register(r)
+	r.send(RcStoreScene{Index: 0, Name: "Mix"})
+	r.waitResults(t, 1)
+
+	mutate := func(s *ControllerSate) {
+		s.Master.VolumeDB = VOLUME_MAX_DB
+		s.Speaker[SpeakerA].Name = "changed"
+		s.Speaker[SpeakerA].Channels[0].Solo = true
+		delete(s.Speaker, SpeakerB)
+		s.SpeakerOrder[0] = Sub
+		s.Groups["Speaker"] = RuleAny
+		s.Links[0].Select[0] = SpeakerC
+		s.Scenes[0].Selection[SpeakerA] = false
+		s.Scenes[0].TrimDB[SpeakerA] = TRIM_MAX_DB
+	}
+
+	want := c.Snapshot()
+	mutate(c.Snapshot())
+	if got := c.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot changed by its copy\ngot  %+v\nwant %+v", got, want)
+	}
+
+	changes := c.Changes()
+	mutate(<-changes)
+	if got := c.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot changed by a published copy\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+// heartbeatRemote answers its heartbeats with err, with answer set a heartbeat waits until it's closed
+type heartbeatRemote struct {
+	*testRemote
+	err    error
+	answer chan struct{}
+	beats  atomic.Int32
+}
+
+func (r *heartbeatRemote) Heartbeat() error {
+	r.beats.Add(1)
+	if r.answer != nil {
+		<-r.answer
+	}
+	return r.err
+}
+
+func (r *heartbeatRemote) waitBeat(t *testing.T) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for r.beats.Load() == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("no heartbeat")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// checkUnregistered checks that r gets no more updates while listening does, and that the commands of r are dropped without blocking it
+func checkUnregistered(t *testing.T, c *Controller, dev *testDevice, r *testRemote, listening *testRemote) {
+	t.Helper()
+	dim := !c.Snapshot().Master.Dim
+	update := fmt.Sprintf("dim %t", dim)
+	dev.send(c, AdSetDim(dim))
+	listening.waitFor(t, update)
+	time.Sleep(20 * time.Millisecond)
+	if slices.Contains(r.received(), update) {
+		t.Fatalf("unregistered remote got %s", update)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		for n := 0; n < 300; n++ {
+			r.send(RcSetDim(!dim))
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("unregistered remote blocked sending")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if c.Snapshot().Master.Dim != dim {
+		t.Fatal("command of an unregistered remote applied")
+	}
+}
+
+// eagerRemote sends a command as soon as it gets the control channel
+type eagerRemote struct {
+	*testRemote
+}
+
+func (r *eagerRemote) SetControlChannel(ch chan interface{}) {
+	r.testRemote.SetControlChannel(ch)
+	ch <- RcCommand{CorrelationID: 1, Command: RcSetDim(true)}
+}
+
+func TestRegisteredBeforeFirstCommand(t *testing.T) {
+	c, _ := newTestController(t, NewDefaultState())
+	r := &eagerRemote{testRemote: &testRemote{}}
+	c.Register(r)
+
+	results := r.waitResults(t, 1)
+	if results[0].CorrelationID != 1 || results[0].Status != Accepted {
+		t.Fatalf("result %+v", results[0])
+	}
+	r.waitFor(t, "dim true")
+}
+
+func TestUnregister(t *testing.T) {
+	c, dev := newTestController(t, NewDefaultState())
+	r, listening := &testRemote{}, &testRemote{}
+	id := c.Register(r)
+	c.Register(listening)
+	r.waitFor(t, "scenes")
+
+	c.Unregister(id)
+	c.Snapshot() // served after the remote is gone
+	checkUnregistered(t, c, dev, r, listening)
+}
+
+func TestHeartbeatEviction(t *testing.T) {
+	interval := remoteHeartbeatInterval
+	remoteHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { remoteHeartbeatInterval = interval })
+
+	t.Run("failed", func(t *testing.T) {
+		c, dev := newTestController(t, NewDefaultState())
+		r := &heartbeatRemote{testRemote: &testRemote{}, err: errors.New("connection closed")}
+		listening := &heartbeatRemote{testRemote: &testRemote{}}
+		c.Register(r)
+		c.Register(listening)
+
+		r.waitBeat(t)
+		time.Sleep(20 * time.Millisecond)
+		checkUnregistered(t, c, dev, r.testRemote, listening.testRemote)
+		if listening.beats.Load() < 2 {
+			t.Fatalf("answering remote got %d heartbeats", listening.beats.Load())
+		}
+	})
+
+	t.Run("no answer", func(t *testing.T) {
+		c, dev := newTestController(t, NewDefaultState())
+		r := &heartbeatRemote{testRemote: &testRemote{}, answer: make(chan struct{})}
+		listening := &heartbeatRemote{testRemote: &testRemote{}}
+		c.Register(r)
+		c.Register(listening)
+
+		r.waitBeat(t)
+		time.Sleep(5 * remoteHeartbeatInterval)
+		close(r.answer)
+		checkUnregistered(t, c, dev, r.testRemote, listening.testRemote)
+		if n := r.beats.Load(); n != 1 {
+			t.Fatalf("remote not answering got %d heartbeats", n)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package monitorcontroller
+
+const (
+	VOLUME_MIN_DB int = -127
+	VOLUME_MAX_DB int = 0
+
+	DEFAULT_STARTUP_MAX_DB int = -20
+)
+
+// StartupPolicy decides the master volume and mute when the controller starts
+type StartupPolicy string
+
+const (
+	StartupRestore StartupPolicy = "restore" // the saved volume and mute
+	StartupCapped  StartupPolicy = "capped"  // the saved volume, at most StartupMaxDB
+	StartupMuted   StartupPolicy = "muted"   // the saved volume, muted
+)
+
+var StartupPolicies []StartupPolicy = []StartupPolicy{StartupRestore, StartupCapped, StartupMuted}
+
+// VolumeLimits protect the speakers and ears from slips, they are applied to every volume change
+type VolumeLimits struct {
+	MaxVolumeDB  int // highest master volume
+	MaxStepDB    int // largest change of one remote command, e.g. a fader or encoder move, 0: unlimited
+	Startup      StartupPolicy
+	StartupMaxDB int // highest volume at startup with StartupCapped
+}
+
+func DefaultVolumeLimits() VolumeLimits {
+	return VolumeLimits{
+		MaxVolumeDB:  VOLUME_MAX_DB,
+		MaxStepDB:    0,
+		Startup:      StartupRestore,
+		StartupMaxDB: DEFAULT_STARTUP_MAX_DB,
+	}
+}
+
+// limitVolume clamps vol to the volume range and the max volume,
+// with step the change from the current volume is limited to MaxStepDB as well
+func (s *ControllerSate) limitVolume(vol int, step bool) int {
+	if step && s.Limits.MaxStepDB > 0 {
+		vol = max(s.Master.VolumeDB-s.Limits.MaxStepDB, min(s.Master.VolumeDB+s.Limits.MaxStepDB, vol))
+	}
+	return max(VOLUME_MIN_DB, min(s.Limits.MaxVolumeDB, vol))
+}
+
+// applyStartupPolicy limits the restored master state before anything is sent
+func (s *ControllerSate) applyStartupPolicy() {
+	switch s.Limits.Startup {
+	case StartupCapped:
+		s.Master.VolumeDB = min(s.Limits.StartupMaxDB, s.Master.VolumeDB)
+	case StartupMuted:
+		s.Master.Mute = true
+	}
+	s.Master.VolumeDB = s.limitVolume(s.Master.VolumeDB, false)
+}
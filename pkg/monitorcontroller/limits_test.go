@@ -0,0 +1,137 @@
+package monitorcontroller
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLimitVolume(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		maxDB   int
+		stepDB  int
+		vol     int
+		step    bool
+		want    int
+	}{
+		{name: "in range", current: -40, maxDB: VOLUME_MAX_DB, vol: -20, want: -20},
+		{name: "over the range", current: -40, maxDB: VOLUME_MAX_DB, vol: 10, want: VOLUME_MAX_DB},
+		{name: "under the range", current: -40, maxDB: VOLUME_MAX_DB, vol: -200, want: VOLUME_MIN_DB},
+		{name: "max volume", current: -40, maxDB: -10, vol: -3, want: -10},
+		{name: "max volume reached", current: -40, maxDB: -10, vol: -10, want: -10},
+		{name: "step up", current: -40, maxDB: VOLUME_MAX_DB, stepDB: 6, vol: 0, step: true, want: -34},
+		{name: "step down", current: -40, maxDB: VOLUME_MAX_DB, stepDB: 6, vol: VOLUME_MIN_DB, step: true, want: -46},
+		{name: "small step", current: -40, maxDB: VOLUME_MAX_DB, stepDB: 6, vol: -36, step: true, want: -36},
+		{name: "step and max volume", current: -12, maxDB: -10, stepDB: 6, vol: 0, step: true, want: -10},
+		{name: "step unlimited", current: -40, maxDB: VOLUME_MAX_DB, vol: 0, step: true, want: 0},
+		{name: "step limit without step", current: -40, maxDB: VOLUME_MAX_DB, stepDB: 6, vol: 0, want: 0},
+		{name: "step over the range", current: -3, maxDB: VOLUME_MAX_DB, stepDB: 6, vol: 0, step: true, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewDefaultState()
+			s.Master.VolumeDB = test.current
+			s.Limits.MaxVolumeDB = test.maxDB
+			s.Limits.MaxStepDB = test.stepDB
+			if got := s.limitVolume(test.vol, test.step); got != test.want {
+				t.Fatalf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyStartupPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   StartupPolicy
+		maxDB    int
+		volume   int
+		mute     bool
+		wantVol  int
+		wantMute bool
+	}{
+		{name: "restore", policy: StartupRestore, maxDB: VOLUME_MAX_DB, volume: -3, wantVol: -3},
+		{name: "restore muted", policy: StartupRestore, maxDB: VOLUME_MAX_DB, volume: -3, mute: true, wantVol: -3, wantMute: true},
+		{name: "restore over the max volume", policy: StartupRestore, maxDB: -10, volume: -3, wantVol: -10},
+		{name: "capped", policy: StartupCapped, maxDB: VOLUME_MAX_DB, volume: -3, wantVol: DEFAULT_STARTUP_MAX_DB},
+		{name: "capped below", policy: StartupCapped, maxDB: VOLUME_MAX_DB, volume: -40, wantVol: -40},
+		{name: "capped over the max volume", policy: StartupCapped, maxDB: -30, volume: -3, wantVol: -30},
+		{name: "muted", policy: StartupMuted, maxDB: VOLUME_MAX_DB, volume: -3, wantVol: -3, wantMute: true},
+		{name: "muted over the max volume", policy: StartupMuted, maxDB: -10, volume: -3, wantVol: -10, wantMute: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewDefaultState()
+			s.Limits.Startup = test.policy
+			s.Limits.MaxVolumeDB = test.maxDB
+			s.Master.VolumeDB = test.volume
+			s.Master.Mute = test.mute
+			s.applyStartupPolicy()
+			if s.Master.VolumeDB != test.wantVol || s.Master.Mute != test.wantMute {
+				t.Fatalf("volume %d, mute %t, want %d, %t", s.Master.VolumeDB, s.Master.Mute, test.wantVol, test.wantMute)
+			}
+		})
+	}
+}
+
+func TestStartupPolicyApplied(t *testing.T) {
+	state := NewDefaultState()
+	state.Limits.Startup = StartupCapped
+	state.Master.VolumeDB = -3
+	c, _ := newTestController(t, state)
+
+	if vol := c.Snapshot().Master.VolumeDB; vol != DEFAULT_STARTUP_MAX_DB {
+		t.Fatalf("volume %d at startup", vol)
+	}
+}
+
+// a large fader jump of a remote is limited to the step, the origin gets the limited value even if it doesn't get its own echoes
+func TestVolumeLimitsReported(t *testing.T) {
+	state := NewDefaultState()
+	state.Master.VolumeDB = -40
+	state.Limits.MaxVolumeDB = -6
+	state.Limits.MaxStepDB = 6
+	c, dev := newTestController(t, state)
+
+	origin, other := &testRemote{policy: EchoPolicy{Mode: EchoNotToOrigin}}, &testRemote{}
+	c.Register(origin)
+	c.Register(other)
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "fader jump", command: RcSetVolume(0), status: Clamped, value: -34},
+		{name: "fader step", command: RcSetVolume(-30), status: Accepted},
+	})
+
+	// the audio device isn't step limited
+	dev.send(c, AdSetVolume(-10))
+	if vol := c.Snapshot().Master.VolumeDB; vol != -10 {
+		t.Fatalf("volume %d set by the audio device", vol)
+	}
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "fader jump over the max volume", command: RcSetVolume(0), status: Clamped, value: -6},
+		{name: "fader jump down", command: RcSetVolume(VOLUME_MIN_DB), status: Clamped, value: -12},
+	})
+
+	origin.waitFor(t, "volume -12")
+	other.waitFor(t, "volume -12")
+	for _, update := range []string{"volume -34", "volume -6"} {
+		if !slices.Contains(origin.received(), update) {
+			t.Errorf("origin didn't get the limited %s", update)
+		}
+		if !slices.Contains(other.received(), update) {
+			t.Errorf("other remote didn't get %s", update)
+		}
+	}
+	if slices.Contains(origin.received(), "volume -30") {
+		t.Error("origin got the echo of its own volume")
+	}
+	for _, call := range []string{"volume -34", "volume -30", "volume -6", "volume -12"} {
+		if !slices.Contains(dev.received(), call) {
+			t.Errorf("audio device didn't get %s", call)
+		}
+	}
+}
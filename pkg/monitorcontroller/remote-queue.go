@@ -0,0 +1,179 @@
+package monitorcontroller
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type updateKind int
+
+const (
+	updateDim updateKind = iota
+	updateMute
+	updateVolume
+	updateMeter
+	updateSpeakerSelection
+	updateSpeakerName
+	updateSpeakerState
+	updateMasterState
+	updateDevice
+	updateApproval
+	updateScenes
+	updateResult
+	updateHeartbeat
+)
+
+// updateKey identifies the updates replacing each other, id is only used by the speaker updates
+type updateKey struct {
+	kind updateKind
+	id   SpeakerID
+	seq  uint64 // keeps the command results apart, they never replace each other
+}
+
+// echoable updates are the continuous values of faders and sliders, the echo policy of their origin applies
+func (k updateKey) echoable() bool {
+	return k.kind == updateVolume
+}
+
+type remoteUpdate struct {
+	key     updateKey
+	origin  RemoteID // remote whose command set the value, NoRemote if it wasn't set exactly as requested
+	deliver func(RemoteController)
+}
+
+// remoteQueue delivers the updates to one remote controller in order, one at a time.
+// A pending update is dropped when a newer update of the same key is queued,
+// so a slow remote gets the latest state instead of a backlog of meter values.
+type remoteQueue struct {
+	id     RemoteID
+	remote RemoteController
+	policy EchoPolicy
+
+	mutex   sync.Mutex
+	pending []remoteUpdate
+	wake    chan struct{}
+
+	held      map[updateKey]int // echoes held back until the value settled, by sequence number
+	heldCount int
+
+	beating atomic.Bool // a heartbeat is queued and not answered yet
+	done    chan struct{}
+}
+
+func newRemoteQueue(id RemoteID, remote RemoteController) *remoteQueue {
+	q := &remoteQueue{
+		id:      id,
+		remote:  remote,
+		policy:  EchoPolicy{Mode: EchoAlways},
+		pending: make([]remoteUpdate, 0),
+		wake:    make(chan struct{}, 1),
+		held:    make(map[updateKey]int),
+		done:    make(chan struct{}),
+	}
+	if p, ok := remote.(EchoPolicyRemote); ok {
+		q.policy = p.EchoPolicy()
+	}
+	go q.run()
+	return q
+}
+
+// push queues an update, deliver must only use values captured when pushing
+func (q *remoteQueue) push(u remoteUpdate) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// a newer value replaces an echo held back
+	delete(q.held, u.key)
+
+	if u.key.echoable() && u.origin == q.id && q.policy.Mode != EchoAlways {
+		// an older value still pending would move the fader back
+		q.pending = slices.DeleteFunc(q.pending, func(p remoteUpdate) bool { return p.key == u.key })
+		switch q.policy.Mode {
+		case EchoNotToOrigin:
+			return
+		case EchoFinal:
+			q.hold(u)
+			return
+		}
+	}
+	q.enqueue(u)
+}
+
+// hold delivers the update once no newer value came in for the settle time
+func (q *remoteQueue) hold(u remoteUpdate) {
+	q.heldCount++
+	seq := q.heldCount
+	q.held[u.key] = seq
+
+	time.AfterFunc(q.policy.Settle, func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		if q.held[u.key] != seq {
+			return
+		}
+		delete(q.held, u.key)
+		q.enqueue(u)
+	})
+}
+
+func (q *remoteQueue) enqueue(u remoteUpdate) {
+	q.pending = slices.DeleteFunc(q.pending, func(p remoteUpdate) bool { return p.key == u.key })
+	q.pending = append(q.pending, u)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// beat queues a heartbeat, failed is called if the remote returns an error.
+// It returns false if the last heartbeat isn't answered yet.
+func (q *remoteQueue) beat(failed func(error)) bool {
+	if q.beating.Swap(true) {
+		return false
+	}
+	q.push(remoteUpdate{key: updateKey{kind: updateHeartbeat}, deliver: func(rc RemoteController) {
+		if err := rc.(HeartbeatRemote).Heartbeat(); err != nil {
+			failed(err)
+			return
+		}
+		q.beating.Store(false)
+	}})
+	return true
+}
+
+// stop ends the delivery, the pending updates are dropped
+func (q *remoteQueue) stop() {
+	close(q.done)
+}
+
+func (q *remoteQueue) run() {
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		}
+
+		for {
+			select {
+			case <-q.done:
+				return
+			default:
+			}
+
+			q.mutex.Lock()
+			if len(q.pending) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			u := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mutex.Unlock()
+
+			u.deliver(q.remote)
+		}
+	}
+}
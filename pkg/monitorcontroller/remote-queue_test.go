@@ -0,0 +1,275 @@
+package monitorcontroller
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRemote records the updates it gets, every delivery waits for delay
+type testRemote struct {
+	mutex    sync.Mutex
+	updates  []string
+	results  []CommandResult
+	commands chan interface{}
+	policy   EchoPolicy
+	delay    time.Duration
+	gate     chan struct{} // the first delivery waits until it's closed
+	first    sync.Once
+}
+
+func (r *testRemote) record(format string, a ...interface{}) {
+	r.first.Do(func() {
+		if r.gate != nil {
+			<-r.gate
+		}
+	})
+	time.Sleep(r.delay)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.updates = append(r.updates, fmt.Sprintf(format, a...))
+}
+
+func (r *testRemote) received() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.updates)
+}
+
+// wait returns the updates once count were received
+func (r *testRemote) wait(t *testing.T, count int) []string {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		if updates := r.received(); len(updates) >= count {
+			return updates
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("got %v, want %d updates", r.received(), count)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// send sends a command to the controller like a remote does
+func (r *testRemote) send(command interface{}) {
+	r.mutex.Lock()
+	commands := r.commands
+	r.mutex.Unlock()
+	commands <- command
+}
+
+func (r *testRemote) commandResults() []CommandResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.results)
+}
+
+func (r *testRemote) SetControlChannel(ch chan interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = ch
+}
+
+func (r *testRemote) HandleDim(dim bool)               { r.record("dim %t", dim) }
+func (r *testRemote) HandleMute(mute bool)             { r.record("mute %t", mute) }
+func (r *testRemote) HandleVolume(vol int)             { r.record("volume %d", vol) }
+func (r *testRemote) HandleMeter(left, right int)      { r.record("meter %d %d", left, right) }
+func (r *testRemote) HandleSpeakerSelection(Selection) { r.record("selection") }
+func (r *testRemote) HandleSpeakerName(id SpeakerID, name string) {
+	r.record("name %d %s", id, name)
+}
+func (r *testRemote) HandleSpeakerUpdate(id SpeakerID, spk *SpeakerState) {
+	r.record("speaker %d %t", id, spk.Selected)
+}
+func (r *testRemote) HandleMasterUpdate(*MasterState) { r.record("master") }
+func (r *testRemote) HandleDeviceUpdate(*DeviceInfo)  { r.record("device") }
+func (r *testRemote) HandleApproval(a ApprovalState)  { r.record("approval %s", a) }
+func (r *testRemote) HandleScenes([]Scene)            { r.record("scenes") }
+func (r *testRemote) EchoPolicy() EchoPolicy          { return r.policy }
+func (r *testRemote) HandleResult(result CommandResult) {
+	r.mutex.Lock()
+	r.results = append(r.results, result)
+	r.mutex.Unlock()
+	r.record("result %d", result.CorrelationID)
+}
+
+// blockedRemote returns a remote blocking its first delivery until release is called
+func blockedRemote() (*testRemote, func()) {
+	r := &testRemote{gate: make(chan struct{})}
+	return r, func() { close(r.gate) }
+}
+
+func newTestQueue(t *testing.T, r *testRemote) *remoteQueue {
+	q := newRemoteQueue(1, r)
+	t.Cleanup(q.stop)
+	return q
+}
+
+func volumeUpdate(vol int, origin RemoteID) remoteUpdate {
+	return remoteUpdate{key: updateKey{kind: updateVolume}, origin: origin, deliver: func(rc RemoteController) { rc.HandleVolume(vol) }}
+}
+
+func dimUpdate(dim bool) remoteUpdate {
+	return remoteUpdate{key: updateKey{kind: updateDim}, deliver: func(rc RemoteController) { rc.HandleDim(dim) }}
+}
+
+func meterUpdate(level int) remoteUpdate {
+	return remoteUpdate{key: updateKey{kind: updateMeter}, deliver: func(rc RemoteController) { rc.HandleMeter(level, level) }}
+}
+
+func speakerUpdate(id SpeakerID, selected bool) remoteUpdate {
+	spk := &SpeakerState{Selected: selected}
+	return remoteUpdate{key: updateKey{kind: updateSpeakerState, id: id}, deliver: func(rc RemoteController) { rc.HandleSpeakerUpdate(id, spk) }}
+}
+
+func checkUpdates(t *testing.T, got []string, want []string) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Fatalf("got  %v\nwant %v", got, want)
+	}
+}
+
+// while the remote is busy newer updates replace the pending ones of the same key,
+// the pending updates are delivered in the order of their last push
+func TestQueueCoalescesInterleavedKeys(t *testing.T) {
+	r, release := blockedRemote()
+	q := newTestQueue(t, r)
+
+	q.push(volumeUpdate(-10, NoRemote)) // delivered first, blocks the remote
+	time.Sleep(10 * time.Millisecond)
+
+	q.push(volumeUpdate(-11, NoRemote))
+	q.push(dimUpdate(true))
+	q.push(meterUpdate(-30))
+	q.push(volumeUpdate(-12, NoRemote))
+	q.push(speakerUpdate(SpeakerA, true))
+	q.push(meterUpdate(-31))
+	q.push(speakerUpdate(SpeakerB, false))
+	q.push(dimUpdate(false))
+	q.push(speakerUpdate(SpeakerA, false))
+	q.push(meterUpdate(-32))
+	release()
+
+	checkUpdates(t, r.wait(t, 6), []string{
+		"volume -10",
+		"volume -12",
+		"speaker 1 false",
+		"dim false",
+		"speaker 0 false",
+		"meter -32 -32",
+	})
+	time.Sleep(20 * time.Millisecond)
+	if n := len(r.received()); n != 6 {
+		t.Fatalf("%d updates delivered", n)
+	}
+}
+
+func TestQueueKeepsResultsApart(t *testing.T) {
+	r, release := blockedRemote()
+	q := newTestQueue(t, r)
+
+	q.push(dimUpdate(true))
+	time.Sleep(10 * time.Millisecond)
+	for seq := uint64(1); seq <= 3; seq++ {
+		result := CommandResult{CorrelationID: seq}
+		q.push(remoteUpdate{key: updateKey{kind: updateResult, seq: seq}, deliver: func(rc RemoteController) {
+			rc.(ResultRemote).HandleResult(result)
+		}})
+	}
+	release()
+
+	checkUpdates(t, r.wait(t, 4), []string{"dim true", "result 1", "result 2", "result 3"})
+}
+
+func TestQueueEchoPolicy(t *testing.T) {
+	const origin RemoteID = 1
+
+	t.Run("not to origin", func(t *testing.T) {
+		r := &testRemote{policy: EchoPolicy{Mode: EchoNotToOrigin}}
+		q := newTestQueue(t, r)
+		q.push(volumeUpdate(-10, origin))
+		q.push(volumeUpdate(-20, NoRemote)) // changed by the controller, e.g. limited
+		checkUpdates(t, r.wait(t, 1), []string{"volume -20"})
+	})
+
+	t.Run("final", func(t *testing.T) {
+		r := &testRemote{policy: EchoPolicy{Mode: EchoFinal, Settle: 30 * time.Millisecond}}
+		q := newTestQueue(t, r)
+		for vol := -20; vol <= -10; vol++ {
+			q.push(volumeUpdate(vol, origin))
+			time.Sleep(5 * time.Millisecond)
+		}
+		checkUpdates(t, r.wait(t, 1), []string{"volume -10"})
+		time.Sleep(50 * time.Millisecond)
+		checkUpdates(t, r.received(), []string{"volume -10"})
+	})
+}
+
+// concurrent pushers and a slow remote: every key gets its last value and its values in pushing order
+func TestQueueSlowRemoteConcurrentPushes(t *testing.T) {
+	r := &testRemote{delay: 200 * time.Microsecond}
+	q := newTestQueue(t, r)
+
+	const pushes = 500
+	var wg sync.WaitGroup
+	for _, kind := range []string{"volume", "meter", "speaker"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= pushes; i++ {
+				switch kind {
+				case "volume":
+					q.push(volumeUpdate(-i, NoRemote))
+				case "meter":
+					q.push(meterUpdate(-i))
+				case "speaker":
+					q.push(speakerUpdate(SpeakerID(i%2), true))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	last := map[string]string{
+		"volume":  fmt.Sprintf("volume %d", -pushes),
+		"meter":   fmt.Sprintf("meter %d %d", -pushes, -pushes),
+		"speaker": "speaker 0 true",
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		updates := r.received()
+		if slices.Contains(updates, last["volume"]) && slices.Contains(updates, last["meter"]) && slices.Contains(updates, last["speaker"]) {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("last values not delivered, got %d updates", len(updates))
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	updates := r.received()
+	if len(updates) >= 3*pushes {
+		t.Errorf("nothing coalesced, %d updates", len(updates))
+	}
+	// the values of a key only go down
+	previous := map[string]int{}
+	for _, u := range updates {
+		var kind string
+		var v, w int
+		if _, err := fmt.Sscanf(u, "%s %d %d", &kind, &v, &w); err != nil {
+			fmt.Sscanf(u, "%s %d", &kind, &v)
+		}
+		if kind == "speaker" {
+			continue
+		}
+		if p, ok := previous[kind]; ok && v >= p {
+			t.Fatalf("%s delivered out of order: %d after %d", kind, v, p)
+		}
+		previous[kind] = v
+	}
+}
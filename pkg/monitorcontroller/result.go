@@ -0,0 +1,108 @@
+package monitorcontroller
+
+import "fmt"
+
+type ResultStatus int
+
+const (
+	Accepted ResultStatus = iota // applied as sent
+	Clamped                      // applied with another value, e.g. by the volume limits
+	Rejected                     // not applied, see Reason
+)
+
+func (s ResultStatus) String() string {
+	switch s {
+	case Accepted:
+		return "accepted"
+	case Clamped:
+		return "clamped"
+	case Rejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("ResultStatus(%d)", int(s))
+}
+
+// CommandResult tells the origin of a command what became of it
+type CommandResult struct {
+	CorrelationID uint64 // as sent with the RcCommand, 0 for plain commands
+	Command       interface{}
+	Status        ResultStatus
+	Value         int    // the applied value with Clamped
+	Reason        string // with Rejected
+}
+
+// ResultRemote is implemented by remotes which want the results of their commands,
+// e.g. to flash an LED or show a message instead of showing stale state
+type ResultRemote interface {
+	HandleResult(CommandResult)
+}
+
+func accepted() CommandResult {
+	return CommandResult{Status: Accepted}
+}
+
+func clamped(value int) CommandResult {
+	return CommandResult{Status: Clamped, Value: value}
+}
+
+func rejected(format string, a ...interface{}) CommandResult {
+	return CommandResult{Status: Rejected, Reason: fmt.Sprintf(format, a...)}
+}
+
+func (d DeviceInfo) connected() bool {
+	return d.ConnectionState && d.DeviceId != 0
+}
+
+// rejectReason checks a remote command before it's applied, it returns "" if the command can be applied
+func (c *Controller) rejectReason(command interface{}) string {
+	switch r := command.(type) {
+	case RcUpdateRequest:
+		return ""
+	case RcStoreScene:
+		if r.Index < 0 || r.Index > len(c.state.Scenes) {
+			return fmt.Sprintf("no scene %d", r.Index)
+		}
+		return ""
+	}
+
+	if !c.device.connected() {
+		return "no device connected"
+	}
+
+	switch r := command.(type) {
+	case RcSpeakerSelect:
+		return c.speakerRejectReason(r.Id)
+	case RcSetSpeakerTrim:
+		return c.speakerRejectReason(r.Id)
+	case RcSetChannelSolo:
+		return c.channelRejectReason(r.Id, r.Channel)
+	case RcSetChannelCut:
+		return c.channelRejectReason(r.Id, r.Channel)
+	case RcRecallScene:
+		if int(r) < 0 || int(r) >= len(c.state.Scenes) {
+			return fmt.Sprintf("no scene %d", int(r))
+		}
+	}
+	return ""
+}
+
+func (c *Controller) speakerRejectReason(id SpeakerID) string {
+	spk, ok := c.state.Speaker[id]
+	if !ok {
+		return fmt.Sprintf("no speaker %d", id)
+	}
+	if spk.Disabled {
+		return fmt.Sprintf("speaker %s is disabled", spk.Name)
+	}
+	return ""
+}
+
+func (c *Controller) channelRejectReason(id SpeakerID, channel int) string {
+	if reason := c.speakerRejectReason(id); reason != "" {
+		return reason
+	}
+	if channel < 0 || channel >= len(c.state.Speaker[id].Channels) {
+		return fmt.Sprintf("speaker %s has no channel %d", c.state.Speaker[id].Name, channel)
+	}
+	return ""
+}
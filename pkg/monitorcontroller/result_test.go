@@ -0,0 +1,125 @@
+package monitorcontroller
+
+import (
+	"strings"
+	"testing"
+)
+
+type resultTest struct {
+	name    string
+	command interface{}
+	status  ResultStatus
+	value   int
+	reason  string // contained in the reason of a rejection
+}
+
+// runResultTests sends the commands from origin and checks their results, other must get none of them
+func runResultTests(t *testing.T, origin *testRemote, other *testRemote, tests []resultTest) {
+	t.Helper()
+	sent := len(origin.commandResults())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent++
+			id := uint64(sent)
+			origin.send(RcCommand{CorrelationID: id, Command: test.command})
+			result := origin.waitResults(t, sent)[sent-1]
+
+			if result.CorrelationID != id {
+				t.Fatalf("correlation ID %d, want %d", result.CorrelationID, id)
+			}
+			if result.Command != test.command {
+				t.Errorf("command %#v, want %#v", result.Command, test.command)
+			}
+			if result.Status != test.status || result.Value != test.value {
+				t.Fatalf("%s %d (%s), want %s %d", result.Status, result.Value, result.Reason, test.status, test.value)
+			}
+			if test.status == Rejected && !strings.Contains(result.Reason, test.reason) {
+				t.Errorf("reason %q, want %q", result.Reason, test.reason)
+			}
+		})
+	}
+	if results := other.commandResults(); len(results) != 0 {
+		t.Fatalf("results sent to another remote: %+v", results)
+	}
+}
+
+func TestCommandResults(t *testing.T) {
+	state := NewDefaultState()
+	state.Limits.MaxVolumeDB = -10
+	state.Groups = map[string]SelectionRule{"Subwoofer": RuleExactlyOne}
+	c, dev := newTestController(t, state)
+	dev.send(c, AdSetSpeakerChannels{Id: SpeakerA, Names: []string{"L", "R"}})
+
+	origin, other := &testRemote{}, &testRemote{}
+	c.Register(origin)
+	c.Register(other)
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "accepted", command: RcSetDim(true), status: Accepted},
+		{name: "accepted volume", command: RcSetVolume(-30), status: Accepted},
+		{name: "volume over the limit", command: RcSetVolume(0), status: Clamped, value: -10},
+		{name: "volume under the range", command: RcSetVolume(-200), status: Clamped, value: VOLUME_MIN_DB},
+		{name: "trim over the range", command: RcSetSpeakerTrim{Id: SpeakerA, TrimDB: 30}, status: Clamped, value: TRIM_MAX_DB},
+		{name: "trim in range", command: RcSetSpeakerTrim{Id: SpeakerB, TrimDB: -3}, status: Accepted},
+		{name: "disabled speaker", command: RcSpeakerSelect{Id: SpeakerD, State: true}, status: Rejected, reason: "disabled"},
+		{name: "trim of a disabled speaker", command: RcSetSpeakerTrim{Id: SpeakerD, TrimDB: 3}, status: Rejected, reason: "disabled"},
+		{name: "unknown speaker", command: RcSpeakerSelect{Id: 42, State: true}, status: Rejected, reason: "no speaker 42"},
+		{name: "speaker kept by its group", command: RcSpeakerSelect{Id: Sub, State: false}, status: Rejected, reason: "rule of its group"},
+		{name: "channel", command: RcSetChannelSolo{Id: SpeakerA, Channel: 1, State: true}, status: Accepted},
+		{name: "channel out of range", command: RcSetChannelSolo{Id: SpeakerA, Channel: 2, State: true}, status: Rejected, reason: "no channel 2"},
+		{name: "negative channel", command: RcSetChannelCut{Id: SpeakerA, Channel: -1, State: true}, status: Rejected, reason: "no channel -1"},
+		{name: "channel of a speaker without channels", command: RcSetChannelCut{Id: SpeakerB, Channel: 0, State: true}, status: Rejected, reason: "no channel 0"},
+		{name: "scene out of range", command: RcRecallScene(0), status: Rejected, reason: "no scene 0"},
+		{name: "scene store after the next index", command: RcStoreScene{Index: 1}, status: Rejected, reason: "no scene 1"},
+		{name: "scene store", command: RcStoreScene{Index: 0}, status: Accepted},
+		{name: "scene recall", command: RcRecallScene(0), status: Accepted},
+		{name: "negative scene", command: RcRecallScene(-1), status: Rejected, reason: "no scene -1"},
+	})
+
+	// plain commands have no correlation ID
+	origin.send(RcSetDim(false))
+	results := origin.waitResults(t, 20)
+	if result := results[19]; result.CorrelationID != 0 || result.Status != Accepted {
+		t.Fatalf("result of a plain command %+v", result)
+	}
+}
+
+func TestCommandResultsWithoutDevice(t *testing.T) {
+	t.Run("never connected", func(t *testing.T) {
+		c := NewController(&testDevice{}, NewDefaultState())
+		origin, other := &testRemote{}, &testRemote{}
+		c.Register(origin)
+		c.Register(other)
+
+		runResultTests(t, origin, other, []resultTest{
+			{name: "mute", command: RcSetMute(false), status: Rejected, reason: "no device"},
+			{name: "speaker", command: RcSpeakerSelect{Id: SpeakerB, State: true}, status: Rejected, reason: "no device"},
+			{name: "update request", command: RcUpdateRequest(true), status: Accepted},
+			{name: "scene store", command: RcStoreScene{Index: 0}, status: Accepted},
+		})
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		c, dev := newTestController(t, NewDefaultState())
+		dev.send(c, AdSetDeviceStatus{DeviceId: 0, ConnectionState: true})
+		origin, other := &testRemote{}, &testRemote{}
+		c.Register(origin)
+		c.Register(other)
+
+		runResultTests(t, origin, other, []resultTest{
+			{name: "volume", command: RcSetVolume(-20), status: Rejected, reason: "no device"},
+		})
+	})
+
+	t.Run("disconnected", func(t *testing.T) {
+		c, dev := newTestController(t, NewDefaultState())
+		dev.send(c, AdSetDeviceStatus{DeviceId: 1, ConnectionState: false})
+		origin, other := &testRemote{}, &testRemote{}
+		c.Register(origin)
+		c.Register(other)
+
+		runResultTests(t, origin, other, []resultTest{
+			{name: "dim", command: RcSetDim(true), status: Rejected, reason: "no device"},
+		})
+	})
+}
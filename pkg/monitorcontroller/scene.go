@@ -0,0 +1,87 @@
+package monitorcontroller
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Scene is a stored monitoring setup, remotes address scenes by their index in ControllerSate.Scenes
+type Scene struct {
+	Name      string
+	Selection Selection
+	VolumeDB  int
+	DimOffset int
+	TrimDB    map[SpeakerID]int
+}
+
+// captureScene returns the current setup as scene
+func (s *ControllerSate) captureScene(name string) Scene {
+	scene := Scene{
+		Name:      name,
+		Selection: s.Selection(),
+		VolumeDB:  s.Master.VolumeDB,
+		DimOffset: s.Master.DimOffset,
+		TrimDB:    make(map[SpeakerID]int, len(s.Speaker)),
+	}
+	for id, spk := range s.Speaker {
+		scene.TrimDB[id] = spk.TrimDB
+	}
+	return scene
+}
+
+// storeScene captures the current setup into the scene at index, an index after the last scene appends a new one.
+// An empty name keeps the name of the stored scene.
+func (s *ControllerSate) storeScene(index int, name string) bool {
+	if index < 0 || index > len(s.Scenes) {
+		return false
+	}
+	if index == len(s.Scenes) {
+		if name == "" {
+			name = fmt.Sprintf("Scene %d", index+1)
+		}
+		s.Scenes = append(s.Scenes, s.captureScene(name))
+		return true
+	}
+	if name == "" {
+		name = s.Scenes[index].Name
+	}
+	s.Scenes[index] = s.captureScene(name)
+	return true
+}
+
+// SceneNames returns the scene names by index
+func (s *ControllerSate) SceneNames() []string {
+	names := make([]string, 0, len(s.Scenes))
+	for _, scene := range s.Scenes {
+		names = append(names, scene.Name)
+	}
+	return names
+}
+
+// RemoveScene deletes a scene, the following scenes move up one index
+func (s *ControllerSate) RemoveScene(index int) {
+	if index < 0 || index >= len(s.Scenes) {
+		return
+	}
+	s.Scenes = append(s.Scenes[:index], s.Scenes[index+1:]...)
+}
+
+// MoveScene moves a scene by offset positions
+func (s *ControllerSate) MoveScene(index int, offset int) {
+	to := index + offset
+	if index < 0 || index >= len(s.Scenes) || to < 0 || to >= len(s.Scenes) {
+		return
+	}
+	s.Scenes[index], s.Scenes[to] = s.Scenes[to], s.Scenes[index]
+}
+
+// copyScenes returns the scenes without shared maps, remotes get a copy
+func copyScenes(scenes []Scene) []Scene {
+	c := make([]Scene, 0, len(scenes))
+	for _, scene := range scenes {
+		scene.Selection = maps.Clone(scene.Selection)
+		scene.TrimDB = maps.Clone(scene.TrimDB)
+		c = append(c, scene)
+	}
+	return c
+}
@@ -0,0 +1,138 @@
+package monitorcontroller
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestStoreScene(t *testing.T) {
+	stored := []Scene{{Name: "Mix", Selection: selected(SpeakerB), VolumeDB: -50, DimOffset: 10, TrimDB: map[SpeakerID]int{SpeakerB: -3}}}
+
+	tests := []struct {
+		name   string
+		scenes []Scene
+		index  int
+		scene  string
+		ok     bool
+		want   []string
+	}{
+		{name: "first", index: 0, scene: "Mix", ok: true, want: []string{"Mix"}},
+		{name: "first default name", index: 0, ok: true, want: []string{"Scene 1"}},
+		{name: "new", scenes: stored, index: 1, scene: "Mastering", ok: true, want: []string{"Mix", "Mastering"}},
+		{name: "new default name", scenes: stored, index: 1, ok: true, want: []string{"Mix", "Scene 2"}},
+		{name: "existing", scenes: stored, index: 0, scene: "Tracking", ok: true, want: []string{"Tracking"}},
+		{name: "existing keeps its name", scenes: stored, index: 0, ok: true, want: []string{"Mix"}},
+		{name: "after the next index", scenes: stored, index: 2, scene: "Mastering", want: []string{"Mix"}},
+		{name: "negative index", scenes: stored, index: -1, scene: "Mastering", want: []string{"Mix"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewDefaultState()
+			s.Scenes = copyScenes(test.scenes)
+			s.Master.VolumeDB = -30
+			s.Speaker[SpeakerA].TrimDB = 2
+
+			if ok := s.storeScene(test.index, test.scene); ok != test.ok {
+				t.Fatalf("stored %t", ok)
+			}
+			if names := s.SceneNames(); !slices.Equal(names, test.want) {
+				t.Fatalf("scenes %v, want %v", names, test.want)
+			}
+			if !test.ok {
+				if !reflect.DeepEqual(s.Scenes, test.scenes) {
+					t.Fatalf("scenes changed: %+v", s.Scenes)
+				}
+				return
+			}
+
+			scene := s.Scenes[test.index]
+			trims := map[SpeakerID]int{SpeakerA: 2, SpeakerB: 0, SpeakerC: 0, SpeakerD: 0, Sub: 0}
+			if !maps.Equal(scene.Selection, selected(SpeakerA, Sub)) || scene.VolumeDB != -30 || scene.DimOffset != 20 || !maps.Equal(scene.TrimDB, trims) {
+				t.Fatalf("stored %+v", scene)
+			}
+			if len(test.scenes) > 0 && test.index > 0 && !reflect.DeepEqual(s.Scenes[0], test.scenes[0]) {
+				t.Fatalf("other scene changed: %+v", s.Scenes[0])
+			}
+		})
+	}
+}
+
+// scenes stored and recalled through a remote
+func TestRecallScene(t *testing.T) {
+	c, _ := newTestController(t, NewDefaultState())
+	origin, other := &testRemote{}, &testRemote{}
+	c.Register(origin)
+	c.Register(other)
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "volume", command: RcSetVolume(-30), status: Accepted},
+		{name: "speaker", command: RcSpeakerSelect{Id: SpeakerB, State: true}, status: Accepted},
+		{name: "trim", command: RcSetSpeakerTrim{Id: SpeakerB, TrimDB: -3}, status: Accepted},
+		{name: "store", command: RcStoreScene{Index: 0, Name: "Mix"}, status: Accepted},
+	})
+	want := c.Snapshot()
+	if names := want.SceneNames(); !slices.Equal(names, []string{"Mix"}) {
+		t.Fatalf("scenes %v", names)
+	}
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "change volume", command: RcSetVolume(-50), status: Accepted},
+		{name: "change speaker", command: RcSpeakerSelect{Id: SpeakerC, State: true}, status: Accepted},
+		{name: "change trim", command: RcSetSpeakerTrim{Id: SpeakerB, TrimDB: 5}, status: Accepted},
+		{name: "recall", command: RcRecallScene(0), status: Accepted},
+	})
+	if got := c.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recalled\n%+v\nwant\n%+v", got, want)
+	}
+	other.waitFor(t, "volume -30")
+
+	runResultTests(t, origin, other, []resultTest{
+		{name: "change volume again", command: RcSetVolume(-50), status: Accepted},
+		{name: "recall out of range", command: RcRecallScene(1), status: Rejected, reason: "no scene 1"},
+	})
+	if vol := c.Snapshot().Master.VolumeDB; vol != -50 {
+		t.Fatalf("volume %d after recalling a missing scene", vol)
+	}
+}
+
+// a scene is validated against the rules and limits of the recall, not the ones it was stored with
+func TestRecallSceneValidated(t *testing.T) {
+	state := NewDefaultState()
+	state.Groups = map[string]SelectionRule{"Speaker": RuleExactlyOne}
+	state.Limits.MaxVolumeDB = -10
+	state.Limits.MaxStepDB = 3
+	state.Master.VolumeDB = -40
+	state.Scenes = []Scene{{
+		Name:      "Stored without limits",
+		Selection: selected(SpeakerB, SpeakerC, SpeakerD),
+		VolumeDB:  0,
+		DimOffset: 20,
+		TrimDB:    map[SpeakerID]int{SpeakerA: 30, SpeakerB: -30, 42: 3},
+	}}
+	c, dev := newTestController(t, state)
+	r := &testRemote{}
+	c.Register(r)
+
+	r.send(RcRecallScene(0))
+	r.waitResults(t, 1)
+	got := c.Snapshot()
+
+	// Speaker B is decided first and kept by the exactly one rule, the disabled Speaker D isn't selected
+	if sel := got.Selection(); !maps.Equal(sel, selected(SpeakerB)) {
+		t.Errorf("selection %v", sel)
+	}
+	// the max volume applies, the step limit of remote commands doesn't
+	if got.Master.VolumeDB != -10 {
+		t.Errorf("volume %d", got.Master.VolumeDB)
+	}
+	if got.Speaker[SpeakerA].TrimDB != TRIM_MAX_DB || got.Speaker[SpeakerB].TrimDB != TRIM_MIN_DB {
+		t.Errorf("trims %d, %d", got.Speaker[SpeakerA].TrimDB, got.Speaker[SpeakerB].TrimDB)
+	}
+	if !slices.Contains(dev.received(), "volume -10") {
+		t.Errorf("audio device got %v", dev.received())
+	}
+	r.waitFor(t, "volume -10")
+}
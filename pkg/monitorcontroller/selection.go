@@ -0,0 +1,146 @@
+package monitorcontroller
+
+import "slices"
+
+// Selection is the selection state of all speakers
+type Selection map[SpeakerID]bool
+
+// SelectionRule of a speaker group
+type SelectionRule string
+
+const (
+	RuleExclusiveFlag SelectionRule = ""            // exclusive speakers deselect the others of the group, see SpeakerState.Exclusive
+	RuleExactlyOne    SelectionRule = "exactly-one" // selecting a speaker deselects the others, the last one can't be deselected
+	RuleAtMostOne     SelectionRule = "at-most-one" // selecting a speaker deselects the others
+	RuleAny           SelectionRule = "any"         // speakers are selected independently
+)
+
+var SelectionRules []SelectionRule = []SelectionRule{RuleExclusiveFlag, RuleExactlyOne, RuleAtMostOne, RuleAny}
+
+// SpeakerLink selects and deselects other speakers when a speaker gets selected,
+// e.g. selecting the far fields enables the sub, selecting the nearfields disables it.
+type SpeakerLink struct {
+	Speaker  SpeakerID
+	Select   []SpeakerID
+	Deselect []SpeakerID
+}
+
+type speakerRequest struct {
+	id    SpeakerID
+	state bool
+}
+
+// requests returns a request per speaker, selections first, the group rules keep the speaker decided first
+func (sel Selection) requests() []speakerRequest {
+	requests := make([]speakerRequest, 0, len(sel))
+	for id, state := range sel {
+		requests = append(requests, speakerRequest{id: id, state: state})
+	}
+	slices.SortFunc(requests, func(a, b speakerRequest) int {
+		if a.state != b.state {
+			if a.state {
+				return -1
+			}
+			return 1
+		}
+		return int(a.id - b.id)
+	})
+	return requests
+}
+
+// Selection returns the current selection of all speakers
+func (s *ControllerSate) Selection() Selection {
+	sel := make(Selection, len(s.Speaker))
+	for id, spk := range s.Speaker {
+		sel[id] = spk.Selected
+	}
+	return sel
+}
+
+// resolveSelection applies the requests, the speaker links and the group rules to the current selection.
+// Every speaker is decided once, requests win over links and links over group rules.
+// Disabled speakers are never changed.
+func (s *ControllerSate) resolveSelection(requests []speakerRequest) Selection {
+	old := s.Selection()
+	sel := s.Selection()
+	decided := make(map[SpeakerID]int)
+
+	queue := slices.Clone(requests)
+	for len(queue) > 0 {
+		req := queue[0]
+		queue = queue[1:]
+
+		spk, ok := s.Speaker[req.id]
+		if _, done := decided[req.id]; done || !ok || spk.Disabled {
+			continue
+		}
+		decided[req.id] = len(decided)
+		sel[req.id] = req.state
+		if !req.state {
+			continue
+		}
+
+		for _, link := range s.Links {
+			if link.Speaker != req.id {
+				continue
+			}
+			for _, id := range link.Select {
+				queue = append(queue, speakerRequest{id: id, state: true})
+			}
+			for _, id := range link.Deselect {
+				queue = append(queue, speakerRequest{id: id, state: false})
+			}
+		}
+
+		if s.Groups[spk.GroupName()] == RuleExclusiveFlag {
+			for _, id := range s.SpeakerIDs() {
+				other := s.Speaker[id]
+				if id != req.id && other.GroupName() == spk.GroupName() && sel[id] && (spk.Exclusive || other.Exclusive) {
+					queue = append(queue, speakerRequest{id: id, state: false})
+				}
+			}
+		}
+	}
+
+	for group, rule := range s.Groups {
+		if rule != RuleExactlyOne && rule != RuleAtMostOne {
+			continue
+		}
+
+		// the speaker decided first stays selected, speakers selected before lose against decided ones
+		var keep SpeakerID
+		first := -1
+		selected := make([]SpeakerID, 0)
+		for _, id := range s.SpeakerIDs() {
+			spk := s.Speaker[id]
+			if spk.GroupName() != group || spk.Disabled || !sel[id] {
+				continue
+			}
+			selected = append(selected, id)
+			if n, ok := decided[id]; ok && (first < 0 || n < first) {
+				keep, first = id, n
+			}
+		}
+		if len(selected) > 1 {
+			if first < 0 {
+				keep = selected[0]
+			}
+			for _, id := range selected {
+				sel[id] = id == keep
+			}
+		}
+
+		// deselecting the last speaker is refused
+		if rule == RuleExactlyOne && len(selected) == 0 {
+			for _, id := range s.SpeakerIDs() {
+				spk := s.Speaker[id]
+				if spk.GroupName() == group && !spk.Disabled && old[id] {
+					sel[id] = true
+					break
+				}
+			}
+		}
+	}
+
+	return sel
+}
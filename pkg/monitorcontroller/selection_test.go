@@ -0,0 +1,189 @@
+package monitorcontroller
+
+import (
+	"maps"
+	"testing"
+)
+
+// selected returns the selection of the default speakers with ids selected
+func selected(ids ...SpeakerID) Selection {
+	sel := Selection{SpeakerA: false, SpeakerB: false, SpeakerC: false, SpeakerD: false, Sub: false}
+	for _, id := range ids {
+		sel[id] = true
+	}
+	return sel
+}
+
+func TestResolveSelection(t *testing.T) {
+	nonExclusive := func(ids ...SpeakerID) func(s *ControllerSate) {
+		return func(s *ControllerSate) {
+			for _, id := range ids {
+				s.Speaker[id].Exclusive = false
+			}
+		}
+	}
+	rule := func(r SelectionRule) func(s *ControllerSate) {
+		return func(s *ControllerSate) { s.Groups = map[string]SelectionRule{"Speaker": r} }
+	}
+
+	tests := []struct {
+		name     string
+		setup    []func(s *ControllerSate)
+		requests Selection
+		want     Selection
+	}{
+		// the default state has Speaker A and the sub selected, Speaker D disabled and all speakers exclusive
+		{name: "exclusive flag select", requests: Selection{SpeakerB: true}, want: selected(SpeakerB, Sub)},
+		{name: "exclusive flag deselect", requests: Selection{SpeakerA: false}, want: selected(Sub)},
+		{name: "exclusive flag other group", requests: Selection{Sub: false}, want: selected(SpeakerA)},
+		{
+			name:     "exclusive flag non exclusive speakers",
+			setup:    []func(s *ControllerSate){nonExclusive(SpeakerA, SpeakerB)},
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerA, SpeakerB, Sub),
+		},
+		{
+			name:     "exclusive flag exclusive speaker deselects non exclusive ones",
+			setup:    []func(s *ControllerSate){nonExclusive(SpeakerA, SpeakerB), func(s *ControllerSate) { s.Speaker[SpeakerB].Selected = true }},
+			requests: Selection{SpeakerC: true},
+			want:     selected(SpeakerC, Sub),
+		},
+		{
+			name:     "exclusive flag requests win",
+			setup:    []func(s *ControllerSate){nonExclusive(SpeakerA, SpeakerB)},
+			requests: Selection{SpeakerB: true, SpeakerC: true},
+			want:     selected(SpeakerB, SpeakerC, Sub),
+		},
+		{name: "disabled speaker", requests: Selection{SpeakerD: true}, want: selected(SpeakerA, Sub)},
+		{name: "unknown speaker", requests: Selection{42: true}, want: selected(SpeakerA, Sub)},
+
+		{name: "exactly one select", setup: []func(s *ControllerSate){rule(RuleExactlyOne)}, requests: Selection{SpeakerC: true}, want: selected(SpeakerC, Sub)},
+		{name: "exactly one deselect the last", setup: []func(s *ControllerSate){rule(RuleExactlyOne)}, requests: Selection{SpeakerA: false}, want: selected(SpeakerA, Sub)},
+		{name: "exactly one select two", setup: []func(s *ControllerSate){rule(RuleExactlyOne)}, requests: Selection{SpeakerB: true, SpeakerC: true}, want: selected(SpeakerB, Sub)},
+		{name: "exactly one switch", setup: []func(s *ControllerSate){rule(RuleExactlyOne)}, requests: Selection{SpeakerA: false, SpeakerB: true}, want: selected(SpeakerB, Sub)},
+		{
+			name:     "exactly one ignores the exclusive flag",
+			setup:    []func(s *ControllerSate){rule(RuleExactlyOne), nonExclusive(SpeakerA, SpeakerB)},
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerB, Sub),
+		},
+		{
+			name:     "exactly one without a speaker selected before",
+			setup:    []func(s *ControllerSate){rule(RuleExactlyOne), func(s *ControllerSate) { s.Speaker[SpeakerA].Selected = false }},
+			requests: Selection{SpeakerA: false},
+			want:     selected(Sub),
+		},
+
+		{name: "at most one select", setup: []func(s *ControllerSate){rule(RuleAtMostOne)}, requests: Selection{SpeakerC: true}, want: selected(SpeakerC, Sub)},
+		{name: "at most one deselect the last", setup: []func(s *ControllerSate){rule(RuleAtMostOne)}, requests: Selection{SpeakerA: false}, want: selected(Sub)},
+		{name: "at most one select two", setup: []func(s *ControllerSate){rule(RuleAtMostOne)}, requests: Selection{SpeakerC: true, SpeakerB: true}, want: selected(SpeakerB, Sub)},
+
+		{name: "any select", setup: []func(s *ControllerSate){rule(RuleAny)}, requests: Selection{SpeakerB: true, SpeakerC: true}, want: selected(SpeakerA, SpeakerB, SpeakerC, Sub)},
+		{name: "any deselect the last", setup: []func(s *ControllerSate){rule(RuleAny)}, requests: Selection{SpeakerA: false}, want: selected(Sub)},
+		{name: "any disabled speaker", setup: []func(s *ControllerSate){rule(RuleAny)}, requests: Selection{SpeakerD: true}, want: selected(SpeakerA, Sub)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewDefaultState()
+			for _, setup := range test.setup {
+				setup(s)
+			}
+			if got := s.resolveSelection(test.requests.requests()); !maps.Equal(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveLinkedSelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		groups   map[string]SelectionRule
+		links    []SpeakerLink
+		current  Selection
+		requests Selection
+		want     Selection
+	}{
+		{
+			name:     "select",
+			links:    []SpeakerLink{{Speaker: SpeakerB, Select: []SpeakerID{Sub}}},
+			current:  selected(SpeakerA),
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerB, Sub),
+		},
+		{
+			name:     "deselect",
+			links:    []SpeakerLink{{Speaker: SpeakerB, Deselect: []SpeakerID{Sub}}},
+			current:  selected(SpeakerA, Sub),
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerB),
+		},
+		{
+			name:     "applied on selection only",
+			links:    []SpeakerLink{{Speaker: SpeakerA, Deselect: []SpeakerID{Sub}}},
+			current:  selected(SpeakerA, Sub),
+			requests: Selection{SpeakerA: false},
+			want:     selected(Sub),
+		},
+		{
+			name:     "request wins over link",
+			links:    []SpeakerLink{{Speaker: SpeakerB, Select: []SpeakerID{Sub}}},
+			current:  selected(SpeakerA),
+			requests: Selection{SpeakerB: true, Sub: false},
+			want:     selected(SpeakerB),
+		},
+		{
+			name:     "disabled speaker",
+			links:    []SpeakerLink{{Speaker: SpeakerB, Select: []SpeakerID{SpeakerD, Sub}}},
+			current:  selected(SpeakerA),
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerB, Sub),
+		},
+		{
+			name:     "unknown speaker",
+			links:    []SpeakerLink{{Speaker: SpeakerB, Select: []SpeakerID{42}}},
+			current:  selected(SpeakerA),
+			requests: Selection{SpeakerB: true},
+			want:     selected(SpeakerB),
+		},
+		{
+			name:     "chained",
+			links:    []SpeakerLink{{Speaker: Sub, Select: []SpeakerID{SpeakerC}}, {Speaker: SpeakerC, Deselect: []SpeakerID{SpeakerA}}},
+			groups:   map[string]SelectionRule{"Speaker": RuleAny},
+			current:  selected(SpeakerA, SpeakerB),
+			requests: Selection{Sub: true},
+			want:     selected(SpeakerB, SpeakerC, Sub),
+		},
+		{
+			name:     "link wins over the group rule",
+			links:    []SpeakerLink{{Speaker: Sub, Select: []SpeakerID{SpeakerC}}},
+			groups:   map[string]SelectionRule{"Speaker": RuleExactlyOne},
+			current:  selected(SpeakerA),
+			requests: Selection{Sub: true},
+			want:     selected(SpeakerC, Sub),
+		},
+		{
+			name:     "link deselects the last speaker of an exactly one group",
+			links:    []SpeakerLink{{Speaker: Sub, Deselect: []SpeakerID{SpeakerA}}},
+			groups:   map[string]SelectionRule{"Speaker": RuleExactlyOne},
+			current:  selected(SpeakerA),
+			requests: Selection{Sub: true},
+			want:     selected(SpeakerA, Sub),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewDefaultState()
+			s.Groups = test.groups
+			s.Links = test.links
+			for id, state := range test.current {
+				s.Speaker[id].Selected = state
+			}
+			if got := s.resolveSelection(test.requests.requests()); !maps.Equal(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package monitorcontroller
+
+import "reflect"
+
+// Snapshot returns a deep copy of the controller state, taken between two commands
+func (c *Controller) Snapshot() *ControllerSate {
+	reply := make(chan *ControllerSate)
+	c.snapshotRequest <- reply
+	return <-reply
+}
+
+// Changes returns a stream of snapshots, starting with the current state and followed by one after every change.
+// A snapshot not read yet is replaced by the newer one, so a slow reader only misses states in between.
+// Meter levels are left out, they change all the time.
+func (c *Controller) Changes() <-chan *ControllerSate {
+	ch := make(chan *ControllerSate, 1)
+	c.subscribe <- ch
+	return ch
+}
+
+// publish sends a snapshot to the subscribers if the state changed since the last one
+func (c *Controller) publish() {
+	snapshot := c.state.Copy()
+	snapshot.Master.LevelLeft = VOLUME_MIN_DB
+	snapshot.Master.LevelRight = VOLUME_MIN_DB
+	if reflect.DeepEqual(snapshot, c.published) {
+		return
+	}
+	c.published = snapshot
+
+	for _, ch := range c.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot.Copy()
+	}
+}
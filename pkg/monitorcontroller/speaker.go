@@ -1,15 +1,15 @@
 package monitorcontroller
 
+// SpeakerID identifies a speaker of the configured speaker list, IDs are kept when speakers are reordered
 type SpeakerID int
 
+// IDs of the speakers of the default configuration
 const (
 	SpeakerA SpeakerID = iota
 	SpeakerB
 	SpeakerC
 	SpeakerD
 	Sub
-
-	SPEAKER_LEN
 )
 
 type SpeakerType int
@@ -19,10 +19,11 @@ const (
 	Subwoofer
 )
 
-var SpeakerName map[SpeakerID]string = map[SpeakerID]string{
-	SpeakerA: "Speaker A",
-	SpeakerB: "Speaker B",
-	SpeakerC: "Speaker C",
-	SpeakerD: "Speaker D",
-	Sub:      "Sub",
+var SpeakerTypeName map[SpeakerType]string = map[SpeakerType]string{
+	Speaker:   "Speaker",
+	Subwoofer: "Subwoofer",
+}
+
+func (t SpeakerType) String() string {
+	return SpeakerTypeName[t]
 }
@@ -1,8 +1,29 @@
 package monitorcontroller
 
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+const (
+	TRIM_MIN_DB int = -20
+	TRIM_MAX_DB int = 20
+
+	DEFAULT_CALIBRATION_LEVEL_DB int = -20
+)
+
 type ControllerSate struct {
-	Speaker map[SpeakerID]*SpeakerState
-	Master  *MasterState
+	Speaker      map[SpeakerID]*SpeakerState
+	SpeakerOrder []SpeakerID // order of the speakers in the GUI and for remotes
+	Master       *MasterState
+
+	Groups map[string]SelectionRule // rules by group name, groups without rule use the exclusive flags
+	Links  []SpeakerLink
+
+	Limits VolumeLimits
+
+	Scenes []Scene // recalled by index
 }
 
 type SpeakerState struct {
@@ -10,7 +31,42 @@ type SpeakerState struct {
 	Name      string
 	Selected  bool
 	Type      SpeakerType
-	Exclusive bool
+	Group     string // selection group, empty: the type name
+	Exclusive bool   // deselects the others of the group if the group has no rule
+	TrimDB    int    // added to the master volume, TRIM_MIN_DB .. TRIM_MAX_DB
+
+	Channels []*ChannelState `yaml:"-"` // reported by the audio device
+}
+
+// GroupName returns the exclusivity group of the speaker
+func (s *SpeakerState) GroupName() string {
+	if s.Group == "" {
+		return s.Type.String()
+	}
+	return s.Group
+}
+
+// ChannelState is one output of a speaker set, e.g. L, R, C, LFE, Ls, Rs
+type ChannelState struct {
+	Name string
+	Solo bool
+	Cut  bool
+}
+
+// ChannelAudible returns false if the channel is cut or another channel of the speaker is soloed
+func (s *SpeakerState) ChannelAudible(channel int) bool {
+	if channel < 0 || channel >= len(s.Channels) {
+		return true
+	}
+	if s.Channels[channel].Cut {
+		return false
+	}
+	for _, ch := range s.Channels {
+		if ch.Solo {
+			return s.Channels[channel].Solo
+		}
+	}
+	return true
 }
 
 type MasterState struct {
@@ -21,6 +77,10 @@ type MasterState struct {
 	LevelLeft  int `yaml:"-"`
 	LevelRight int `yaml:"-"`
 	DimOffset  int
+
+	// while calibrating all speakers play at CalibrationLevelDB + trim, volume and dim are ignored
+	Calibration        bool `yaml:"-"`
+	CalibrationLevelDB int
 }
 
 func NewDefaultState() *ControllerSate {
@@ -32,24 +92,154 @@ func NewDefaultState() *ControllerSate {
 			LevelLeft:  -127,
 			LevelRight: -127,
 			DimOffset:  20,
+
+			CalibrationLevelDB: DEFAULT_CALIBRATION_LEVEL_DB,
 		},
 		Speaker: make(map[SpeakerID]*SpeakerState),
+		Limits:  DefaultVolumeLimits(),
 	}
 
-	for spkId, name := range SpeakerName {
-		s.Speaker[spkId] = &SpeakerState{
-			Name:      name,
-			Selected:  false,
-			Type:      Speaker,
-			Exclusive: true,
-		}
-	}
+	s.AddSpeaker("Speaker A", Speaker)
+	s.AddSpeaker("Speaker B", Speaker)
+	s.AddSpeaker("Speaker C", Speaker)
+	s.AddSpeaker("Speaker D", Speaker)
+	s.AddSpeaker("Sub", Subwoofer)
 
 	s.Speaker[SpeakerA].Selected = true
 	s.Speaker[SpeakerD].Disabled = true
 	s.Speaker[Sub].Selected = true
-	s.Speaker[Sub].Type = Subwoofer
 
 	return s
 
 }
+
+// Copy returns a deep copy of the state, sharing nothing with the original
+func (s *ControllerSate) Copy() *ControllerSate {
+	c := &ControllerSate{
+		Speaker:      make(map[SpeakerID]*SpeakerState, len(s.Speaker)),
+		SpeakerOrder: slices.Clone(s.SpeakerOrder),
+		Groups:       maps.Clone(s.Groups),
+		Links:        make([]SpeakerLink, 0, len(s.Links)),
+		Limits:       s.Limits,
+		Scenes:       copyScenes(s.Scenes),
+	}
+	for id, spk := range s.Speaker {
+		c.Speaker[id] = copySpeaker(spk)
+	}
+	if s.Master != nil {
+		c.Master = copyMaster(s.Master)
+	}
+	for _, link := range s.Links {
+		link.Select = slices.Clone(link.Select)
+		link.Deselect = slices.Clone(link.Deselect)
+		c.Links = append(c.Links, link)
+	}
+	return c
+}
+
+// copySpeaker returns a copy of a speaker, remotes and the audio device never get pointers into the controller state
+func copySpeaker(spk *SpeakerState) *SpeakerState {
+	c := *spk
+	c.Channels = make([]*ChannelState, 0, len(spk.Channels))
+	for _, ch := range spk.Channels {
+		chCopy := *ch
+		c.Channels = append(c.Channels, &chCopy)
+	}
+	return &c
+}
+
+func copyMaster(master *MasterState) *MasterState {
+	c := *master
+	return &c
+}
+
+func copySelection(sel Selection) Selection {
+	return maps.Clone(sel)
+}
+
+// SpeakerIDs returns the speakers in list order, speakers missing in SpeakerOrder follow sorted by ID
+func (s *ControllerSate) SpeakerIDs() []SpeakerID {
+	ids := make([]SpeakerID, 0, len(s.Speaker))
+	for _, id := range s.SpeakerOrder {
+		if _, ok := s.Speaker[id]; ok && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	missing := make([]SpeakerID, 0)
+	for id := range s.Speaker {
+		if !slices.Contains(ids, id) {
+			missing = append(missing, id)
+		}
+	}
+	slices.Sort(missing)
+
+	return append(ids, missing...)
+}
+
+// AddSpeaker appends a speaker to the list and returns its ID
+func (s *ControllerSate) AddSpeaker(name string, t SpeakerType) SpeakerID {
+	id := SpeakerID(0)
+	for spkId := range s.Speaker {
+		id = max(id, spkId+1)
+	}
+	if name == "" {
+		name = fmt.Sprintf("Speaker %d", id+1)
+	}
+
+	s.SpeakerOrder = append(s.SpeakerIDs(), id)
+	s.Speaker[id] = &SpeakerState{
+		Name:      name,
+		Type:      t,
+		Exclusive: true,
+	}
+	return id
+}
+
+// RemoveSpeaker removes a speaker from the list and its links
+func (s *ControllerSate) RemoveSpeaker(id SpeakerID) {
+	delete(s.Speaker, id)
+	s.SpeakerOrder = s.SpeakerIDs()
+
+	links := make([]SpeakerLink, 0, len(s.Links))
+	for _, link := range s.Links {
+		if link.Speaker == id {
+			continue
+		}
+		link.Select = slices.DeleteFunc(slices.Clone(link.Select), func(other SpeakerID) bool { return other == id })
+		link.Deselect = slices.DeleteFunc(slices.Clone(link.Deselect), func(other SpeakerID) bool { return other == id })
+		if len(link.Select) > 0 || len(link.Deselect) > 0 {
+			links = append(links, link)
+		}
+	}
+	s.Links = links
+
+	for _, scene := range s.Scenes {
+		delete(scene.Selection, id)
+		delete(scene.TrimDB, id)
+	}
+}
+
+// GroupNames returns the selection groups of all speakers in list order
+func (s *ControllerSate) GroupNames() []string {
+	names := make([]string, 0)
+	for _, id := range s.SpeakerIDs() {
+		name := s.Speaker[id].GroupName()
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// MoveSpeaker moves a speaker by offset positions in the list
+func (s *ControllerSate) MoveSpeaker(id SpeakerID, offset int) {
+	ids := s.SpeakerIDs()
+	i := slices.Index(ids, id)
+	if i < 0 {
+		return
+	}
+	j := max(0, min(len(ids)-1, i+offset))
+	ids = slices.Delete(ids, i, i+1)
+	s.SpeakerOrder = slices.Insert(ids, j, id)
+}